@@ -8,23 +8,48 @@ import (
 )
 
 type API struct {
-	bidsvc  models.BidService
-	itemsvc models.ItemService
-	usersvc models.UserService
+	bidsvc    models.BidService
+	itemsvc   models.ItemService
+	usersvc   models.UserService
+	walletsvc models.WalletService
+	settlesvc models.SettlementService
+	watchsvc  models.WatchlistService
+	notifysvc models.NotificationService
+
+	// pager splits the lists into pages
+	pager *models.Pager
+	// origins are the web pages allowed to open the live channel besides the ones of the API itself
+	origins []string
+	// adminToken is the bearer token of the administrators, the admin routes being left out without it
+	adminToken string
 
 	viewErr views.Error
 	log     *log.Logger
 }
 
-func NewAPI(db *models.DB, log *log.Logger) API {
+// NewAPI builds the services of the API on top of db. The settlement service is shared with the
+// scheduler, which settles the items in the background. origins are the origins of the web pages,
+// such as "https://example.com", allowed to open the live channel besides the ones of the API itself,
+// and adminToken the token of the administrators, empty to leave the admin routes out.
+func NewAPI(db *models.DB, log *log.Logger, ss models.SettlementService, origins []string, adminToken string, bidOpts ...models.BidOption) API {
 	us := models.NewUserService(db)
 	is := models.NewItemService(db, us)
-	bs := models.NewBidService(db, is, us)
+	bs := models.NewBidService(db, is, us, bidOpts...)
+	ws := models.NewWalletService(db, us)
+	wls := models.NewWatchlistService(db, is, us, bs)
+	ns := models.NewNotificationService(db, us)
 
 	return API{
-		bidsvc:  bs,
-		itemsvc: is,
-		usersvc: us,
-		log:     log,
+		bidsvc:     bs,
+		itemsvc:    is,
+		usersvc:    us,
+		walletsvc:  ws,
+		settlesvc:  ss,
+		watchsvc:   wls,
+		notifysvc:  ns,
+		pager:      models.NewPager(db),
+		origins:    origins,
+		adminToken: adminToken,
+		log:        log,
 	}
 }
@@ -4,10 +4,13 @@ import (
 	"context"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/noelruault/auction-bid-tracker/internal/models"
+	"github.com/noelruault/auction-bid-tracker/internal/views"
 	"github.com/noelruault/auction-bid-tracker/internal/web"
 )
 
@@ -23,9 +26,154 @@ func (app *App) Health(w http.ResponseWriter, r *http.Request) {
 func (app *App) ListItems(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
 
-	items := app.Api.itemsvc.ListItems()
+	p, err := listPage(r)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	f, err := itemFilter(r)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	items, err := app.Api.itemsvc.ListItemsByFilter(f)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	items, next, err := app.Api.pager.Items(items, p)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, web.NewPage(r, views.PublicItems(items), next), http.StatusOK)
+}
+
+// listPage reads the page of a list asked for by r from its query string, giving the order of the
+// list, the number of elements of the page and the cursor of the page, if it is not the first:
+//
+//	/items/?sort=-endsAt&limit=20&cursor=eyJsIjoiL2l0ZW1zLyIsInMiOiItZW5kc0F0Ii...
+func listPage(r *http.Request) (models.Page, error) {
+	q := r.URL.Query()
+	p := models.Page{List: r.URL.Path, Sort: q.Get("sort"), Cursor: q.Get("cursor")}
+
+	if v := q.Get("limit"); v != "" {
+		var err error
+		if p.Limit, err = strconv.Atoi(v); err != nil || p.Limit < 1 {
+			return p, models.ValidationError{"limit": models.ErrInvalid}
+		}
+	}
+
+	return p, nil
+}
+
+// itemFilter reads the filter of the items listed from the query string of r:
+//
+//	/items/?category=electronics/phones&tag=vintage&tag=boxed&minPrice=1000&maxPrice=5000&currency=GBP&hasBids=true
+//
+// Prices are in the minor units of the currency, which is required along with them.
+func itemFilter(r *http.Request) (models.ItemFilter, error) {
+	q := r.URL.Query()
+	f := models.ItemFilter{Category: q.Get("category"), Tags: q["tag"]}
+	ve := models.ValidationError{}
+
+	for field, price := range map[string]**models.Money{"minPrice": &f.MinPrice, "maxPrice": &f.MaxPrice} {
+		v := q.Get(field)
+		if v == "" {
+			continue
+		}
+
+		amount, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			ve[field] = models.ErrInvalid
+			continue
+		}
+		*price = &models.Money{Amount: amount, Currency: strings.ToUpper(q.Get("currency"))}
+	}
+
+	if v := q.Get("hasBids"); v != "" {
+		hasBids, err := strconv.ParseBool(v)
+		if err != nil {
+			ve["hasBids"] = models.ErrInvalid
+		}
+		f.HasBids = &hasBids
+	}
+
+	if len(ve) > 0 {
+		return f, ve
+	}
+	return f, nil
+}
+
+// searchLimit is the number of search results of a page unless the request asks for another.
+const searchLimit = 20
+
+// SearchItems searches the names and descriptions of the items for the words of the q query
+// parameter, returning a page of the results, the most relevant items first unless another sort is
+// asked for
+func (app *App) SearchItems(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	p, err := listPage(r)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+	if p.Limit == 0 {
+		p.Limit = searchLimit
+	}
+
+	results, err := app.Api.itemsvc.Search(r.URL.Query().Get("q"))
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	results, next, err := app.Api.pager.SearchResults(results, p)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, web.NewPage(r, views.PublicSearchResults(results), next), http.StatusOK)
+}
+
+// ListItemsBySellerID fetches all the items a user is selling
+func (app *App) ListItemsBySellerID(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	userID, ok := vars["userId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"userId": models.ErrRequired})
+		return
+	}
+
+	u, _ := strconv.ParseInt(userID, 10, 64)
+
+	p, err := listPage(r)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	items, err := app.Api.itemsvc.ListItemsBySellerID(u)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	items, next, err := app.Api.pager.Items(items, p)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
 
-	web.Respond(ctx, w, items, http.StatusOK)
+	web.Respond(ctx, w, web.NewPage(r, views.PublicItems(items), next), http.StatusOK)
 }
 
 func (app *App) CreateItem(w http.ResponseWriter, r *http.Request) {
@@ -36,34 +184,68 @@ func (app *App) CreateItem(w http.ResponseWriter, r *http.Request) {
 		app.Api.viewErr.JSON(ctx, w, err)
 		return
 	}
-	app.Api.itemsvc.TxCreate(&ni)
+	if err := app.Api.itemsvc.TxCreate(&ni); err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
 
 	web.Respond(ctx, w, ni, http.StatusCreated)
 }
 
-func (app *App) ListUsers(w http.ResponseWriter, r *http.Request) {
+// GetItem retrieves an item. An item ID must be provided in URL path
+func (app *App) GetItem(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	itemID, ok := vars["itemId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"itemId": models.ErrRequired})
+		return
+	}
+
+	i, _ := strconv.ParseInt(itemID, 10, 64)
 
-	users := app.Api.usersvc.ListUsers()
+	item, err := app.Api.itemsvc.Get(i)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"item": models.ErrNotFound})
+		return
+	}
 
-	web.Respond(ctx, w, users, http.StatusOK)
+	web.Respond(ctx, w, views.PublicItem(item), http.StatusOK)
 }
 
-func (app *App) CreateUser(w http.ResponseWriter, r *http.Request) {
+// EditItem changes the fields of an item given in the body, leaving the others as they are. An item
+// ID must be provided in URL path
+func (app *App) EditItem(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
-	var nu models.User
+	vars := mux.Vars(r)
 
-	if err := web.Decode(r, &nu); err != nil {
+	itemID, ok := vars["itemId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"itemId": models.ErrRequired})
+		return
+	}
+
+	i, _ := strconv.ParseInt(itemID, 10, 64)
+
+	var edit models.ItemEdit
+	if err := web.Decode(r, &edit); err != nil {
 		app.Api.viewErr.JSON(ctx, w, err)
 		return
 	}
-	app.Api.usersvc.TxCreate(&nu)
 
-	web.Respond(ctx, w, nu, http.StatusCreated)
+	item, err := app.Api.itemsvc.TxEdit(i, edit)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, views.PublicItem(item), http.StatusOK)
 }
 
-// ListBidsByItemID retrieves all the bids for a given item ID
-func (app *App) ListBidsByItemID(w http.ResponseWriter, r *http.Request) {
+// PublishItem publishes a draft item, which is then opened and closed on schedule. An item ID must be
+// provided in URL path
+func (app *App) PublishItem(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
 	vars := mux.Vars(r)
 
@@ -75,19 +257,18 @@ func (app *App) ListBidsByItemID(w http.ResponseWriter, r *http.Request) {
 
 	i, _ := strconv.ParseInt(itemID, 10, 64)
 
-	var err error
-	var bids []models.Bid // verbose declaration to let you see in a glance that we are using a list here
-	bids, err = app.Api.bidsvc.ListBidsByItemID(i)
+	item, err := app.Api.itemsvc.TxPublish(i)
 	if err != nil {
 		app.Api.viewErr.JSON(ctx, w, err)
 		return
 	}
 
-	web.Respond(ctx, w, bids, http.StatusCreated)
+	web.Respond(ctx, w, views.PublicItem(item), http.StatusOK)
 }
 
-// GetWinningBid gets the winning bid (highest current bid) for a given item ID
-func (app *App) GetWinningBid(w http.ResponseWriter, r *http.Request) {
+// WithdrawItem deletes an item, or cancels its auction if it has bids, responding with the cancelled
+// item. An item ID must be provided in URL path
+func (app *App) WithdrawItem(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
 	vars := mux.Vars(r)
 
@@ -99,17 +280,88 @@ func (app *App) GetWinningBid(w http.ResponseWriter, r *http.Request) {
 
 	i, _ := strconv.ParseInt(itemID, 10, 64)
 
-	bid, err := app.Api.bidsvc.GetWinningBid(i)
+	item, err := app.Api.itemsvc.TxWithdraw(i)
 	if err != nil {
 		app.Api.viewErr.JSON(ctx, w, err)
 		return
 	}
 
-	web.Respond(ctx, w, bid, http.StatusOK)
+	if item.State != models.ItemCancelled {
+		web.Respond(ctx, w, nil, http.StatusNoContent)
+		return
+	}
+
+	web.Respond(ctx, w, views.PublicItem(item), http.StatusOK)
 }
 
-// ListBetItemsByUserID fetches all the items on which the user has a bid
-func (app *App) ListBetItemsByUserID(w http.ResponseWriter, r *http.Request) {
+func (app *App) ListUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	p, err := listPage(r)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	users, next, err := app.Api.pager.Users(app.Api.usersvc.ListUsers(), p)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, web.NewPage(r, users, next), http.StatusOK)
+}
+
+func (app *App) CreateUser(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	var nu models.User
+
+	if err := web.Decode(r, &nu); err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+	app.Api.usersvc.TxCreate(&nu)
+
+	web.Respond(ctx, w, nu, http.StatusCreated)
+}
+
+// ListBidsByItemID retrieves all the bids for a given item ID
+func (app *App) ListBidsByItemID(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	itemID, ok := vars["itemId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"itemId": models.ErrRequired})
+		return
+	}
+
+	i, _ := strconv.ParseInt(itemID, 10, 64)
+
+	p, err := listPage(r)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	var bids []models.Bid // verbose declaration to let you see in a glance that we are using a list here
+	bids, err = app.Api.bidsvc.ListBidsByItemID(i)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	bids, next, err := app.Api.pager.Bids(bids, p)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, web.NewPage(r, bids, next), http.StatusOK)
+}
+
+// ListBidsByUserID retrieves all the bids placed by a user. A user ID must be provided in URL path
+func (app *App) ListBidsByUserID(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
 	vars := mux.Vars(r)
 
@@ -121,32 +373,30 @@ func (app *App) ListBetItemsByUserID(w http.ResponseWriter, r *http.Request) {
 
 	u, _ := strconv.ParseInt(userID, 10, 64)
 
-	bids, err := app.Api.bidsvc.ListBidsByUserID(u)
+	p, err := listPage(r)
 	if err != nil {
 		app.Api.viewErr.JSON(ctx, w, err)
 		return
 	}
 
-	keys := make(map[int64]bool)
-	var itemIDs []int64
-	for _, v := range bids {
-		if _, ok := keys[v.ItemID]; !ok {
-			keys[v.ItemID] = true
-			itemIDs = append(itemIDs, v.ItemID)
-		}
+	bids, err := app.Api.bidsvc.ListBidsByUserID(u)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
 	}
 
-	items, err := app.Api.itemsvc.ListItemsByIDs(itemIDs...)
+	bids, next, err := app.Api.pager.Bids(bids, p)
 	if err != nil {
 		app.Api.viewErr.JSON(ctx, w, err)
 		return
 	}
 
-	web.Respond(ctx, w, items, http.StatusOK)
+	web.Respond(ctx, w, web.NewPage(r, bids, next), http.StatusOK)
 }
 
-// CreateBid allows to bid. An item ID and user ID must be provided in URL path
-func (app *App) CreateBid(w http.ResponseWriter, r *http.Request) {
+// GetWinningBid gets the winning bid (highest current bid) for a given item ID, telling whether it
+// meets the reserve price of the item without disclosing it
+func (app *App) GetWinningBid(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
 	vars := mux.Vars(r)
 
@@ -156,31 +406,737 @@ func (app *App) CreateBid(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, ok := vars["userId"]
-	if !ok {
-		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"userId": models.ErrRequired})
+	i, _ := strconv.ParseInt(itemID, 10, 64)
+
+	bid, err := app.Api.bidsvc.GetWinningBid(i)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
 		return
 	}
 
-	var nb models.Bid
-	if err := web.Decode(r, &nb); err != nil {
+	item, err := app.Api.itemsvc.Get(i)
+	if err != nil {
 		app.Api.viewErr.JSON(ctx, w, err)
 		return
 	}
 
+	web.Respond(ctx, w, views.NewWinningBid(bid, item), http.StatusOK)
+}
+
+// GetWinningBids gets the allocation of the units of a multi-unit item to its winning bids, along
+// with the uniform price every winner pays
+func (app *App) GetWinningBids(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	itemID, ok := vars["itemId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"itemId": models.ErrRequired})
+		return
+	}
+
 	i, _ := strconv.ParseInt(itemID, 10, 64)
-	u, _ := strconv.ParseInt(userID, 10, 64)
 
-	bid := models.Bid{
-		ItemID: i,
-		UserID: u,
-		Amount: nb.Amount,
+	allocs, err := app.Api.bidsvc.GetWinningBids(i)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
 	}
-	err := app.Api.bidsvc.TxCreate(&bid)
+
+	item, err := app.Api.itemsvc.Get(i)
 	if err != nil {
 		app.Api.viewErr.JSON(ctx, w, err)
 		return
 	}
 
-	web.Respond(ctx, w, bid, http.StatusCreated)
+	web.Respond(ctx, w, views.NewWinners(allocs, item), http.StatusOK)
+}
+
+// streamHeartbeat is the time after which an idle stream gets a comment, so the connection is not
+// closed along the way.
+const streamHeartbeat = 15 * time.Second
+
+// StreamBids streams the bids placed on an item and the changes of its winning bid as Server-Sent
+// Events. An item ID must be provided in URL path, and the stream resumes after the bid given in the
+// Last-Event-ID header
+func (app *App) StreamBids(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	itemID, ok := vars["itemId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"itemId": models.ErrRequired})
+		return
+	}
+
+	var lastID int64
+	if h := r.Header.Get("Last-Event-ID"); h != "" {
+		var err error
+		if lastID, err = strconv.ParseInt(h, 10, 64); err != nil || lastID < 0 {
+			app.Api.viewErr.JSON(ctx, w, models.ValidationError{"lastEventId": models.ErrInvalid})
+			return
+		}
+	}
+
+	i, _ := strconv.ParseInt(itemID, 10, 64)
+
+	stream, err := app.Api.bidsvc.StreamBids(i, lastID)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+	defer stream.Close()
+
+	if err := web.StartEvents(w); err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	// the stream ends when the client goes away
+	for {
+		next, cancel := context.WithTimeout(r.Context(), streamHeartbeat)
+		e, err := stream.Next(next)
+		cancel()
+
+		switch {
+		case r.Context().Err() != nil:
+			return
+		case err == context.DeadlineExceeded:
+			err = web.SendComment(w, "heartbeat")
+		case err != nil:
+			app.Api.log.Printf("handlers : streaming the bids of item %d : %v", i, err)
+			return
+		case e.Type == models.BidEventWinner:
+			err = web.SendEvent(w, "", string(e.Type), views.NewWinningBid(e.Bid, e.Item))
+		default:
+			err = web.SendEvent(w, strconv.FormatInt(e.Bid.ID, 10), string(e.Type), e.Bid)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// ListBetItemsByUserID fetches all the items on which the user has a bid
+func (app *App) ListBetItemsByUserID(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	userID, ok := vars["userId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"userId": models.ErrRequired})
+		return
+	}
+
+	u, _ := strconv.ParseInt(userID, 10, 64)
+
+	p, err := listPage(r)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	bids, err := app.Api.bidsvc.ListBidsByUserID(u)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	keys := make(map[int64]bool)
+	var itemIDs []int64
+	for _, v := range bids {
+		if _, ok := keys[v.ItemID]; !ok {
+			keys[v.ItemID] = true
+			itemIDs = append(itemIDs, v.ItemID)
+		}
+	}
+
+	items, err := app.Api.itemsvc.ListItemsByIDs(itemIDs...)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	items, next, err := app.Api.pager.Items(items, p)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, web.NewPage(r, views.PublicItems(items), next), http.StatusOK)
+}
+
+// CreateBid allows to bid. An item ID and user ID must be provided in URL path. The response includes
+// the end time of the item, which may have been pushed back by the bid
+func (app *App) CreateBid(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	itemID, ok := vars["itemId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"itemId": models.ErrRequired})
+		return
+	}
+
+	userID, ok := vars["userId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"userId": models.ErrRequired})
+		return
+	}
+
+	var nb models.Bid
+	if err := web.Decode(r, &nb); err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	i, _ := strconv.ParseInt(itemID, 10, 64)
+	u, _ := strconv.ParseInt(userID, 10, 64)
+
+	bid := models.Bid{
+		ItemID:   i,
+		UserID:   u,
+		Amount:   nb.Amount,
+		Quantity: nb.Quantity,
+	}
+	err := app.Api.bidsvc.TxCreate(&bid)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	item, err := app.Api.itemsvc.Get(i)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, views.CreatedBid{Bid: bid, ItemEndsAt: item.EndsAt}, http.StatusCreated)
+}
+
+// CreateProxyBid registers the maximum amount a user is willing to pay for an item, so bids are
+// placed automatically on their behalf. An item ID and user ID must be provided in URL path
+func (app *App) CreateProxyBid(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	itemID, ok := vars["itemId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"itemId": models.ErrRequired})
+		return
+	}
+
+	userID, ok := vars["userId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"userId": models.ErrRequired})
+		return
+	}
+
+	var np models.ProxyBid
+	if err := web.Decode(r, &np); err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	i, _ := strconv.ParseInt(itemID, 10, 64)
+	u, _ := strconv.ParseInt(userID, 10, 64)
+
+	proxy := models.ProxyBid{
+		ItemID:    i,
+		UserID:    u,
+		MaxAmount: np.MaxAmount,
+	}
+	err := app.Api.bidsvc.TxCreateProxy(&proxy)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, proxy, http.StatusCreated)
+}
+
+// BuyNow buys an item at its buy-now price, closing the auction straight away. An item ID and user ID
+// must be provided in URL path
+func (app *App) BuyNow(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	itemID, ok := vars["itemId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"itemId": models.ErrRequired})
+		return
+	}
+
+	userID, ok := vars["userId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"userId": models.ErrRequired})
+		return
+	}
+
+	i, _ := strconv.ParseInt(itemID, 10, 64)
+	u, _ := strconv.ParseInt(userID, 10, 64)
+
+	bid := models.Bid{
+		ItemID: i,
+		UserID: u,
+	}
+	err := app.Api.bidsvc.TxBuyNow(&bid)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, bid, http.StatusCreated)
+}
+
+// AcceptPrice accepts the current price of a Dutch auction, closing it straight away. An item ID and
+// user ID must be provided in URL path
+func (app *App) AcceptPrice(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	itemID, ok := vars["itemId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"itemId": models.ErrRequired})
+		return
+	}
+
+	userID, ok := vars["userId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"userId": models.ErrRequired})
+		return
+	}
+
+	i, _ := strconv.ParseInt(itemID, 10, 64)
+	u, _ := strconv.ParseInt(userID, 10, 64)
+
+	bid := models.Bid{
+		ItemID: i,
+		UserID: u,
+	}
+	err := app.Api.bidsvc.TxAccept(&bid)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, bid, http.StatusCreated)
+}
+
+// RetractBid withdraws a bid on behalf of the user who placed it. A user ID and bid ID must be provided
+// in URL path, and a reason in the body
+func (app *App) RetractBid(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	userID, ok := vars["userId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"userId": models.ErrRequired})
+		return
+	}
+
+	bidID, ok := vars["bidId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"bidId": models.ErrRequired})
+		return
+	}
+
+	var nb models.Bid
+	if err := web.Decode(r, &nb); err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	u, _ := strconv.ParseInt(userID, 10, 64)
+	b, _ := strconv.ParseInt(bidID, 10, 64)
+
+	bid := models.Bid{
+		ID:     b,
+		UserID: u,
+		Reason: nb.Reason,
+	}
+	err := app.Api.bidsvc.TxRetract(&bid)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, bid, http.StatusOK)
+}
+
+// VoidBid cancels a bid as an administrator. A bid ID must be provided in URL path, and a reason in
+// the body
+func (app *App) VoidBid(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	bidID, ok := vars["bidId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"bidId": models.ErrRequired})
+		return
+	}
+
+	var nb models.Bid
+	if err := web.Decode(r, &nb); err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	b, _ := strconv.ParseInt(bidID, 10, 64)
+
+	bid := models.Bid{
+		ID:     b,
+		Reason: nb.Reason,
+	}
+	err := app.Api.bidsvc.TxVoid(&bid)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, bid, http.StatusOK)
+}
+
+// GetWallet gets the balances of a user and the funds held by their bids. A user ID must be provided
+// in URL path
+func (app *App) GetWallet(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	userID, ok := vars["userId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"userId": models.ErrRequired})
+		return
+	}
+
+	u, _ := strconv.ParseInt(userID, 10, 64)
+
+	wallet, err := app.Api.walletsvc.Get(u)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, wallet, http.StatusOK)
+}
+
+// Deposit adds money to the wallet of a user. A user ID must be provided in URL path, and an amount
+// in the body
+func (app *App) Deposit(w http.ResponseWriter, r *http.Request) {
+	app.transfer(w, r, app.Api.walletsvc.TxDeposit)
+}
+
+// Withdraw takes money out of the wallet of a user, up to their available balance. A user ID must be
+// provided in URL path, and an amount in the body
+func (app *App) Withdraw(w http.ResponseWriter, r *http.Request) {
+	app.transfer(w, r, app.Api.walletsvc.TxWithdraw)
+}
+
+// transfer applies the transfer fn described by the request and responds with the updated wallet
+func (app *App) transfer(w http.ResponseWriter, r *http.Request, fn func(*models.Transfer) error) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	userID, ok := vars["userId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"userId": models.ErrRequired})
+		return
+	}
+
+	var nt models.Transfer
+	if err := web.Decode(r, &nt); err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	u, _ := strconv.ParseInt(userID, 10, 64)
+
+	transfer := models.Transfer{
+		UserID: u,
+		Amount: nt.Amount,
+	}
+	if err := fn(&transfer); err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	wallet, err := app.Api.walletsvc.Get(u)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, wallet, http.StatusOK)
+}
+
+// GetOrder gets the order issued when an item was settled, with the fees and the payment status of
+// every winning bid. An item ID must be provided in URL path
+func (app *App) GetOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	itemID, ok := vars["itemId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"itemId": models.ErrRequired})
+		return
+	}
+
+	i, _ := strconv.ParseInt(itemID, 10, 64)
+
+	order, err := app.Api.settlesvc.GetOrderByItemID(i)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, order, http.StatusOK)
+}
+
+// ListInvoicesByUserID lists the invoices of a user, as a buyer and as a seller. A user ID must be
+// provided in URL path
+func (app *App) ListInvoicesByUserID(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	userID, ok := vars["userId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"userId": models.ErrRequired})
+		return
+	}
+
+	p, err := listPage(r)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	u, _ := strconv.ParseInt(userID, 10, 64)
+
+	invoices, err := app.Api.settlesvc.ListInvoicesByUserID(u)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	invoices, next, err := app.Api.pager.Invoices(invoices, p)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, web.NewPage(r, invoices, next), http.StatusOK)
+}
+
+// ListOffersByUserID lists the second-chance offers made to a user. A user ID must be provided in URL
+// path
+func (app *App) ListOffersByUserID(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	userID, ok := vars["userId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"userId": models.ErrRequired})
+		return
+	}
+
+	p, err := listPage(r)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	u, _ := strconv.ParseInt(userID, 10, 64)
+
+	offers, err := app.Api.settlesvc.ListOffersByUserID(u)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	offers, next, err := app.Api.pager.Offers(offers, p)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, web.NewPage(r, offers, next), http.StatusOK)
+}
+
+// AcceptOffer takes up a second-chance offer, adding the units to the order of the item. A user ID and
+// offer ID must be provided in URL path
+func (app *App) AcceptOffer(w http.ResponseWriter, r *http.Request) {
+	app.answerOffer(w, r, app.Api.settlesvc.TxAcceptOffer)
+}
+
+// DeclineOffer turns down a second-chance offer, so it is made to the next bidder. A user ID and offer
+// ID must be provided in URL path
+func (app *App) DeclineOffer(w http.ResponseWriter, r *http.Request) {
+	app.answerOffer(w, r, app.Api.settlesvc.TxDeclineOffer)
+}
+
+// answerOffer applies the answer fn to the offer of the request and responds with the updated offer
+func (app *App) answerOffer(w http.ResponseWriter, r *http.Request, fn func(*models.Offer) error) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	userID, ok := vars["userId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"userId": models.ErrRequired})
+		return
+	}
+
+	offerID, ok := vars["offerId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"offerId": models.ErrRequired})
+		return
+	}
+
+	u, _ := strconv.ParseInt(userID, 10, 64)
+	o, _ := strconv.ParseInt(offerID, 10, 64)
+
+	offer := models.Offer{
+		ID:     o,
+		UserID: u,
+	}
+	if err := fn(&offer); err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, offer, http.StatusOK)
+}
+
+// ListWatchlist lists the items followed by a user, with their winning bid and whether the user leads
+// the bidding. A user ID must be provided in URL path
+func (app *App) ListWatchlist(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	userID, ok := vars["userId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"userId": models.ErrRequired})
+		return
+	}
+
+	p, err := listPage(r)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	u, _ := strconv.ParseInt(userID, 10, 64)
+
+	entries, err := app.Api.watchsvc.ListWatchlist(u)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	entries, next, err := app.Api.pager.Watchlist(entries, p)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, web.NewPage(r, views.PublicWatchlist(entries), next), http.StatusOK)
+}
+
+// Watch adds an item to the watchlist of a user. A user ID and item ID must be provided in URL path
+func (app *App) Watch(w http.ResponseWriter, r *http.Request) {
+	app.watch(w, r, app.Api.watchsvc.TxWatch, http.StatusOK)
+}
+
+// Unwatch removes an item from the watchlist of a user. A user ID and item ID must be provided in URL
+// path
+func (app *App) Unwatch(w http.ResponseWriter, r *http.Request) {
+	app.watch(w, r, app.Api.watchsvc.TxUnwatch, http.StatusNoContent)
+}
+
+// watch applies fn to the watch described by the request and responds with it
+func (app *App) watch(w http.ResponseWriter, r *http.Request, fn func(*models.Watch) error, status int) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	userID, ok := vars["userId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"userId": models.ErrRequired})
+		return
+	}
+
+	itemID, ok := vars["itemId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"itemId": models.ErrRequired})
+		return
+	}
+
+	u, _ := strconv.ParseInt(userID, 10, 64)
+	i, _ := strconv.ParseInt(itemID, 10, 64)
+
+	watch := models.Watch{
+		UserID: u,
+		ItemID: i,
+	}
+	if err := fn(&watch); err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, watch, status)
+}
+
+// GetNotificationPreferences gets how a user wants to be notified. A user ID must be provided in URL
+// path
+func (app *App) GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	userID, ok := vars["userId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"userId": models.ErrRequired})
+		return
+	}
+
+	u, _ := strconv.ParseInt(userID, 10, 64)
+
+	prefs, err := app.Api.notifysvc.GetPreferences(u)
+	if err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, prefs, http.StatusOK)
+}
+
+// SetNotificationPreferences replaces how a user wants to be notified. A user ID must be provided in URL
+// path
+func (app *App) SetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	userID, ok := vars["userId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"userId": models.ErrRequired})
+		return
+	}
+
+	var np models.NotificationPreferences
+	if err := web.Decode(r, &np); err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	u, _ := strconv.ParseInt(userID, 10, 64)
+
+	prefs := models.NotificationPreferences{
+		UserID:     u,
+		Email:      np.Email,
+		WebhookURL: np.WebhookURL,
+		Events:     np.Events,
+	}
+	if err := app.Api.notifysvc.TxSetPreferences(&prefs); err != nil {
+		app.Api.viewErr.JSON(ctx, w, err)
+		return
+	}
+
+	web.Respond(ctx, w, prefs, http.StatusOK)
 }
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/noelruault/auction-bid-tracker/internal/models"
+	"github.com/noelruault/auction-bid-tracker/internal/views"
+	"github.com/noelruault/auction-bid-tracker/internal/web"
+)
+
+const (
+	// livePing is the interval at which the clients of the live channel are pinged, well within the
+	// time they may stay silent.
+	livePing = web.WSReadTimeout / 2
+	// liveMaxSubscriptions is the number of items a client of the live channel may follow at once,
+	// each of them being followed by a goroutine of its own.
+	liveMaxSubscriptions = 50
+)
+
+// liveCommand is a message sent by a client of the live channel. ID is echoed back in the reply.
+//
+//	{"type": "bid", "id": "1", "itemId": 1, "amount": {"amount": 1050, "currency": "GBP"}}
+//	{"type": "subscribe", "id": "2", "itemId": 1}
+//	{"type": "unsubscribe", "id": "3", "itemId": 1}
+type liveCommand struct {
+	Type     string       `json:"type"`
+	ID       string       `json:"id"`
+	ItemID   int64        `json:"itemId"`
+	Amount   models.Money `json:"amount"`
+	Quantity int          `json:"quantity"`
+}
+
+// liveMessage is a message sent to a client of the live channel: the reply to a command, or a new
+// winning bid of an item it subscribed to.
+type liveMessage struct {
+	Type   string                 `json:"type"`
+	ID     string                 `json:"id,omitempty"`
+	ItemID int64                  `json:"itemId,omitempty"`
+	Bid    *views.CreatedBid      `json:"bid,omitempty"`
+	Winner *views.WinningBid      `json:"winner,omitempty"`
+	Error  map[string]interface{} `json:"error,omitempty"`
+}
+
+// Live opens a WebSocket channel on which a user places bids, and receives the changes of the winning
+// bid of the items they subscribe to. A user ID must be provided in URL path
+func (app *App) Live(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	vars := mux.Vars(r)
+
+	userID, ok := vars["userId"]
+	if !ok {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"userId": models.ErrRequired})
+		return
+	}
+
+	u, _ := strconv.ParseInt(userID, 10, 64)
+
+	if _, err := app.Api.usersvc.Get(u); err != nil {
+		app.Api.viewErr.JSON(ctx, w, models.ValidationError{"user": models.ErrNotFound})
+		return
+	}
+
+	// Upgrade responds to invalid handshakes itself
+	conn, err := web.Upgrade(w, r, app.Api.origins)
+	if err != nil {
+		return
+	}
+
+	s := liveSession{
+		app:    app,
+		conn:   conn,
+		userID: u,
+		subs:   make(map[int64]*liveSubscription),
+		ended:  make(chan *liveSubscription, liveMaxSubscriptions),
+	}
+	s.run()
+}
+
+// liveSession is the live channel of a user. Its commands are handled one at a time, in the order they
+// come, while the winning bids of the subscriptions are pushed in the background.
+type liveSession struct {
+	app    *App
+	conn   *web.WSConn
+	userID int64
+
+	// subs are the subscriptions to every item, and ended receives those whose stream is over
+	subs  map[int64]*liveSubscription
+	ended chan *liveSubscription
+}
+
+// liveSubscription pushes the winning bids of an item to the client until it is cancelled.
+type liveSubscription struct {
+	itemID int64
+	cancel context.CancelFunc
+}
+
+func (s *liveSession) run() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer s.conn.Close()
+
+	go s.ping(ctx)
+
+	for {
+		msg, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		// the subscriptions may have ended while waiting for the command, and must not count anymore
+		s.drop()
+
+		var cmd liveCommand
+		if err := web.DecodeMessage(msg, &cmd); err != nil {
+			s.fail(cmd, err)
+			continue
+		}
+
+		switch cmd.Type {
+		case "bid":
+			s.bid(cmd)
+		case "subscribe":
+			s.subscribe(ctx, cmd)
+		case "unsubscribe":
+			s.unsubscribe(cmd)
+		default:
+			s.fail(cmd, models.ValidationError{"type": models.ErrInvalid})
+		}
+	}
+}
+
+// ping pings the client until ctx is done.
+func (s *liveSession) ping(ctx context.Context) {
+	ticker := time.NewTicker(livePing)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := s.conn.Ping(); err != nil {
+			return
+		}
+	}
+}
+
+// bid places a bid on behalf of the user, as CreateBid does.
+func (s *liveSession) bid(cmd liveCommand) {
+	bid := models.Bid{
+		ItemID:   cmd.ItemID,
+		UserID:   s.userID,
+		Amount:   cmd.Amount,
+		Quantity: cmd.Quantity,
+	}
+	if err := s.app.Api.bidsvc.TxCreate(&bid); err != nil {
+		s.fail(cmd, err)
+		return
+	}
+
+	item, err := s.app.Api.itemsvc.Get(cmd.ItemID)
+	if err != nil {
+		s.fail(cmd, err)
+		return
+	}
+
+	s.conn.WriteJSON(liveMessage{
+		Type:   "bid",
+		ID:     cmd.ID,
+		ItemID: cmd.ItemID,
+		Bid:    &views.CreatedBid{Bid: bid, ItemEndsAt: item.EndsAt},
+	})
+}
+
+// subscribe pushes the winning bid of the item to the client every time it changes, starting with
+// the current one, until the subscription is cancelled or ctx is done. A client follows up to
+// liveMaxSubscriptions items at once.
+func (s *liveSession) subscribe(ctx context.Context, cmd liveCommand) {
+	if _, ok := s.subs[cmd.ItemID]; ok {
+		s.conn.WriteJSON(liveMessage{Type: "subscribed", ID: cmd.ID, ItemID: cmd.ItemID})
+		return
+	}
+
+	if len(s.subs) >= liveMaxSubscriptions {
+		s.fail(cmd, models.ValidationError{"itemId": models.ErrTooMany})
+		return
+	}
+
+	stream, err := s.app.Api.bidsvc.StreamBids(cmd.ItemID, 0)
+	if err != nil {
+		s.fail(cmd, err)
+		return
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	sub := &liveSubscription{itemID: cmd.ItemID, cancel: cancel}
+	s.subs[cmd.ItemID] = sub
+
+	// the reply is sent before the first winning bid
+	s.conn.WriteJSON(liveMessage{Type: "subscribed", ID: cmd.ID, ItemID: cmd.ItemID})
+
+	go func() {
+		defer stream.Close()
+
+		// the subscription is dropped by run, which owns them, unless the whole session is over
+		defer func() {
+			select {
+			case s.ended <- sub:
+			case <-ctx.Done():
+			}
+		}()
+
+		for {
+			e, err := stream.Next(subCtx)
+			if err != nil {
+				if subCtx.Err() == nil {
+					s.app.Api.log.Printf("handlers : live winning bids of item %d : %v", cmd.ItemID, err)
+				}
+				return
+			}
+
+			if e.Type != models.BidEventWinner {
+				continue
+			}
+
+			winner := views.NewWinningBid(e.Bid, e.Item)
+			if err := s.conn.WriteJSON(liveMessage{Type: "winner", ItemID: cmd.ItemID, Winner: &winner}); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+func (s *liveSession) unsubscribe(cmd liveCommand) {
+	if sub, ok := s.subs[cmd.ItemID]; ok {
+		sub.cancel()
+		delete(s.subs, cmd.ItemID)
+	}
+
+	s.conn.WriteJSON(liveMessage{Type: "unsubscribed", ID: cmd.ID, ItemID: cmd.ItemID})
+}
+
+// drop forgets the subscriptions whose stream ended, unless the item was subscribed to again since.
+func (s *liveSession) drop() {
+	for {
+		select {
+		case sub := <-s.ended:
+			if s.subs[sub.itemID] == sub {
+				sub.cancel()
+				delete(s.subs, sub.itemID)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// fail replies to cmd with err, in the same form as the errors of the REST API.
+func (s *liveSession) fail(cmd liveCommand, err error) {
+	_, data := s.app.Api.viewErr.Body(err)
+	s.conn.WriteJSON(liveMessage{Type: "error", ID: cmd.ID, ItemID: cmd.ItemID, Error: data})
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/noelruault/auction-bid-tracker/internal/models"
+)
+
+// testServer serves the API on top of db, which must be set up before as the services read its clock
+// when they are built, with the admin token and origins given.
+func testServer(t *testing.T, db *models.DB, adminToken string, origins ...string) *httptest.Server {
+	app := &App{
+		Router: mux.NewRouter().StrictSlash(true),
+		Api:    NewAPI(db, log.New(ioutil.Discard, "", 0), models.NewSettlementService(db, models.NewUserService(db)), origins, adminToken),
+	}
+	app.SetupRouter()
+
+	srv := httptest.NewServer(app.Router)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// dialLive opens the live channel of the user at path, with the origin given if not empty. It returns
+// the response to the handshake, and the connection once it is upgraded.
+func dialLive(t *testing.T, srv *httptest.Server, path, origin string) (*http.Response, net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req := fmt.Sprintf("GET %s HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n", path, srv.Listener.Addr())
+	if origin != "" {
+		req += "Origin: " + origin + "\r\n"
+	}
+	_, err = conn.Write([]byte(req + "\r\n"))
+	assert.NoError(t, err)
+
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return res, conn, br
+}
+
+// sendLive sends v as a masked text message of the client, in a single frame.
+func sendLive(t *testing.T, conn net.Conn, v interface{}) {
+	payload, err := json.Marshal(v)
+	assert.NoError(t, err)
+
+	b := []byte{0x81, 0x80}
+	switch n := len(payload); {
+	case n < 126:
+		b[1] |= byte(n)
+	default:
+		b[1] |= 126
+		b = append(b, byte(n>>8), byte(n))
+	}
+
+	// a zero mask leaves the payload as it is
+	b = append(b, 0, 0, 0, 0)
+	_, err = conn.Write(append(b, payload...))
+	assert.NoError(t, err)
+}
+
+// readLive reads the next message of the server, skipping the pings.
+func readLive(t *testing.T, r io.Reader) map[string]interface{} {
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(r, head[:]); !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		size := int(head[1] & 0x7f)
+		if size == 126 {
+			var ext [2]byte
+			io.ReadFull(r, ext[:])
+			size = int(ext[0])<<8 | int(ext[1])
+		}
+
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		if head[0]&0x0f != 0x1 {
+			continue
+		}
+
+		var msg map[string]interface{}
+		assert.NoError(t, json.Unmarshal(payload, &msg))
+		return msg
+	}
+}
+
+func TestLive_Origin(t *testing.T) {
+	db := models.CreateDatabase()
+	srv := testServer(t, db, "", "https://allowed.example.com")
+	models.NewUserService(db).TxCreate(&models.User{Name: "Morty"})
+
+	var cases = []struct {
+		name      string
+		origin    string
+		outstatus int
+	}{
+		{"no origin", "", http.StatusSwitchingProtocols},
+		{"same origin", srv.URL, http.StatusSwitchingProtocols},
+		{"allowed origin", "https://allowed.example.com", http.StatusSwitchingProtocols},
+		{"other origin", "https://evil.example.com", http.StatusForbidden},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			res, _, _ := dialLive(t, srv, "/users/1/live", tt.origin)
+			assert.Equal(t, tt.outstatus, res.StatusCode)
+		})
+	}
+}
+
+func TestLive_SubscriptionLimit(t *testing.T) {
+	db := models.CreateDatabase()
+	srv := testServer(t, db, "")
+
+	usvc := models.NewUserService(db)
+	isvc := models.NewItemService(db, usvc)
+	usvc.TxCreate(&models.User{Name: "Morty"})
+	usvc.TxCreate(&models.User{Name: "Rick"})
+
+	for n := 0; n <= liveMaxSubscriptions; n++ {
+		item := models.Item{Name: "plumbus", SellerID: 2, Value: models.Money{Amount: 10, Currency: "GBP"}}
+		assert.NoError(t, isvc.TxCreate(&item))
+	}
+
+	res, conn, br := dialLive(t, srv, "/users/1/live", "")
+	if !assert.Equal(t, http.StatusSwitchingProtocols, res.StatusCode) {
+		return
+	}
+
+	for n := 1; n <= liveMaxSubscriptions; n++ {
+		sendLive(t, conn, map[string]interface{}{"type": "subscribe", "id": fmt.Sprint(n), "itemId": n})
+		assert.Equal(t, "subscribed", readLive(t, br)["type"])
+	}
+
+	// the items already followed do not count twice
+	sendLive(t, conn, map[string]interface{}{"type": "subscribe", "id": "again", "itemId": 1})
+	assert.Equal(t, "subscribed", readLive(t, br)["type"])
+
+	sendLive(t, conn, map[string]interface{}{"type": "subscribe", "id": "over", "itemId": liveMaxSubscriptions + 1})
+	msg := readLive(t, br)
+	assert.Equal(t, "error", msg["type"])
+	assert.Equal(t, "over", msg["id"])
+	assert.Contains(t, fmt.Sprint(msg["error"]), "too_many")
+
+	// unsubscribing makes room for another item
+	sendLive(t, conn, map[string]interface{}{"type": "unsubscribe", "id": "u", "itemId": 1})
+	assert.Equal(t, "unsubscribed", readLive(t, br)["type"])
+
+	sendLive(t, conn, map[string]interface{}{"type": "subscribe", "id": "room", "itemId": liveMaxSubscriptions + 1})
+	assert.Equal(t, "subscribed", readLive(t, br)["type"])
+}
+
+func TestLiveSession_Drop(t *testing.T) {
+	s := liveSession{
+		subs:  make(map[int64]*liveSubscription),
+		ended: make(chan *liveSubscription, liveMaxSubscriptions),
+	}
+
+	cancelled := map[*liveSubscription]bool{}
+	subscribe := func(itemID int64) *liveSubscription {
+		sub := &liveSubscription{itemID: itemID}
+		sub.cancel = func() { cancelled[sub] = true }
+		s.subs[itemID] = sub
+		return sub
+	}
+
+	first := subscribe(1)
+	old := subscribe(2)
+	second := subscribe(2) // the item was subscribed to again before the old stream was over
+
+	// the subscriptions whose stream is over stop counting, but not the newer ones of the same item
+	s.ended <- first
+	s.ended <- old
+	s.drop()
+
+	assert.Equal(t, map[int64]*liveSubscription{2: second}, s.subs)
+	assert.True(t, cancelled[first])
+	assert.False(t, cancelled[second])
+}
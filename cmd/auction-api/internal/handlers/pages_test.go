@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/noelruault/auction-bid-tracker/internal/models"
+)
+
+// getPage gets the page of the list at path, returning the identification numbers read by id from its
+// elements, along with the path of the next page given by the Link header.
+func getPage(t *testing.T, srv *httptest.Server, path string, id func(map[string]interface{}) float64) ([]int64, string) {
+	res, err := http.Get(srv.URL + path)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer res.Body.Close()
+	if !assert.Equal(t, http.StatusOK, res.StatusCode, path) {
+		t.FailNow()
+	}
+
+	var page struct {
+		Data []map[string]interface{} `json:"data"`
+		Next string                   `json:"next"`
+	}
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&page))
+
+	var ids []int64
+	for _, e := range page.Data {
+		ids = append(ids, int64(id(e)))
+	}
+
+	var next string
+	if link := res.Header.Get("Link"); link != "" {
+		next = link[1 : len(link)-len(`>; rel="next"`)]
+		assert.NotEmpty(t, page.Next)
+	}
+	return ids, next
+}
+
+// getPages gets every page of the list at path, one after the other.
+func getPages(t *testing.T, srv *httptest.Server, path string, id func(map[string]interface{}) float64) [][]int64 {
+	var ret [][]int64
+	for path != "" {
+		var ids []int64
+		ids, path = getPage(t, srv, path, id)
+		ret = append(ret, ids)
+	}
+	return ret
+}
+
+func field(name string) func(map[string]interface{}) float64 {
+	return func(e map[string]interface{}) float64 {
+		v, _ := e[name].(float64)
+		return v
+	}
+}
+
+func itemField(e map[string]interface{}) float64 {
+	item, _ := e["item"].(map[string]interface{})
+	return field("id")(item)
+}
+
+func TestPages(t *testing.T) {
+	now := time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)
+	db := models.CreateDatabase()
+	db.SetClock(models.ClockFunc(func() time.Time { return now }))
+	srv := testServer(t, db, "")
+
+	usvc := models.NewUserService(db)
+	isvc := models.NewItemService(db, usvc)
+	bsvc := models.NewBidService(db, isvc, usvc)
+	wsvc := models.NewWatchlistService(db, isvc, usvc, bsvc)
+	asvc := models.NewAuctionService(db)
+	ssvc := models.NewSettlementService(db, usvc, models.WithPaymentDeadline(time.Hour), models.WithOfferWindow(time.Hour))
+
+	for _, name := range []string{"Morty", "Rick", "Summer"} {
+		usvc.TxCreate(&models.User{Name: name})
+		now = now.Add(time.Minute)
+	}
+
+	endsAt := now.Add(time.Hour)
+	for n := 0; n < 3; n++ {
+		item := models.Item{Name: "plumbus", SellerID: 3, Value: models.Money{Amount: 10, Currency: "GBP"}, EndsAt: endsAt}
+		assert.NoError(t, isvc.TxCreate(&item))
+		assert.NoError(t, bsvc.TxCreate(&models.Bid{UserID: 2, ItemID: item.ID, Amount: models.Money{Amount: 20, Currency: "GBP"}}))
+		assert.NoError(t, bsvc.TxCreate(&models.Bid{UserID: 1, ItemID: item.ID, Amount: models.Money{Amount: 30, Currency: "GBP"}}))
+		assert.NoError(t, wsvc.TxWatch(&models.Watch{UserID: 1, ItemID: item.ID}))
+		now = now.Add(time.Minute)
+	}
+
+	// Morty wins every item but does not pay for them, so Rick gets an offer for each
+	now = endsAt
+	_, err := asvc.Advance()
+	assert.NoError(t, err)
+	_, err = ssvc.Settle()
+	assert.NoError(t, err)
+	now = now.Add(time.Hour)
+	_, err = ssvc.Settle()
+	assert.NoError(t, err)
+
+	var cases = []struct {
+		name    string
+		path    string
+		id      func(map[string]interface{}) float64
+		outpage [][]int64
+	}{
+		{"items by creation", "/items/?sort=-createdAt", field("id"), [][]int64{{3, 2, 1}}},
+		{"users by creation", "/users/?sort=-createdAt&limit=2", field("id"), [][]int64{{3, 2}, {1}}},
+		{"watchlist", "/users/1/watchlist/?limit=2", itemField, [][]int64{{3, 2}, {1}}},
+		{"watchlist by item", "/users/1/watchlist/?sort=id&limit=2", itemField, [][]int64{{1, 2}, {3}}},
+		{"invoices", "/users/3/invoices/?limit=2", field("itemId"), [][]int64{{1, 2}, {3}}},
+		{"offers", "/users/2/offers/?limit=2", field("id"), [][]int64{{1, 2}, {3}}},
+		{"offers descending", "/users/2/offers/?sort=-id&limit=2", field("id"), [][]int64{{3, 2}, {1}}},
+		{"search", "/items/search?q=plumbus&sort=id&limit=2", itemField, [][]int64{{1, 2}, {3}}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.outpage, getPages(t, srv, tt.path, tt.id))
+		})
+	}
+
+	for _, path := range []string{
+		"/users/1/watchlist/?sort=amount",
+		"/users/3/invoices/?limit=0",
+		"/users/2/offers/?cursor=garbage",
+		"/items/search?q=plumbus&limit=1000",
+	} {
+		res, err := http.Get(srv.URL + path)
+		if assert.NoError(t, err) {
+			res.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode, path)
+		}
+	}
+}
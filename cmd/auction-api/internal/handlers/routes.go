@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"context"
+	"crypto/subtle"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/mux"
+
+	"github.com/noelruault/auction-bid-tracker/internal/web"
 )
 
 type App struct {
@@ -23,6 +28,39 @@ func (app *App) SetupRouter() {
 		Path("/users/{userId}/items/{itemId}/bids/").
 		HandlerFunc(app.CreateBid)
 
+	app.Router.
+		Methods(http.MethodPost).
+		Path("/users/{userId}/items/{itemId}/proxy/").
+		HandlerFunc(app.CreateProxyBid)
+
+	app.Router.
+		Methods(http.MethodPost).
+		Path("/users/{userId}/items/{itemId}/buy").
+		HandlerFunc(app.BuyNow)
+
+	app.Router.
+		Methods(http.MethodPost).
+		Path("/users/{userId}/items/{itemId}/accept").
+		HandlerFunc(app.AcceptPrice)
+
+	app.Router.
+		Methods(http.MethodPost).
+		Path("/users/{userId}/bids/{bidId}/retract").
+		HandlerFunc(app.RetractBid)
+
+	// the admin routes are only served when the API has an admin token to check
+	if app.Api.adminToken != "" {
+		app.Router.
+			Methods(http.MethodPost).
+			Path("/admin/bids/{bidId}/void").
+			HandlerFunc(app.admin(app.VoidBid))
+	}
+
+	app.Router.
+		Methods(http.MethodGet).
+		Path("/users/{userId}/bids/").
+		HandlerFunc(app.ListBidsByUserID)
+
 	app.Router.
 		Methods(http.MethodGet).
 		Path("/users/{userId}/bids/items/").
@@ -33,6 +71,16 @@ func (app *App) SetupRouter() {
 		Path("/items/{itemId}/bids/highest/").
 		HandlerFunc(app.GetWinningBid)
 
+	app.Router.
+		Methods(http.MethodGet).
+		Path("/items/{itemId}/bids/winners/").
+		HandlerFunc(app.GetWinningBids)
+
+	app.Router.
+		Methods(http.MethodGet).
+		Path("/items/{itemId}/bids/stream").
+		HandlerFunc(app.StreamBids)
+
 	app.Router.
 		Methods(http.MethodGet).
 		Path("/items/{itemId}/bids/").
@@ -44,11 +92,116 @@ func (app *App) SetupRouter() {
 		Path("/items/").
 		HandlerFunc(app.ListItems)
 
+	app.Router.
+		Methods(http.MethodGet).
+		Path("/items/search").
+		HandlerFunc(app.SearchItems)
+
+	app.Router.
+		Methods(http.MethodGet).
+		Path("/items/{itemId}").
+		HandlerFunc(app.GetItem)
+
+	app.Router.
+		Methods(http.MethodPatch).
+		Path("/items/{itemId}").
+		HandlerFunc(app.EditItem)
+
+	app.Router.
+		Methods(http.MethodDelete).
+		Path("/items/{itemId}").
+		HandlerFunc(app.WithdrawItem)
+
+	app.Router.
+		Methods(http.MethodPost).
+		Path("/items/{itemId}/publish").
+		HandlerFunc(app.PublishItem)
+
+	app.Router.
+		Methods(http.MethodGet).
+		Path("/users/{userId}/items/").
+		HandlerFunc(app.ListItemsBySellerID)
+
 	app.Router.
 		Methods(http.MethodPost).
 		Path("/items/").
 		HandlerFunc(app.CreateItem)
 
+	// Wallets
+	app.Router.
+		Methods(http.MethodGet).
+		Path("/users/{userId}/wallet/").
+		HandlerFunc(app.GetWallet)
+
+	app.Router.
+		Methods(http.MethodPost).
+		Path("/users/{userId}/wallet/deposit").
+		HandlerFunc(app.Deposit)
+
+	app.Router.
+		Methods(http.MethodPost).
+		Path("/users/{userId}/wallet/withdraw").
+		HandlerFunc(app.Withdraw)
+
+	// Watchlists
+	app.Router.
+		Methods(http.MethodGet).
+		Path("/users/{userId}/watchlist/").
+		HandlerFunc(app.ListWatchlist)
+
+	app.Router.
+		Methods(http.MethodPut).
+		Path("/users/{userId}/watchlist/{itemId}").
+		HandlerFunc(app.Watch)
+
+	app.Router.
+		Methods(http.MethodDelete).
+		Path("/users/{userId}/watchlist/{itemId}").
+		HandlerFunc(app.Unwatch)
+
+	// Live bidding
+	app.Router.
+		Methods(http.MethodGet).
+		Path("/users/{userId}/live").
+		HandlerFunc(app.Live)
+
+	// Notifications
+	app.Router.
+		Methods(http.MethodGet).
+		Path("/users/{userId}/notifications/").
+		HandlerFunc(app.GetNotificationPreferences)
+
+	app.Router.
+		Methods(http.MethodPut).
+		Path("/users/{userId}/notifications/").
+		HandlerFunc(app.SetNotificationPreferences)
+
+	// Settlements
+	app.Router.
+		Methods(http.MethodGet).
+		Path("/items/{itemId}/order").
+		HandlerFunc(app.GetOrder)
+
+	app.Router.
+		Methods(http.MethodGet).
+		Path("/users/{userId}/invoices/").
+		HandlerFunc(app.ListInvoicesByUserID)
+
+	app.Router.
+		Methods(http.MethodGet).
+		Path("/users/{userId}/offers/").
+		HandlerFunc(app.ListOffersByUserID)
+
+	app.Router.
+		Methods(http.MethodPost).
+		Path("/users/{userId}/offers/{offerId}/accept").
+		HandlerFunc(app.AcceptOffer)
+
+	app.Router.
+		Methods(http.MethodPost).
+		Path("/users/{userId}/offers/{offerId}/decline").
+		HandlerFunc(app.DeclineOffer)
+
 	// Users
 	app.Router.
 		Methods(http.MethodGet).
@@ -60,3 +213,24 @@ func (app *App) SetupRouter() {
 		Path("/users/").
 		HandlerFunc(app.CreateUser)
 }
+
+// admin only lets the requests carrying the admin token of the API as a bearer token through to h:
+//
+//	Authorization: Bearer <token>
+func (app *App) admin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		token := strings.TrimPrefix(auth, "Bearer ")
+
+		if token == auth || subtle.ConstantTimeCompare([]byte(token), []byte(app.Api.adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			web.Respond(context.Background(), w, map[string]string{
+				"error":   "unauthorized",
+				"message": "admin token required",
+			}, http.StatusUnauthorized)
+			return
+		}
+
+		h(w, r)
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/noelruault/auction-bid-tracker/internal/models"
+)
+
+func TestAdmin(t *testing.T) {
+	db := models.CreateDatabase()
+	usvc := models.NewUserService(db)
+	isvc := models.NewItemService(db, usvc)
+	bsvc := models.NewBidService(db, isvc, usvc)
+
+	usvc.TxCreate(&models.User{Name: "Morty"})
+	usvc.TxCreate(&models.User{Name: "Rick"})
+	item := models.Item{Name: "plumbus", SellerID: 2, Value: models.Money{Amount: 10, Currency: "GBP"}}
+	assert.NoError(t, isvc.TxCreate(&item))
+	assert.NoError(t, bsvc.TxCreate(&models.Bid{UserID: 1, ItemID: item.ID, Amount: models.Money{Amount: 20, Currency: "GBP"}}))
+
+	var cases = []struct {
+		name      string
+		token     string
+		auth      string
+		outstatus int
+	}{
+		{"no admin token", "", "Bearer ", http.StatusNotFound},
+		{"no authorization", "secret", "", http.StatusUnauthorized},
+		{"not a bearer token", "secret", "secret", http.StatusUnauthorized},
+		{"wrong token", "secret", "Bearer guess", http.StatusUnauthorized},
+		{"admin", "secret", "Bearer secret", http.StatusOK},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := testServer(t, db, tt.token)
+
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/admin/bids/1/void", strings.NewReader(`{"reason": "shill bidding"}`))
+			assert.NoError(t, err)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+
+			res, err := http.DefaultClient.Do(req)
+			if assert.NoError(t, err) {
+				res.Body.Close()
+				assert.Equal(t, tt.outstatus, res.StatusCode)
+			}
+		})
+	}
+
+	bid, err := bsvc.Get(1)
+	assert.NoError(t, err)
+	assert.Equal(t, models.BidVoided, bid.Status)
+}
@@ -0,0 +1,2 @@
+// Package scheduler contains the background worker that moves the items through their auction lifecycle.
+package scheduler
@@ -0,0 +1,106 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/noelruault/auction-bid-tracker/internal/models"
+)
+
+// retryDelay is the time the scheduler waits before trying again after a failed advance.
+const retryDelay = time.Second
+
+// settleRetryDelay is the time the scheduler waits before collecting again the payments of the orders
+// whose buyers did not have the funds.
+const settleRetryDelay = time.Minute
+
+// Scheduler opens and closes the items exactly when their schedule says so, and settles them once
+// they are closed.
+type Scheduler struct {
+	auctions    models.AuctionService
+	settlements models.SettlementService
+	clock       models.Clock
+	log         *log.Logger
+}
+
+func New(as models.AuctionService, ss models.SettlementService, clock models.Clock, log *log.Logger) *Scheduler {
+	return &Scheduler{
+		auctions:    as,
+		settlements: ss,
+		clock:       clock,
+		log:         log,
+	}
+}
+
+// Run advances and settles the items every time one of them reaches a schedule boundary, sleeping in
+// between. It blocks until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		var wait *time.Duration
+
+		if err := s.step(); err != nil {
+			s.log.Printf("scheduler : %v", err)
+			d := retryDelay
+			wait = &d
+		} else {
+			if next, ok := s.auctions.NextTransition(); ok {
+				d := next.Sub(s.clock.Now())
+				wait = &d
+			}
+
+			if s.settlements.Outstanding() && (wait == nil || *wait > settleRetryDelay) {
+				d := settleRetryDelay
+				wait = &d
+			}
+		}
+
+		if !s.sleep(ctx, wait) {
+			return
+		}
+	}
+}
+
+// step advances the items and settles the ones that closed, logging every change of state.
+func (s *Scheduler) step() error {
+	items, err := s.auctions.Advance()
+	s.logStates(items)
+	if err != nil {
+		return fmt.Errorf("advance : %w", err)
+	}
+
+	items, err = s.settlements.Settle()
+	s.logStates(items)
+	if err != nil {
+		return fmt.Errorf("settle : %w", err)
+	}
+
+	return nil
+}
+
+func (s *Scheduler) logStates(items []models.Item) {
+	for _, i := range items {
+		s.log.Printf("scheduler : item %d is now %s", i.ID, i.State)
+	}
+}
+
+// sleep waits for d to pass or for the items to change, whatever happens first. A nil d waits for
+// changes only. It returns false if ctx was cancelled in the meantime.
+func (s *Scheduler) sleep(ctx context.Context, d *time.Duration) bool {
+	var timeout <-chan time.Time
+	if d != nil {
+		timer := time.NewTimer(*d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-s.auctions.Changed():
+	case <-timeout:
+	}
+
+	return true
+}
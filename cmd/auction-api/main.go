@@ -1,13 +1,23 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/mail"
+	"net/smtp"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/noelruault/auction-bid-tracker/cmd/auction-api/internal/handlers"
+	"github.com/noelruault/auction-bid-tracker/cmd/auction-api/internal/scheduler"
 	"github.com/noelruault/auction-bid-tracker/internal/models"
 )
 
@@ -21,16 +31,190 @@ func main() {
 func run() error {
 	log := log.New(os.Stdout, "AUCTION-BID-TRACKER : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
 
+	increment := flag.String("increment", "", `global minimum bid increment rule as JSON, e.g. {"type":"percentage","percent":5}`)
+	buyNowThreshold := flag.Int("buy-now-threshold", 0, "percentage (0 to 100) of the buy-now price a bid has to exceed to withdraw the buy-now option")
+	var softClose models.SoftClose
+	flag.DurationVar(&softClose.Window, "soft-close-window", 0, "final period of an auction in which a bid pushes back its end (0 disables the soft close)")
+	flag.DurationVar(&softClose.Extension, "soft-close-extension", 2*time.Minute, "time the end of an auction is pushed back by a bid in the soft close window")
+	flag.DurationVar(&softClose.MaxExtension, "soft-close-max", 30*time.Minute, "maximum total time the end of an auction can be pushed back (0 means no limit)")
+	var retraction models.RetractionPolicy
+	flag.DurationVar(&retraction.Window, "retract-window", 0, "time after placing a bid during which users may retract it (0 disables the retractions)")
+	flag.DurationVar(&retraction.Cutoff, "retract-cutoff", time.Hour, "final period of an auction in which bids cannot be retracted")
+	requireFunds := flag.Bool("require-funds", true, "require bidders to have the funds for their bids in their wallet, holding them while they lead")
+	ratesPath := flag.String("rates", "", `exchange rates file as JSON, e.g. {"base":"GBP","rates":{"EUR":1.17}} (bids must be in the currency of their item without it)`)
+	ratesReload := flag.Duration("rates-reload", time.Minute, "interval at which the exchange rates file is reloaded if it changed (0 disables the reloads)")
+	buyerPremium := flag.String("buyer-premium", "", `fee schedule charged to buyers on top of the hammer price as JSON, e.g. {"tiers":[{"basisPoints":2000}]}`)
+	sellerCommission := flag.String("seller-commission", "", `fee schedule taken from the hammer price before paying sellers as JSON, e.g. {"tiers":[{"below":100000,"basisPoints":1000},{"basisPoints":500}]}`)
+	paymentDeadline := flag.Duration("payment-deadline", 72*time.Hour, "time buyers have to pay once an item is settled, before a second-chance offer is made (0 waits indefinitely)")
+	offerWindow := flag.Duration("offer-window", 24*time.Hour, "time a second-chance offer stays open (0 disables the offers)")
+	notifyQueue := flag.Int("notify-queue", 1024, "number of notifications waiting to be delivered before the new ones are dropped")
+	notifyWorkers := flag.Int("notify-workers", 4, "number of notifications delivered at the same time")
+	smtpAddr := flag.String("smtp-addr", "", "address (host:port) of the SMTP server sending the email notifications (empty disables the emails)")
+	smtpFrom := flag.String("smtp-from", "auctions@localhost", "sender address of the email notifications")
+	smtpUser := flag.String("smtp-user", "", "user authenticating to the SMTP server, with the password in the SMTP_PASSWORD environment variable")
+	adminToken := flag.String("admin-token", "", "bearer token of the administrators, which may rather be given in the ADMIN_TOKEN environment variable (empty disables the admin routes)")
+	wsOrigins := flag.String("ws-origins", "", "comma-separated origins of the web pages allowed to open the live bidding channel besides the API's own, e.g. https://example.com")
+	flag.Parse()
+
+	if *adminToken == "" {
+		*adminToken = os.Getenv("ADMIN_TOKEN")
+	}
+
 	log.Printf("main : Started")
 	defer log.Println("main : Completed")
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := models.ValidBuyNowThreshold(*buyNowThreshold); err != nil {
+		return fmt.Errorf("validating buy-now threshold: %w", err)
+	}
+
+	bidOpts := []models.BidOption{
+		models.WithBuyNowThreshold(*buyNowThreshold),
+		models.WithSoftClose(softClose),
+		models.WithRetraction(retraction),
+	}
+	if *requireFunds {
+		bidOpts = append(bidOpts, models.WithHolds())
+	}
+	if *increment != "" {
+		var inc models.Increment
+		if err := json.Unmarshal([]byte(*increment), &inc); err != nil {
+			return fmt.Errorf("parsing increment: %w", err)
+		}
+		if err := inc.Validate(); err != nil {
+			return fmt.Errorf("validating increment: %w", err)
+		}
+		bidOpts = append(bidOpts, models.WithIncrement(inc))
+	}
+	if *ratesPath != "" {
+		rates, err := models.NewFileRates(*ratesPath)
+		if err != nil {
+			return fmt.Errorf("loading rates: %w", err)
+		}
+		bidOpts = append(bidOpts, models.WithRates(rates))
+
+		if *ratesReload > 0 {
+			go reloadRates(ctx, rates, *ratesReload, log)
+		}
+	}
+
+	settleOpts := []models.SettlementOption{
+		models.WithPaymentDeadline(*paymentDeadline),
+		models.WithOfferWindow(*offerWindow),
+	}
+	if *buyerPremium != "" {
+		fs, err := parseFeeSchedule(*buyerPremium)
+		if err != nil {
+			return fmt.Errorf("buyer premium: %w", err)
+		}
+		settleOpts = append(settleOpts, models.WithBuyerPremium(fs))
+	}
+	if *sellerCommission != "" {
+		fs, err := parseFeeSchedule(*sellerCommission)
+		if err != nil {
+			return fmt.Errorf("seller commission: %w", err)
+		}
+		settleOpts = append(settleOpts, models.WithSellerCommission(fs))
+	}
+
 	database := models.CreateDatabase()
+	users := models.NewUserService(database)
+
+	notifiers := []models.Notifier{models.NewWebhookNotifier(nil)}
+	if *smtpAddr != "" {
+		sn, err := smtpNotifier(*smtpAddr, *smtpFrom, *smtpUser, os.Getenv("SMTP_PASSWORD"))
+		if err != nil {
+			return fmt.Errorf("smtp: %w", err)
+		}
+		notifiers = append(notifiers, sn)
+	}
+
+	// the publisher is set before the services are created, as they keep it
+	dispatcher := models.NewDispatcher(models.NewNotificationService(database, users), *notifyQueue, notifiers...)
+	database.SetPublisher(dispatcher)
+	for n := 0; n < *notifyWorkers; n++ {
+		go dispatcher.Run(ctx, log.Printf)
+	}
+
+	settlements := models.NewSettlementService(database, users, settleOpts...)
 	app := &handlers.App{
 		Router: mux.NewRouter().StrictSlash(true),
-		Api:    handlers.NewAPI(database, log),
+		Api:    handlers.NewAPI(database, log, settlements, splitList(*wsOrigins), *adminToken, bidOpts...),
 	}
 
 	app.SetupRouter()
 
+	sched := scheduler.New(models.NewAuctionService(database), settlements, models.SystemClock, log)
+	go sched.Run(ctx)
+
 	return http.ListenAndServe(":8080", app.Router)
 }
+
+// parseFeeSchedule parses and validates a fee schedule given as JSON.
+func parseFeeSchedule(raw string) (models.FeeSchedule, error) {
+	var fs models.FeeSchedule
+	if err := json.Unmarshal([]byte(raw), &fs); err != nil {
+		return fs, fmt.Errorf("parsing: %w", err)
+	}
+	if err := fs.Validate(); err != nil {
+		return fs, fmt.Errorf("validating: %w", err)
+	}
+	return fs, nil
+}
+
+// splitList splits a comma-separated list, leaving out the empty elements.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// smtpNotifier returns the notifier sending the emails through the SMTP server at addr, authenticating
+// as user if it is set.
+func smtpNotifier(addr, from, user, password string) (*models.SMTPNotifier, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("sender: %w", err)
+	}
+
+	var auth smtp.Auth
+	if user != "" {
+		auth = smtp.PlainAuth("", user, password, host)
+	}
+
+	return models.NewSMTPNotifier(addr, from, auth), nil
+}
+
+// reloadRates reloads the exchange rates every interval if their file changed, until ctx is cancelled.
+// The previous rates stay in use if the file cannot be loaded.
+func reloadRates(ctx context.Context, rates *models.FileRates, interval time.Duration, log *log.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := rates.ReloadIfChanged()
+		if err != nil {
+			log.Printf("main : reloading rates : %v", err)
+			continue
+		}
+		if reloaded {
+			log.Printf("main : rates reloaded")
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"time"
+)
+
+// AuctionService drives the lifecycle of the items, opening and closing them when their schedule
+// says so.
+type AuctionService interface {
+	// Advance moves every item whose schedule boundary has been reached to its next state and
+	// returns the items that changed.
+	Advance() ([]Item, error)
+
+	// NextTransition returns the time of the closest pending state change, if any.
+	NextTransition() (time.Time, bool)
+
+	// Changed receives a value every time an item is created or deleted, or its schedule or state
+	// changes, so callers waiting on NextTransition know they have to ask again.
+	Changed() <-chan struct{}
+}
+
+type auctionService struct {
+	items   *ItemStorage
+	bids    *BidStorage
+	wallets *WalletStorage
+	clock   Clock
+	events  Publisher
+}
+
+func NewAuctionService(db *DB) AuctionService {
+	return &auctionService{
+		items:   &db.items,
+		bids:    &db.bids,
+		wallets: &db.wallets,
+		clock:   db.clock,
+		events:  db.events,
+	}
+}
+
+func (as *auctionService) Advance() ([]Item, error) {
+	now := as.clock.Now()
+
+	var changed []Item
+	for _, item := range as.items.ListItems() {
+		if item.StateAt(now) == item.State {
+			continue
+		}
+
+		var updated Item
+		var events []Event
+		err := as.items.TxUpdate(item.ID, func(i *Item) error {
+			i.State = i.StateAt(now)
+
+			if i.State == ItemClosed {
+				bids, err := as.bids.ListBidsByItemID(i.ID)
+				if err != nil && err != ErrNotFound {
+					return err
+				}
+
+				freezeResult(i, bids)
+				events = closedEvents(*i, bids, now)
+
+				// the losers get their funds back
+				if err := releaseHolds(as.wallets, as.bids, *i); err != nil {
+					return err
+				}
+			}
+
+			updated = *i
+			return nil
+		})
+		if err != nil {
+			return changed, err
+		}
+
+		if len(events) > 0 {
+			as.events.Publish(events...)
+		}
+		changed = append(changed, updated)
+	}
+
+	return changed, nil
+}
+
+func (as *auctionService) NextTransition() (time.Time, bool) {
+	var next time.Time
+
+	for _, item := range as.items.ListItems() {
+		var t time.Time
+		switch item.State {
+		case ItemScheduled:
+			t = item.StartsAt
+		case ItemOpen:
+			t = item.EndsAt
+		default:
+			continue
+		}
+
+		if next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+
+	return next, !next.IsZero()
+}
+
+func (as *auctionService) Changed() <-chan struct{} {
+	return as.items.Changed()
+}
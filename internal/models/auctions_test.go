@@ -0,0 +1,299 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuctionService_Advance(t *testing.T) {
+	now := testNow
+	db := CreateDatabase()
+	db.SetClock(ClockFunc(func() time.Time { return now }))
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc)
+	asvc := NewAuctionService(db)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+
+	item := Item{Name: "portal gun", SellerID: testSeller(usvc), Value: gbp(10), StartsAt: now.Add(time.Minute), EndsAt: now.Add(time.Hour)}
+	assert.NoError(t, isvc.TxCreate(&item))
+	assert.Equal(t, ItemScheduled, item.State)
+
+	next, ok := asvc.NextTransition()
+	assert.True(t, ok)
+	assert.Equal(t, item.StartsAt, next)
+
+	// nothing to do before the start time
+	changed, err := asvc.Advance()
+	assert.NoError(t, err)
+	assert.Empty(t, changed)
+	assert.Error(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: gbp(20)}))
+
+	now = item.StartsAt
+	changed, err = asvc.Advance()
+	assert.NoError(t, err)
+	assert.Len(t, changed, 1)
+	assert.Equal(t, ItemOpen, changed[0].State)
+
+	next, ok = asvc.NextTransition()
+	assert.True(t, ok)
+	assert.Equal(t, item.EndsAt, next)
+
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: gbp(20)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: item.ID, Amount: gbp(30)}))
+
+	now = item.EndsAt
+	err = bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: gbp(40)})
+	assert.Equal(t, ValidationError{"item": ErrNotOpen}, err)
+
+	changed, err = asvc.Advance()
+	assert.NoError(t, err)
+	assert.Len(t, changed, 1)
+	assert.Equal(t, ItemClosed, changed[0].State)
+	assert.Equal(t, int64(2), changed[0].WinningBidID)
+	assert.True(t, changed[0].Sold)
+
+	_, ok = asvc.NextTransition()
+	assert.False(t, ok)
+
+	winning, err := bsvc.GetWinningBid(item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, Bid{ID: 2, UserID: 2, ItemID: item.ID, Amount: gbp(30), Kind: BidManual, Status: BidActive, CreatedAt: item.StartsAt}, winning)
+}
+
+func TestAuctionService_Advance_ReserveNotMet(t *testing.T) {
+	now := testNow
+	db := CreateDatabase()
+	db.SetClock(ClockFunc(func() time.Time { return now }))
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc)
+	asvc := NewAuctionService(db)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+
+	item := Item{Name: "portal gun", SellerID: testSeller(usvc), Value: gbp(10), ReservePrice: gbpPtr(100), EndsAt: now.Add(time.Hour)}
+	assert.NoError(t, isvc.TxCreate(&item))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: gbp(50)}))
+
+	now = item.EndsAt
+	changed, err := asvc.Advance()
+	assert.NoError(t, err)
+	assert.Len(t, changed, 1)
+	assert.Equal(t, ItemClosed, changed[0].State)
+	assert.Equal(t, int64(1), changed[0].WinningBidID)
+	assert.False(t, changed[0].Sold)
+}
+
+func TestAuctionService_Advance_Sealed(t *testing.T) {
+	var cases = []struct {
+		name      string
+		auction   AuctionType
+		reserve   int64
+		bids      []Bid
+		outwinner int64
+		outprice  int64
+	}{
+		{
+			"first_price",
+			AuctionSealedFirstPrice,
+			0,
+			[]Bid{{UserID: 1, Amount: gbp(50)}, {UserID: 2, Amount: gbp(80)}, {UserID: 3, Amount: gbp(60)}},
+			2,
+			80,
+		},
+		{
+			"vickrey",
+			AuctionVickrey,
+			0,
+			[]Bid{{UserID: 1, Amount: gbp(50)}, {UserID: 2, Amount: gbp(80)}, {UserID: 3, Amount: gbp(60)}},
+			2,
+			60,
+		},
+		{
+			"vickrey_replaced_bid",
+			AuctionVickrey,
+			0,
+			[]Bid{{UserID: 1, Amount: gbp(50)}, {UserID: 2, Amount: gbp(80)}, {UserID: 1, Amount: gbp(90)}},
+			1,
+			80,
+		},
+		{
+			"vickrey_single_bid_pays_reserve",
+			AuctionVickrey,
+			40,
+			[]Bid{{UserID: 1, Amount: gbp(50)}},
+			1,
+			40,
+		},
+		{
+			"tie_goes_to_first_bid",
+			AuctionSealedFirstPrice,
+			0,
+			[]Bid{{UserID: 1, Amount: gbp(50)}, {UserID: 2, Amount: gbp(50)}},
+			1,
+			50,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			now := testNow
+			db := CreateDatabase()
+			db.SetClock(ClockFunc(func() time.Time { return now }))
+
+			usvc := NewUserService(db)
+			isvc := NewItemService(db, usvc)
+			bsvc := NewBidService(db, isvc, usvc)
+			asvc := NewAuctionService(db)
+
+			usvc.TxCreate(&User{Name: "Morty"})
+			usvc.TxCreate(&User{Name: "Rick"})
+			usvc.TxCreate(&User{Name: "Summer"})
+
+			item := Item{Name: "portal gun", SellerID: testSeller(usvc), Value: gbp(10), ReservePrice: gbpPtr(tt.reserve), Type: tt.auction, EndsAt: now.Add(time.Hour)}
+			assert.NoError(t, isvc.TxCreate(&item))
+
+			for _, b := range tt.bids {
+				b.ItemID = item.ID
+				assert.NoError(t, bsvc.TxCreate(&b))
+			}
+
+			// amounts and winner are hidden while the item is open
+			bids, err := bsvc.ListBidsByItemID(item.ID)
+			assert.NoError(t, err)
+			for _, b := range bids {
+				assert.Zero(t, b.Amount)
+			}
+
+			// even to the users who placed them
+			bids, err = bsvc.ListBidsByUserID(1)
+			assert.NoError(t, err)
+			assert.NotEmpty(t, bids)
+			for _, b := range bids {
+				assert.Zero(t, b.Amount)
+			}
+
+			_, err = bsvc.GetWinningBid(item.ID)
+			assert.Equal(t, ErrSealed, err)
+
+			now = item.EndsAt
+			_, err = asvc.Advance()
+			assert.NoError(t, err)
+
+			item, _ = isvc.Get(item.ID)
+			assert.Equal(t, gbpPtr(tt.outprice), item.ClearingPrice)
+			assert.True(t, item.Sold)
+
+			winning, err := bsvc.GetWinningBid(item.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.outwinner, winning.UserID)
+
+			bids, err = bsvc.ListBidsByItemID(item.ID)
+			assert.NoError(t, err)
+			for _, b := range bids {
+				assert.NotZero(t, b.Amount)
+			}
+
+			bids, err = bsvc.ListBidsByUserID(1)
+			assert.NoError(t, err)
+			for _, b := range bids {
+				assert.NotZero(t, b.Amount)
+			}
+		})
+	}
+}
+
+func TestAuctionService_Advance_Draft(t *testing.T) {
+	db := CreateDatabase()
+	db.SetClock(testClock())
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	asvc := NewAuctionService(db)
+
+	item := Item{Name: "plumbus", SellerID: testSeller(usvc), Value: gbp(10), State: ItemDraft}
+	assert.NoError(t, isvc.TxCreate(&item))
+
+	changed, err := asvc.Advance()
+	assert.NoError(t, err)
+	assert.Empty(t, changed)
+
+	_, ok := asvc.NextTransition()
+	assert.False(t, ok)
+}
+
+func TestItemService_TxPublish(t *testing.T) {
+	now := testNow
+	db := CreateDatabase()
+	db.SetClock(ClockFunc(func() time.Time { return now }))
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc)
+	asvc := NewAuctionService(db)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	seller := testSeller(usvc)
+
+	scheduled := Item{Name: "plumbus", SellerID: seller, Value: gbp(10), State: ItemDraft, StartsAt: now.Add(time.Hour), EndsAt: now.Add(2 * time.Hour)}
+	started := Item{Name: "portal gun", SellerID: seller, Value: gbp(10), State: ItemDraft, EndsAt: now.Add(time.Hour)}
+	ended := Item{Name: "meeseeks box", SellerID: seller, Value: gbp(10), State: ItemDraft, EndsAt: now.Add(time.Minute)}
+	open := Item{Name: "space cruiser", SellerID: seller, Value: gbp(10)}
+	for _, i := range []*Item{&scheduled, &started, &ended, &open} {
+		assert.NoError(t, isvc.TxCreate(i))
+	}
+
+	// drafts take no bids
+	assert.Equal(t, ValidationError{"item": ErrNotOpen}, bsvc.TxCreate(&Bid{UserID: 1, ItemID: started.ID, Amount: gbp(20)}))
+
+	now = now.Add(30 * time.Minute)
+
+	var cases = []struct {
+		name     string
+		itemID   int64
+		outstate ItemState
+		outerr   error
+	}{
+		{"before its start", scheduled.ID, ItemScheduled, nil},
+		{"after its start", started.ID, ItemOpen, nil},
+		{"after its end", ended.ID, "", ValidationError{"endsAt": ErrInvalid}},
+		{"already published", open.ID, "", ValidationError{"state": ErrInvalid}},
+		{"twice", started.ID, "", ValidationError{"state": ErrInvalid}},
+		{"unknown item", 42, "", ValidationError{"item": ErrNotFound}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			item, err := isvc.TxPublish(tt.itemID)
+			assert.Equal(t, tt.outerr, err)
+			assert.Equal(t, tt.outstate, item.State)
+		})
+	}
+
+	ended, _ = isvc.Get(ended.ID)
+	assert.Equal(t, ItemDraft, ended.State)
+
+	// published items take bids and are scheduled like any other
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: started.ID, Amount: gbp(20)}))
+
+	next, ok := asvc.NextTransition()
+	assert.True(t, ok)
+	assert.Equal(t, scheduled.StartsAt, next)
+
+	now = scheduled.StartsAt
+	changed, err := asvc.Advance()
+	assert.NoError(t, err)
+
+	// items are listed in no particular order
+	states := map[int64]ItemState{}
+	for _, i := range changed {
+		states[i.ID] = i.State
+	}
+	assert.Equal(t, map[int64]ItemState{scheduled.ID: ItemOpen, started.ID: ItemClosed}, states)
+}
@@ -1,21 +1,89 @@
 package models
 
+import (
+	"time"
+)
+
+// BidKind tells how a bid was placed.
+type BidKind string
+
+const (
+	BidManual BidKind = "manual"  // placed by the user
+	BidProxy  BidKind = "proxy"   // placed automatically on behalf of the user, up to their proxy maximum
+	BidBuyNow BidKind = "buy_now" // the purchase of the item at its buy-now price
+	BidAccept BidKind = "accept"  // the acceptance of the current price of a Dutch auction
+)
+
+// BidStatus tells whether a bid still competes for the item.
+type BidStatus string
+
+const (
+	BidActive    BidStatus = "active"    // competes for the item
+	BidRetracted BidStatus = "retracted" // withdrawn by the user who placed it
+	BidVoided    BidStatus = "voided"    // cancelled by an administrator
+)
+
 type Bid struct {
-	ID     int64 `json:"id"`
-	UserID int64 `json:"userId"`
-	ItemID int64 `json:"itemId"`
-	Amount int   `json:"amount"`
+	ID     int64   `json:"id"`
+	UserID int64   `json:"userId"`
+	ItemID int64   `json:"itemId"`
+	Amount Money   `json:"amount"`
+	Kind   BidKind `json:"kind,omitempty"`
+
+	// OriginalAmount is the amount placed by the user when it is not in the currency of the item, in
+	// which case Amount is its conversion at the rate of the time the bid was placed.
+	OriginalAmount *Money `json:"originalAmount,omitempty"`
+
+	// Quantity is the number of units requested on multi-unit items. Zero means a single unit.
+	Quantity int `json:"quantity,omitempty"`
+
+	// Status is set to active when the bid is placed. Retracted and voided bids are kept for the
+	// record, along with the Reason given, but they are ignored when working out the winners.
+	Status    BidStatus `json:"status"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// active reports whether b competes for its item.
+func (b Bid) active() bool {
+	return b.Status == BidActive
+}
+
+// activeBids returns the bids that compete for their item.
+func activeBids(bids []Bid) []Bid {
+	var active []Bid
+	for _, b := range bids {
+		if b.active() {
+			active = append(active, b)
+		}
+	}
+	return active
 }
 
 type BidDB interface {
 	TxCreate(*Bid) error
+	TxReplace(int64, *Bid) error
+	TxUpdate(int64, func(*Bid) error) error
+	Get(int64) (Bid, error)
 	ListBidsByItemID(int64) ([]Bid, error)
 	GetWinningBid(int64) (Bid, error)
+	GetLowestBid(int64) (Bid, error)
 	ListBidsByUserID(int64) ([]Bid, error)
+	ListItemIDsWithBids() []int64
+
+	// TxRestore puts back the bids of an item as they were, dropping those stored since.
+	TxRestore(itemID int64, bids []Bid) error
 }
 
 type BidService interface {
 	BidDB
+	TxCreateProxy(*ProxyBid) error
+	TxBuyNow(*Bid) error
+	TxAccept(*Bid) error
+	TxRetract(*Bid) error
+	TxVoid(*Bid) error
+	GetWinningBids(int64) ([]Allocation, error)
+	StreamBids(itemID, lastID int64) (*BidStream, error)
 }
 
 // bidService wraps the BidService interface to allow mocking by interfaces
@@ -23,39 +91,399 @@ type bidService struct {
 	BidService
 }
 
-func NewBidService(db *DB, isvc ItemService, usvc UserService) BidService {
+// BidOption configures the bid service built by NewBidService.
+type BidOption func(*bidValidator)
+
+// WithIncrement sets the minimum bid increment used for the items that do not define their own.
+func WithIncrement(inc Increment) BidOption {
+	return func(bv *bidValidator) {
+		bv.increment = inc
+	}
+}
+
+// WithBuyNowThreshold sets the percentage of the buy-now price a bid has to exceed to withdraw the
+// buy-now option of an item. By default, the first bid withdraws it. It panics if percent is not
+// valid, see ValidBuyNowThreshold.
+func WithBuyNowThreshold(percent int) BidOption {
+	if err := ValidBuyNowThreshold(percent); err != nil {
+		panic(err)
+	}
+
+	return func(bv *bidValidator) {
+		bv.buyNowThreshold = percent
+	}
+}
+
+// ValidBuyNowThreshold checks that a buy-now threshold is a percentage between 0 and 100.
+func ValidBuyNowThreshold(percent int) error {
+	if percent < 0 || percent > 100 {
+		return ValidationError{"buyNowThreshold": ErrInvalid}
+	}
+	return nil
+}
+
+// WithSoftClose enables the soft close of the auctions, see SoftClose.
+func WithSoftClose(sc SoftClose) BidOption {
+	return func(bv *bidValidator) {
+		bv.softClose = sc
+	}
+}
+
+// WithRetraction allows users to retract their own bids, see RetractionPolicy.
+func WithRetraction(rp RetractionPolicy) BidOption {
+	return func(bv *bidValidator) {
+		bv.retraction = rp
+	}
+}
+
+// WithRates allows bids in a currency other than the one of their item, which are converted with the
+// rates of rp.
+func WithRates(rp RatesProvider) BidOption {
+	return func(bv *bidValidator) {
+		bv.rates = rp
+	}
+}
+
+// WithHolds requires users to have the funds for their bids in their wallet. The funds are held while
+// the user leads the bidding on the item, see Hold.
+func WithHolds() BidOption {
+	return func(bv *bidValidator) {
+		bv.holds = true
+	}
+}
+
+func NewBidService(db *DB, isvc ItemService, usvc UserService, opts ...BidOption) BidService {
+	bv := &bidValidator{
+		BidDB:       &db.bids,
+		proxies:     &db.proxies,
+		wallets:     &db.wallets,
+		orders:      &db.orders,
+		itemService: isvc,
+		userService: usvc,
+		clock:       db.clock,
+		events:      db.events,
+		feed:        &db.bids.feed,
+		increment:   DefaultIncrement,
+	}
+
+	for _, opt := range opts {
+		opt(bv)
+	}
+
 	return bidService{
-		BidService: &bidValidator{
-			BidDB: &BidStorage{
-				db.bids.mu,
-				db.bids.data,
-				0,
-			},
-			itemService: isvc,
-			userService: usvc,
-		},
+		BidService: bv,
 	}
 }
 
 type bidValidator struct {
 	BidDB
+	proxies     ProxyBidDB
+	wallets     WalletDB
+	orders      OrderDB
 	itemService ItemService
 	userService UserService
+	clock       Clock
+	events      Publisher
+	feed        *bidFeed
+	increment   Increment
+
+	buyNowThreshold int
+	softClose       SoftClose
+	retraction      RetractionPolicy
+	rates           RatesProvider
+	holds           bool
 }
 
 func (bs *bidValidator) TxCreate(b *Bid) error {
 	if err := bs.runValFuncs(b,
 		bs.itemExists,
 		bs.userExists,
-		bs.higherItemValue,
-		bs.higherBidAmount,
+		bs.notSeller,
+		bs.itemOpen,
+		bs.acceptsBids,
+		bs.validQuantity,
+		bs.validAmount,
+		bs.beatsItemValue,
+		bs.beatsWinningBid,
 	); err != nil {
 		return err
 	}
 
+	b.Kind = BidManual
+
+	// the bid is stored while the item is locked, so the item cannot be closed in the meantime
+	return bs.txBidding(b.ItemID, func(i *Item) error {
+		if i.StateAt(bs.clock.Now()) != ItemOpen {
+			return ValidationError{"item": ErrNotOpen}
+		}
+
+		// converted again, as the rates may have changed since the validation
+		if err := bs.convert(*i, b); err != nil {
+			if pe, ok := err.(PublicError); ok {
+				return ValidationError{"amount": pe}
+			}
+			return err
+		}
+
+		// checked again now that no other bid can be placed on the item
+		if err := bs.checkIncrement(*i, b); err != nil {
+			if pe, ok := err.(PublicError); ok {
+				return ValidationError{"bid": pe}
+			}
+			return err
+		}
+
+		// the bids of multi-unit items hold the price of every unit requested
+		total, err := b.Amount.Times(int64(units(b.Quantity)))
+		if err != nil {
+			return ValidationError{"amount": ErrOverflow}
+		}
+
+		// a new winning bid outbids the previous leader straight away, other bids may outbid
+		// several users once they are stored
+		if err := bs.hold(*i, b.UserID, total, !i.Type.Sealed() && !i.multiUnit()); err != nil {
+			return err
+		}
+
+		switch {
+		case i.Type.Sealed():
+			return bs.replaceUserBid(b)
+		case i.multiUnit():
+			err = bs.replaceUserBid(b)
+		default:
+			err = bs.storeBid(b)
+		}
+		if err != nil {
+			return err
+		}
+
+		return bs.bidPlaced(i)
+	})
+}
+
+// txBidding runs fn on item itemID while it is locked, like ItemService.TxUpdate. If fn fails, the bids,
+// proxy bids and holds of the item are put back as they were, as the item is. Once the item is released,
+// it streams the bids fn stored and publishes the events of the users who were outbid, or of the end of
+// the auction if fn closed the item.
+func (bs *bidValidator) txBidding(itemID int64, fn func(*Item) error) error {
+	var events []Event
+	var stored []Bid
+	err := bs.itemService.TxUpdate(itemID, func(i *Item) error {
+		snap, err := bs.snapshot(i.ID)
+		if err != nil {
+			return err
+		}
+		before := leaders(*i, snap.bids)
+
+		if err := fn(i); err != nil {
+			return bs.restore(snap, err)
+		}
+
+		bids, err := bs.BidDB.ListBidsByItemID(i.ID)
+		if err != nil && err != ErrNotFound {
+			return bs.restore(snap, err)
+		}
+		stored = changedBids(snap.bids, bids)
+
+		now := bs.clock.Now()
+		if i.closed() {
+			events = closedEvents(*i, bids, now)
+		} else {
+			events = outbidEvents(*i, before, bids, now)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, b := range stored {
+		bs.feed.publish(b)
+	}
+	if len(events) > 0 {
+		bs.events.Publish(events...)
+	}
+	return nil
+}
+
+// biddingSnapshot is the bidding on an item before it is changed: its bids, proxy bids and holds.
+type biddingSnapshot struct {
+	itemID  int64
+	bids    []Bid
+	proxies []ProxyBid
+	holds   []Hold
+}
+
+// snapshot takes the bidding on the item itemID, which must be locked.
+func (bs *bidValidator) snapshot(itemID int64) (biddingSnapshot, error) {
+	snap := biddingSnapshot{itemID: itemID}
+
+	var err error
+	if snap.bids, err = bs.BidDB.ListBidsByItemID(itemID); err != nil && err != ErrNotFound {
+		return snap, err
+	}
+	if snap.proxies, err = bs.proxies.ListProxyBidsByItemID(itemID); err != nil {
+		return snap, err
+	}
+	if bs.holds {
+		if snap.holds, err = bs.wallets.ListHoldsByItemID(itemID); err != nil {
+			return snap, err
+		}
+	}
+
+	return snap, nil
+}
+
+// restore puts back the bidding on the item of snap after it failed with err, which is returned unless
+// the bidding cannot be put back.
+func (bs *bidValidator) restore(snap biddingSnapshot, err error) error {
+	if rerr := bs.BidDB.TxRestore(snap.itemID, snap.bids); rerr != nil {
+		return rerr
+	}
+	if rerr := bs.proxies.TxRestore(snap.itemID, snap.proxies); rerr != nil {
+		return rerr
+	}
+	if bs.holds {
+		if rerr := bs.wallets.TxRestoreHolds(snap.itemID, snap.holds); rerr != nil {
+			return rerr
+		}
+	}
+
+	return err
+}
+
+// changedBids returns the bids of after that are not in before, or whose status changed since.
+func changedBids(before, after []Bid) []Bid {
+	old := make(map[int64]Bid, len(before))
+	for _, b := range before {
+		old[b.ID] = b
+	}
+
+	var changed []Bid
+	for _, b := range after {
+		if v, found := old[b.ID]; !found || v.Status != b.Status || v.Reason != b.Reason {
+			changed = append(changed, b)
+		}
+	}
+	return changed
+}
+
+// sellTo closes item i, which must be locked, at time now with b as the winning bid. The price is
+// the amount of b.
+func sellTo(i *Item, b Bid, now time.Time) {
+	i.EndsAt = now
+	i.State = ItemClosed
+	i.WinningBidID = b.ID
+	i.ClearingPrice = &b.Amount
+	i.Sold = true
+}
+
+// replaceUserBid stores b, replacing the previous bid of the user on the item if any. It is used on
+// sealed auctions and multi-unit items, where each user holds a single bid.
+func (bs *bidValidator) replaceUserBid(b *Bid) error {
+	bids, err := bs.BidDB.ListBidsByItemID(b.ItemID)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+
+	for _, v := range activeBids(bids) {
+		if v.UserID == b.UserID {
+			bs.stamp(b)
+			return bs.BidDB.TxReplace(v.ID, b)
+		}
+	}
+
+	return bs.storeBid(b)
+}
+
+// storeBid stores b as a new active bid placed now.
+func (bs *bidValidator) storeBid(b *Bid) error {
+	bs.stamp(b)
 	return bs.BidDB.TxCreate(b)
 }
 
+// stamp marks b as an active bid placed now.
+func (bs *bidValidator) stamp(b *Bid) {
+	b.Status, b.Reason = BidActive, ""
+	b.CreatedAt = bs.clock.Now()
+}
+
+// bidPlaced applies the consequences of a new bid on item i, which must be locked: the proxies of the
+// other users answer to it, the buy-now option is withdrawn once the bids get close enough, the end
+// of the auction is pushed back if the bid came in its final minutes and the funds of the outbid
+// users are released.
+func (bs *bidValidator) bidPlaced(i *Item) error {
+	if err := bs.resolveProxies(*i); err != nil {
+		return err
+	}
+
+	if err := bs.withdrawBuyNow(i); err != nil {
+		return err
+	}
+
+	bs.extendEnd(i)
+	return bs.releaseHolds(*i)
+}
+
+// hold sets aside amount from the wallet of the user for item i, which must be locked, replacing
+// their previous hold on the item. If outbidAll is set, the holds of the other users on the item are
+// released in the same step, except for the users whose proxy may still answer with a better bid.
+// The user holds the maximum of their proxy bid instead if it is larger, as the proxy may bid it on
+// their behalf. It does nothing unless the holds are enabled, and on reverse auctions, where the
+// bidders are paid instead of paying.
+func (bs *bidValidator) hold(i Item, userID int64, amount Money, outbidAll bool) error {
+	if !bs.holds || i.Direction == AuctionReverse {
+		return nil
+	}
+
+	proxies, err := bs.proxies.ListProxyBidsByItemID(i.ID)
+	if err != nil {
+		return err
+	}
+
+	answering := map[int64]bool{}
+	for _, p := range proxies {
+		if p.UserID == userID && p.MaxAmount.Amount > amount.Amount {
+			amount = p.MaxAmount
+		}
+		if p.UserID != userID && p.MaxAmount.Amount > amount.Amount {
+			answering[p.UserID] = true
+		}
+	}
+
+	var outbid []int64
+	if outbidAll {
+		holds, err := bs.wallets.ListHoldsByItemID(i.ID)
+		if err != nil {
+			return err
+		}
+		for _, h := range holds {
+			if h.UserID != userID && !answering[h.UserID] {
+				outbid = append(outbid, h.UserID)
+			}
+		}
+	}
+
+	if err := bs.wallets.TxHold(Hold{UserID: userID, ItemID: i.ID, Amount: amount}, outbid...); err != nil {
+		if err == ErrNoFunds {
+			return ValidationError{"amount": ErrNoFunds}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// releaseHolds releases the funds held on item i, which must be locked, by the users who do not lead
+// its bidding anymore. It does nothing unless the holds are enabled.
+func (bs *bidValidator) releaseHolds(i Item) error {
+	if !bs.holds {
+		return nil
+	}
+
+	return releaseHolds(bs.wallets, bs.BidDB, i)
+}
+
 func (bs *bidValidator) ListBidsByItemID(itemID int64) ([]Bid, error) {
 	b := &Bid{ItemID: itemID}
 
@@ -65,7 +493,24 @@ func (bs *bidValidator) ListBidsByItemID(itemID int64) ([]Bid, error) {
 		return nil, err
 	}
 
-	return bs.BidDB.ListBidsByItemID(itemID)
+	item, err := bs.itemService.Get(itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	bids, err := bs.BidDB.ListBidsByItemID(itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the amounts of sealed auctions are only revealed once the winner has been worked out
+	if item.Type.Sealed() && !item.closed() {
+		for n := range bids {
+			bids[n].Amount, bids[n].OriginalAmount = Money{}, nil
+		}
+	}
+
+	return bids, nil
 }
 
 func (bs *bidValidator) GetWinningBid(itemID int64) (Bid, error) {
@@ -77,7 +522,24 @@ func (bs *bidValidator) GetWinningBid(itemID int64) (Bid, error) {
 		return Bid{}, err
 	}
 
-	return bs.BidDB.GetWinningBid(itemID)
+	item, err := bs.itemService.Get(itemID)
+	if err != nil {
+		return Bid{}, err
+	}
+
+	if item.Type.Sealed() && !item.closed() {
+		return Bid{}, ErrSealed
+	}
+
+	// once the item is closed, the winner is the one frozen at closing time, if any
+	if item.WinningBidID != 0 {
+		return bs.BidDB.Get(item.WinningBidID)
+	}
+	if item.State == ItemCancelled {
+		return Bid{}, ErrNotFound
+	}
+
+	return bs.winningBid(item)
 }
 
 func (bs *bidValidator) ListBidsByUserID(userID int64) ([]Bid, error) {
@@ -89,7 +551,31 @@ func (bs *bidValidator) ListBidsByUserID(userID int64) ([]Bid, error) {
 		return nil, err
 	}
 
-	return bs.BidDB.ListBidsByUserID(userID)
+	bids, err := bs.BidDB.ListBidsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the amounts of sealed auctions are only revealed once the winner has been worked out, even to
+	// the users who placed them, like on the list of the bids of the item
+	sealed := map[int64]bool{}
+	for n, b := range bids {
+		s, ok := sealed[b.ItemID]
+		if !ok {
+			item, err := bs.itemService.Get(b.ItemID)
+			if err != nil && err != ErrNotFound {
+				return nil, err
+			}
+			s = err == nil && item.Type.Sealed() && !item.closed()
+			sealed[b.ItemID] = s
+		}
+
+		if s {
+			bids[n].Amount, bids[n].OriginalAmount = Money{}, nil
+		}
+	}
+
+	return bids, nil
 }
 
 type bidValFn func(b *Bid) error
@@ -116,14 +602,135 @@ func (bv *bidValidator) userExists() (string, bidValFn) {
 	}
 }
 
-func (bv *bidValidator) higherItemValue() (string, bidValFn) {
+// notSeller rejects the bids of the seller on their own item, which would push its price up.
+func (bv *bidValidator) notSeller() (string, bidValFn) {
+	return "user", func(b *Bid) error {
+		item, err := bv.itemService.Get(b.ItemID)
+		if err != nil {
+			return nil // reported by itemExists
+		}
+
+		if item.SellerID == b.UserID {
+			return ErrSelfBid
+		}
+
+		return nil
+	}
+}
+
+func (bv *bidValidator) itemOpen() (string, bidValFn) {
+	return "item", func(b *Bid) error {
+		item, err := bv.itemService.Get(b.ItemID)
+		if err != nil {
+			return err
+		}
+
+		if item.StateAt(bv.clock.Now()) != ItemOpen {
+			return ErrNotOpen
+		}
+
+		return nil
+	}
+}
+
+// acceptsBids rejects the bids on the auctions where the price is not set by the bidders.
+func (bv *bidValidator) acceptsBids() (string, bidValFn) {
 	return "item", func(b *Bid) error {
 		item, err := bv.itemService.Get(b.ItemID)
 		if err != nil {
 			return err
 		}
 
-		if b.Amount <= item.Value {
+		if item.Type == AuctionDutch {
+			return ErrUnsupported
+		}
+
+		return nil
+	}
+}
+
+// validQuantity checks that b does not request more units than the item has.
+func (bv *bidValidator) validQuantity() (string, bidValFn) {
+	return "quantity", func(b *Bid) error {
+		item, err := bv.itemService.Get(b.ItemID)
+		if err != nil {
+			return err
+		}
+
+		if b.Quantity < 0 || units(b.Quantity) > item.units() {
+			return ErrInvalid
+		}
+
+		return nil
+	}
+}
+
+// validAmount checks that the amount of b is valid, and converts it to the currency of the item if
+// needed, see convert.
+func (bv *bidValidator) validAmount() (string, bidValFn) {
+	return "amount", func(b *Bid) error {
+		if err := b.Amount.Validate(); err != nil {
+			return err
+		}
+
+		item, err := bv.itemService.Get(b.ItemID)
+		if err != nil {
+			return nil // reported by itemExists
+		}
+
+		return bv.convert(item, b)
+	}
+}
+
+// convert sets the amount of b to its original amount converted to the currency of item at the
+// current rate, keeping the original amount in b. It returns ErrCurrency if the currencies differ
+// and no rates are available.
+func (bv *bidValidator) convert(item Item, b *Bid) error {
+	original := b.Amount
+	if b.OriginalAmount != nil {
+		original = *b.OriginalAmount
+	}
+
+	if original.sameCurrency(item.Value) {
+		b.Amount, b.OriginalAmount = original, nil
+		return nil
+	}
+
+	if bv.rates == nil {
+		return ErrCurrency
+	}
+
+	rate, err := bv.rates.Rate(original.Currency, item.Value.Currency)
+	if err != nil {
+		return err
+	}
+
+	converted, err := original.Convert(item.Value.Currency, rate)
+	if err != nil {
+		return err
+	}
+
+	b.Amount, b.OriginalAmount = converted, &original
+	return nil
+}
+
+// beatsItemValue checks that b is better than the initial value of the item: higher on forward
+// auctions, lower on reverse auctions.
+func (bv *bidValidator) beatsItemValue() (string, bidValFn) {
+	return "item", func(b *Bid) error {
+		item, err := bv.itemService.Get(b.ItemID)
+		if err != nil {
+			return err
+		}
+
+		if !b.Amount.sameCurrency(item.Value) {
+			return nil // reported by validAmount
+		}
+
+		if !item.Direction.beats(b.Amount, item.Value) {
+			if item.Direction == AuctionReverse {
+				return ErrHighValue
+			}
 			return ErrLowValue
 		}
 
@@ -131,17 +738,90 @@ func (bv *bidValidator) higherItemValue() (string, bidValFn) {
 	}
 }
 
-func (bv *bidValidator) higherBidAmount() (string, bidValFn) {
+func (bv *bidValidator) beatsWinningBid() (string, bidValFn) {
 	return "bid", func(b *Bid) error {
-		winning, err := bv.BidDB.GetWinningBid(b.ItemID)
-		if err != nil && err != ErrNotFound {
+		item, err := bv.itemService.Get(b.ItemID)
+		if err != nil {
 			return err
 		}
 
-		if b.Amount <= winning.Amount {
-			return ErrLowValue
+		if !b.Amount.sameCurrency(item.Value) {
+			return nil // reported by validAmount
+		}
+
+		return bv.checkIncrement(item, b)
+	}
+}
+
+// winningBid returns the current winning bid of item: the highest one, or the lowest one on
+// reverse auctions.
+func (bv *bidValidator) winningBid(item Item) (Bid, error) {
+	if item.Direction == AuctionReverse {
+		return bv.BidDB.GetLowestBid(item.ID)
+	}
+	return bv.BidDB.GetWinningBid(item.ID)
+}
+
+// itemIncrement returns the minimum bid increment that applies to item.
+func (bv *bidValidator) itemIncrement(item Item) Increment {
+	if item.Increment != nil {
+		return *item.Increment
+	}
+	return bv.increment
+}
+
+// checkIncrement returns a BidTooLowError if b does not reach the current winning bid of item plus
+// the minimum increment, or a BidTooHighError if b does not go below the winning bid minus the
+// minimum increment on reverse auctions. On multi-unit items, b has to beat the lowest bid of the
+// other users that still gets units, and only once all units are taken. It does not apply to sealed
+// auctions. It does not use the item service, so it can be called while the item is locked.
+func (bv *bidValidator) checkIncrement(item Item, b *Bid) error {
+	// bids do not compete openly on sealed auctions
+	if item.Type.Sealed() {
+		return nil
+	}
+
+	winning, err := bv.winningBid(item)
+	if item.multiUnit() {
+		winning, err = bv.marginalBid(item, b.UserID)
+	}
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	inc := bv.itemIncrement(item)
+
+	if item.Direction == AuctionReverse {
+		max, err := inc.NextMaximum(winning.Amount)
+		if err != nil {
+			return err
 		}
 
+		cmp, err := b.Amount.Cmp(max)
+		if err != nil {
+			return err
+		}
+		if cmp > 0 {
+			return BidTooHighError{Maximum: max}
+		}
 		return nil
 	}
+
+	min, err := inc.NextMinimum(winning.Amount)
+	if err != nil {
+		return err
+	}
+
+	cmp, err := b.Amount.Cmp(min)
+	if err != nil {
+		return err
+	}
+	if cmp < 0 {
+		return BidTooLowError{Minimum: min}
+	}
+
+	return nil
 }
@@ -3,6 +3,7 @@ package models
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -10,7 +11,9 @@ import (
 type testBidDB struct {
 	BidDB
 	txCreate         func(*Bid) error
+	get              func(int64) (Bid, error)
 	getWinningBid    func(int64) (Bid, error)
+	getLowestBid     func(int64) (Bid, error)
 	listItemBids     func(int64) ([]Bid, error)
 	listBidsByUserID func(int64) ([]Bid, error)
 }
@@ -23,6 +26,18 @@ func (t *testBidDB) TxCreate(b *Bid) error {
 	return nil
 }
 
+func (t *testBidDB) TxRestore(itemID int64, bids []Bid) error {
+	return nil
+}
+
+func (t *testBidDB) Get(bidID int64) (Bid, error) {
+	if t.get != nil {
+		return t.get(bidID)
+	}
+
+	return Bid{}, nil
+}
+
 func (t *testBidDB) ListBidsByItemID(itemID int64) ([]Bid, error) {
 	if t.listItemBids != nil {
 		return t.listItemBids(itemID)
@@ -36,6 +51,14 @@ func (t *testBidDB) GetWinningBid(itemID int64) (Bid, error) {
 		return t.getWinningBid(itemID)
 	}
 
+	return Bid{}, ErrNotFound
+}
+
+func (t *testBidDB) GetLowestBid(itemID int64) (Bid, error) {
+	if t.getLowestBid != nil {
+		return t.getLowestBid(itemID)
+	}
+
 	return Bid{}, nil
 }
 
@@ -47,18 +70,38 @@ func (t *testBidDB) ListBidsByUserID(userID int64) ([]Bid, error) {
 	return nil, nil
 }
 
+// testNow is the fixed time returned by the clock of the services under test.
+var testNow = time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+func testClock() Clock {
+	return ClockFunc(func() time.Time { return testNow })
+}
+
+// testOpenItem returns an item that is open for bidding at testNow.
+func testOpenItem(value int64) Item {
+	return Item{
+		ID:       1,
+		Name:     "test",
+		Value:    gbp(value),
+		StartsAt: testNow.Add(-time.Hour),
+		EndsAt:   testNow.Add(time.Hour),
+		State:    ItemOpen,
+	}
+}
+
 func TestBidService_TxCreate(t *testing.T) {
 	tudb := &testUserDB{}
 	tidb := &testItemDB{}
 	tbdb := &testBidDB{}
 
 	db := CreateDatabase()
+	db.SetClock(testClock())
 	usvc := NewUserService(db)
 	isvc := NewItemService(db, usvc)
 	bsvc := NewBidService(db, isvc, usvc)
 
 	usvc.(userService).UserService.(*userCapsule).UserDB = tudb
-	isvc.(itemService).ItemService.(*itemCapsule).ItemDB = tidb
+	isvc.(itemService).ItemService.(*itemValidator).ItemDB = tidb
 	bsvc.(bidService).BidService.(*bidValidator).BidDB = tbdb
 
 	var cases = []struct {
@@ -70,8 +113,8 @@ func TestBidService_TxCreate(t *testing.T) {
 	}{
 		{
 			"ok",
-			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: 1},
-			&Bid{ID: 1, UserID: 1, ItemID: 1, Amount: 1},
+			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: gbp(1)},
+			&Bid{ID: 1, UserID: 1, ItemID: 1, Amount: gbp(1), Kind: BidManual, Status: BidActive, CreatedAt: testNow},
 			nil,
 			func(t *testing.T) {
 				tbdb.txCreate = func(b *Bid) error {
@@ -79,7 +122,7 @@ func TestBidService_TxCreate(t *testing.T) {
 					return nil
 				}
 				tidb.get = func(int64) (Item, error) {
-					return Item{ID: 1, Name: "test", Value: 0}, nil
+					return testOpenItem(0), nil
 				}
 				tudb.get = func(int64) (User, error) {
 					return User{ID: 1, Name: "test"}, nil
@@ -88,8 +131,8 @@ func TestBidService_TxCreate(t *testing.T) {
 		},
 		{
 			"winning_bid_not_found_but_ok",
-			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: 1},
-			&Bid{ID: 1, UserID: 1, ItemID: 1, Amount: 1},
+			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: gbp(1)},
+			&Bid{ID: 1, UserID: 1, ItemID: 1, Amount: gbp(1), Kind: BidManual, Status: BidActive, CreatedAt: testNow},
 			nil,
 			func(t *testing.T) {
 				tbdb.txCreate = func(b *Bid) error {
@@ -97,7 +140,7 @@ func TestBidService_TxCreate(t *testing.T) {
 					return nil
 				}
 				tidb.get = func(int64) (Item, error) {
-					return Item{ID: 1, Name: "test", Value: 0}, nil
+					return testOpenItem(0), nil
 				}
 				tudb.get = func(int64) (User, error) {
 					return User{ID: 1, Name: "test"}, nil
@@ -109,7 +152,7 @@ func TestBidService_TxCreate(t *testing.T) {
 		},
 		{
 			"item_not_found",
-			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: 1},
+			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: gbp(1)},
 			nil,
 			ValidationError{"item": ErrNotFound},
 			func(t *testing.T) {
@@ -124,7 +167,7 @@ func TestBidService_TxCreate(t *testing.T) {
 		},
 		{
 			"user_not_found",
-			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: 1},
+			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: gbp(1)},
 			nil,
 			ValidationError{"user": ErrNotFound},
 			func(t *testing.T) {
@@ -139,7 +182,7 @@ func TestBidService_TxCreate(t *testing.T) {
 		},
 		{
 			"winning_bid_higher_value",
-			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: 1},
+			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: gbp(1)},
 			nil,
 			ValidationError{"bid": ErrLowValue},
 			func(t *testing.T) {
@@ -148,19 +191,19 @@ func TestBidService_TxCreate(t *testing.T) {
 					return nil
 				}
 				tidb.get = func(int64) (Item, error) {
-					return Item{ID: 1, Name: "test", Value: 0}, nil
+					return testOpenItem(0), nil
 				}
 				tudb.get = func(int64) (User, error) {
 					return User{ID: 1, Name: "test"}, nil
 				}
 				tbdb.getWinningBid = func(int64) (Bid, error) {
-					return Bid{ID: 1, UserID: 1, ItemID: 1, Amount: 999}, nil
+					return Bid{ID: 1, UserID: 1, ItemID: 1, Amount: gbp(999)}, nil
 				}
 			},
 		},
 		{
 			"got_item_higher_value",
-			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: 1},
+			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: gbp(1)},
 			nil,
 			ValidationError{"item": ErrLowValue},
 			func(t *testing.T) {
@@ -169,7 +212,174 @@ func TestBidService_TxCreate(t *testing.T) {
 					return nil
 				}
 				tidb.get = func(int64) (Item, error) {
-					return Item{ID: 1, Name: "test", Value: 999}, nil
+					return testOpenItem(999), nil
+				}
+				tudb.get = func(int64) (User, error) {
+					return User{ID: 1, Name: "test"}, nil
+				}
+			},
+		},
+		{
+			"currency_mismatch",
+			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: Money{Amount: 1000, Currency: "EUR"}},
+			nil,
+			ValidationError{"amount": ErrCurrency},
+			func(t *testing.T) {
+				tidb.get = func(int64) (Item, error) {
+					return testOpenItem(0), nil
+				}
+				tudb.get = func(int64) (User, error) {
+					return User{ID: 1, Name: "test"}, nil
+				}
+			},
+		},
+		{
+			"invalid_currency",
+			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: Money{Amount: 1000, Currency: "XYZ"}},
+			nil,
+			ValidationError{"amount.currency": ErrInvalid},
+			func(t *testing.T) {
+				tidb.get = func(int64) (Item, error) {
+					return testOpenItem(0), nil
+				}
+				tudb.get = func(int64) (User, error) {
+					return User{ID: 1, Name: "test"}, nil
+				}
+			},
+		},
+		{
+			"below_global_increment",
+			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: gbp(1009)},
+			nil,
+			ValidationError{"bid": BidTooLowError{Minimum: gbp(1010)}},
+			func(t *testing.T) {
+				bsvc.(bidService).BidService.(*bidValidator).increment = Increment{Type: IncrementFixed, Amount: 10}
+				tidb.get = func(int64) (Item, error) {
+					return testOpenItem(0), nil
+				}
+				tudb.get = func(int64) (User, error) {
+					return User{ID: 1, Name: "test"}, nil
+				}
+				tbdb.getWinningBid = func(int64) (Bid, error) {
+					return Bid{ID: 1, UserID: 2, ItemID: 1, Amount: gbp(1000)}, nil
+				}
+			},
+		},
+		{
+			"below_item_increment",
+			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: gbp(1020)},
+			nil,
+			ValidationError{"bid": BidTooLowError{Minimum: gbp(1100)}},
+			func(t *testing.T) {
+				tidb.get = func(int64) (Item, error) {
+					i := testOpenItem(0)
+					i.Increment = &Increment{Type: IncrementTiered, Tiers: []IncrementTier{{Below: 1000, Step: 25}, {Step: 100}}}
+					return i, nil
+				}
+				tudb.get = func(int64) (User, error) {
+					return User{ID: 1, Name: "test"}, nil
+				}
+				tbdb.getWinningBid = func(int64) (Bid, error) {
+					return Bid{ID: 1, UserID: 2, ItemID: 1, Amount: gbp(1000)}, nil
+				}
+			},
+		},
+		{
+			"outbid_before_storing",
+			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: gbp(1001)},
+			nil,
+			ValidationError{"bid": BidTooLowError{Minimum: gbp(1006)}},
+			func(t *testing.T) {
+				tbdb.txCreate = func(b *Bid) error {
+					t.Fatal("the bid must not be stored")
+					return nil
+				}
+				tidb.get = func(int64) (Item, error) {
+					return testOpenItem(0), nil
+				}
+				tudb.get = func(int64) (User, error) {
+					return User{ID: 1, Name: "test"}, nil
+				}
+				winning := Bid{ID: 1, UserID: 2, ItemID: 1, Amount: gbp(1000)}
+				tbdb.getWinningBid = func(int64) (Bid, error) {
+					return winning, nil
+				}
+				tidb.txUpdate = func(id int64, fn func(*Item) error) error {
+					winning.Amount = gbp(1005) // another bid got in after the validation
+					i := testOpenItem(0)
+					return fn(&i)
+				}
+			},
+		},
+		{
+			"item_not_open_yet",
+			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: gbp(1)},
+			nil,
+			ValidationError{"item": ErrNotOpen},
+			func(t *testing.T) {
+				tidb.get = func(int64) (Item, error) {
+					i := testOpenItem(0)
+					i.StartsAt, i.State = testNow.Add(time.Minute), ItemScheduled
+					return i, nil
+				}
+				tudb.get = func(int64) (User, error) {
+					return User{ID: 1, Name: "test"}, nil
+				}
+			},
+		},
+		{
+			"item_ended",
+			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: gbp(1)},
+			nil,
+			ValidationError{"item": ErrNotOpen},
+			func(t *testing.T) {
+				tidb.get = func(int64) (Item, error) {
+					i := testOpenItem(0)
+					i.EndsAt = testNow // the end time is not part of the open window
+					return i, nil
+				}
+				tudb.get = func(int64) (User, error) {
+					return User{ID: 1, Name: "test"}, nil
+				}
+			},
+		},
+		{
+			"item_closed_before_storing",
+			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: gbp(1)},
+			nil,
+			ValidationError{"item": ErrNotOpen},
+			func(t *testing.T) {
+				tbdb.txCreate = func(b *Bid) error {
+					t.Fatal("the bid must not be stored")
+					return nil
+				}
+				tidb.get = func(int64) (Item, error) {
+					return testOpenItem(0), nil
+				}
+				tidb.txUpdate = func(id int64, fn func(*Item) error) error {
+					i := testOpenItem(0)
+					i.State = ItemClosed
+					return fn(&i)
+				}
+				tudb.get = func(int64) (User, error) {
+					return User{ID: 1, Name: "test"}, nil
+				}
+			},
+		},
+		{
+			"seller_bids_on_own_item",
+			&Bid{ID: 0, UserID: 1, ItemID: 1, Amount: gbp(1)},
+			nil,
+			ValidationError{"user": ErrSelfBid},
+			func(t *testing.T) {
+				tbdb.txCreate = func(b *Bid) error {
+					t.Fatal("the bid must not be stored")
+					return nil
+				}
+				tidb.get = func(int64) (Item, error) {
+					i := testOpenItem(0)
+					i.SellerID = 1
+					return i, nil
 				}
 				tudb.get = func(int64) (User, error) {
 					return User{ID: 1, Name: "test"}, nil
@@ -197,6 +407,7 @@ func TestBidService_TxCreate(t *testing.T) {
 			*tudb = testUserDB{}
 			*tidb = testItemDB{}
 			*tbdb = testBidDB{}
+			bsvc.(bidService).BidService.(*bidValidator).increment = DefaultIncrement
 		})
 	}
 }
@@ -209,7 +420,7 @@ func TestBidService_GetWinningBid(t *testing.T) {
 	isvc := NewItemService(db, nil)
 	bsvc := NewBidService(db, isvc, nil)
 
-	isvc.(itemService).ItemService.(*itemCapsule).ItemDB = tidb
+	isvc.(itemService).ItemService.(*itemValidator).ItemDB = tidb
 	bsvc.(bidService).BidService.(*bidValidator).BidDB = tbdb
 
 	var cases = []struct {
@@ -222,14 +433,14 @@ func TestBidService_GetWinningBid(t *testing.T) {
 		{
 			"ok",
 			1,
-			Bid{ID: 1, UserID: 1, ItemID: 1, Amount: 777},
+			Bid{ID: 1, UserID: 1, ItemID: 1, Amount: gbp(777)},
 			nil,
 			func(t *testing.T) {
 				tbdb.getWinningBid = func(int64) (Bid, error) {
-					return Bid{ID: 1, UserID: 1, ItemID: 1, Amount: 777}, nil
+					return Bid{ID: 1, UserID: 1, ItemID: 1, Amount: gbp(777)}, nil
 				}
 				tidb.get = func(int64) (Item, error) {
-					return Item{ID: 1, Name: "test", Value: 0}, nil
+					return Item{ID: 1, Name: "test", Value: gbp(0)}, nil
 				}
 			},
 		},
@@ -274,9 +485,9 @@ func TestBidService_ListBidsByItemID(t *testing.T) {
 
 	db := CreateDatabase()
 	isvc := NewItemService(db, nil)
-	bsvc := NewBidService(db, tidb, nil)
+	bsvc := NewBidService(db, isvc, nil)
 
-	isvc.(itemService).ItemService.(*itemCapsule).ItemDB = tidb
+	isvc.(itemService).ItemService.(*itemValidator).ItemDB = tidb
 	bsvc.(bidService).BidService.(*bidValidator).BidDB = tbdb
 
 	var cases = []struct {
@@ -290,19 +501,19 @@ func TestBidService_ListBidsByItemID(t *testing.T) {
 			"ok",
 			1,
 			[]Bid{
-				{ID: 1, UserID: 1, ItemID: 1, Amount: 1},
-				{ID: 2, UserID: 2, ItemID: 1, Amount: 2},
+				{ID: 1, UserID: 1, ItemID: 1, Amount: gbp(1)},
+				{ID: 2, UserID: 2, ItemID: 1, Amount: gbp(2)},
 			},
 			nil,
 			func(t *testing.T) {
 				tbdb.listItemBids = func(int64) ([]Bid, error) {
 					return []Bid{
-						{ID: 1, UserID: 1, ItemID: 1, Amount: 1},
-						{ID: 2, UserID: 2, ItemID: 1, Amount: 2},
+						{ID: 1, UserID: 1, ItemID: 1, Amount: gbp(1)},
+						{ID: 2, UserID: 2, ItemID: 1, Amount: gbp(2)},
 					}, nil
 				}
 				tidb.get = func(int64) (Item, error) {
-					return Item{ID: 1, Name: "test", Value: 0}, nil
+					return Item{ID: 1, Name: "test", Value: gbp(0)}, nil
 				}
 			},
 		},
@@ -343,12 +554,15 @@ func TestBidService_ListBidsByItemID(t *testing.T) {
 
 func TestBidService_ListBidsByUserID(t *testing.T) {
 	tudb := &testUserDB{}
+	tidb := &testItemDB{}
 	tbdb := &testBidDB{}
 
 	db := CreateDatabase()
 	usvc := NewUserService(db)
-	bsvc := NewBidService(db, nil, usvc)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc)
 
+	isvc.(itemService).ItemService.(*itemValidator).ItemDB = tidb
 	bsvc.(bidService).BidService.(*bidValidator).BidDB = tbdb
 	usvc.(userService).UserService.(*userCapsule).UserDB = tudb
 
@@ -363,20 +577,49 @@ func TestBidService_ListBidsByUserID(t *testing.T) {
 			"ok",
 			1,
 			[]Bid{
-				{ID: 1, UserID: 1, ItemID: 1, Amount: 1},
-				{ID: 2, UserID: 2, ItemID: 1, Amount: 2},
+				{ID: 1, UserID: 1, ItemID: 1, Amount: gbp(1)},
+				{ID: 2, UserID: 2, ItemID: 1, Amount: gbp(2)},
 			},
 			nil,
 			func(t *testing.T) {
 				tbdb.listBidsByUserID = func(int64) ([]Bid, error) {
 					return []Bid{
-						{ID: 1, UserID: 1, ItemID: 1, Amount: 1},
-						{ID: 2, UserID: 2, ItemID: 1, Amount: 2},
+						{ID: 1, UserID: 1, ItemID: 1, Amount: gbp(1)},
+						{ID: 2, UserID: 2, ItemID: 1, Amount: gbp(2)},
 					}, nil
 				}
 				tudb.get = func(int64) (User, error) {
 					return User{ID: 1, Name: "test"}, nil
 				}
+				tidb.get = func(int64) (Item, error) {
+					return Item{ID: 1, Name: "test", Value: gbp(0)}, nil
+				}
+			},
+		},
+		{
+			"sealed_amounts_hidden",
+			1,
+			[]Bid{
+				{ID: 1, UserID: 1, ItemID: 1},
+				{ID: 2, UserID: 1, ItemID: 2, Amount: gbp(2)},
+			},
+			nil,
+			func(t *testing.T) {
+				tbdb.listBidsByUserID = func(int64) ([]Bid, error) {
+					return []Bid{
+						{ID: 1, UserID: 1, ItemID: 1, Amount: gbp(1), OriginalAmount: &Money{Amount: 1, Currency: "EUR"}},
+						{ID: 2, UserID: 1, ItemID: 2, Amount: gbp(2)},
+					}, nil
+				}
+				tudb.get = func(int64) (User, error) {
+					return User{ID: 1, Name: "test"}, nil
+				}
+				tidb.get = func(itemID int64) (Item, error) {
+					if itemID == 1 {
+						return Item{ID: 1, Name: "open", Value: gbp(0), Type: AuctionVickrey, State: ItemOpen}, nil
+					}
+					return Item{ID: 2, Name: "closed", Value: gbp(0), Type: AuctionSealedFirstPrice, State: ItemClosed}, nil
+				}
 			},
 		},
 		{
@@ -408,6 +651,7 @@ func TestBidService_ListBidsByUserID(t *testing.T) {
 				assert.Equal(t, tt.outbids, bids)
 			}
 
+			*tidb = testItemDB{}
 			*tbdb = testBidDB{}
 			*tudb = testUserDB{}
 		})
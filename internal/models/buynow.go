@@ -0,0 +1,73 @@
+package models
+
+// TxBuyNow buys the item of b at its buy-now price on behalf of the user of b. The purchase is stored
+// as the winning bid and the item is closed straight away.
+func (bs *bidValidator) TxBuyNow(b *Bid) error {
+	if err := bs.runValFuncs(b,
+		bs.itemExists,
+		bs.userExists,
+		bs.notSeller,
+		bs.itemOpen,
+	); err != nil {
+		return err
+	}
+
+	// the item is locked while buying it, so no bid can be placed in the meantime
+	return bs.txBidding(b.ItemID, func(i *Item) error {
+		now := bs.clock.Now()
+
+		if i.StateAt(now) != ItemOpen {
+			return ValidationError{"item": ErrNotOpen}
+		}
+
+		if i.BuyNowPrice == nil {
+			return ValidationError{"item": ErrNoBuyNow}
+		}
+
+		b.Amount = *i.BuyNowPrice
+		b.Kind = BidBuyNow
+		if err := bs.hold(*i, b.UserID, b.Amount, true); err != nil {
+			return err
+		}
+		if err := bs.storeBid(b); err != nil {
+			return err
+		}
+
+		sellTo(i, *b, now)
+
+		// the item is not advanced anymore once closed, so the funds held by the other users, such
+		// as the proxies bidding above the price, are released now
+		return bs.releaseHolds(*i)
+	})
+}
+
+// withdrawBuyNow removes the buy-now option of item i, which must be locked, once the winning bid
+// exceeds the threshold of the buy-now price.
+func (bs *bidValidator) withdrawBuyNow(i *Item) error {
+	if i.BuyNowPrice == nil {
+		return nil
+	}
+
+	winning, err := bs.BidDB.GetWinningBid(i.ID)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	threshold, err := i.BuyNowPrice.Percent(int64(bs.buyNowThreshold))
+	if err != nil {
+		return err
+	}
+
+	cmp, err := winning.Amount.Cmp(threshold)
+	if err != nil {
+		return err
+	}
+	if cmp > 0 {
+		i.BuyNowPrice = nil
+	}
+
+	return nil
+}
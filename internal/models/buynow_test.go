@@ -0,0 +1,119 @@
+package models
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithBuyNowThreshold(t *testing.T) {
+	for percent, valid := range map[int]bool{-1: false, 0: true, 50: true, 100: true, 101: false} {
+		if valid {
+			assert.NoError(t, ValidBuyNowThreshold(percent))
+			assert.NotPanics(t, func() { WithBuyNowThreshold(percent) })
+			continue
+		}
+
+		assert.Equal(t, ValidationError{"buyNowThreshold": ErrInvalid}, ValidBuyNowThreshold(percent))
+		assert.Panics(t, func() { WithBuyNowThreshold(percent) })
+	}
+}
+
+func TestBidService_TxBuyNow(t *testing.T) {
+	var cases = []struct {
+		name      string
+		threshold int
+		bids      []int64
+		outerr    error
+	}{
+		{"ok", 0, nil, nil},
+		{"withdrawn_by_first_bid", 0, []int64{20}, ValidationError{"item": ErrNoBuyNow}},
+		{"below_threshold", 50, []int64{20, 50}, nil},
+		{"above_threshold", 50, []int64{20, 51}, ValidationError{"item": ErrNoBuyNow}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			db := CreateDatabase()
+			db.SetClock(testClock())
+
+			usvc := NewUserService(db)
+			isvc := NewItemService(db, usvc)
+			bsvc := NewBidService(db, isvc, usvc, WithBuyNowThreshold(tt.threshold))
+
+			usvc.TxCreate(&User{Name: "Morty"})
+			usvc.TxCreate(&User{Name: "Rick"})
+
+			item := Item{Name: "portal gun", SellerID: testSeller(usvc), Value: gbp(10), BuyNowPrice: gbpPtr(100)}
+			assert.NoError(t, isvc.TxCreate(&item))
+
+			for _, amount := range tt.bids {
+				assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: item.ID, Amount: gbp(amount)}))
+			}
+
+			bid := Bid{UserID: 1, ItemID: item.ID}
+			err := bsvc.TxBuyNow(&bid)
+
+			if tt.outerr != nil {
+				assert.Error(t, err)
+				assert.True(t, errors.Is(err, tt.outerr), "errors must match, expected %v, got %v", tt.outerr, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, Bid{ID: int64(len(tt.bids) + 1), UserID: 1, ItemID: item.ID, Amount: gbp(100), Kind: BidBuyNow, Status: BidActive, CreatedAt: testNow}, bid)
+
+			item, _ = isvc.Get(item.ID)
+			assert.Equal(t, ItemClosed, item.State)
+			assert.Equal(t, testNow, item.EndsAt)
+			assert.Equal(t, bid.ID, item.WinningBidID)
+			assert.True(t, item.Sold)
+
+			winning, err := bsvc.GetWinningBid(item.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, bid, winning)
+
+			err = bsvc.TxCreate(&Bid{UserID: 2, ItemID: item.ID, Amount: gbp(1000)})
+			assert.True(t, errors.Is(err, ValidationError{"item": ErrNotOpen}))
+		})
+	}
+}
+
+func TestBidService_TxBuyNow_Concurrent(t *testing.T) {
+	db := CreateDatabase()
+	db.SetClock(testClock())
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc, WithBuyNowThreshold(100))
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+
+	item := Item{Name: "portal gun", SellerID: testSeller(usvc), Value: gbp(0), BuyNowPrice: gbpPtr(1000)}
+	assert.NoError(t, isvc.TxCreate(&item))
+
+	var wg sync.WaitGroup
+	for n := 1; n <= 50; n++ {
+		wg.Add(1)
+		go func(amount int64) {
+			defer wg.Done()
+			bsvc.TxCreate(&Bid{UserID: 2, ItemID: item.ID, Amount: gbp(amount)})
+		}(int64(n))
+	}
+
+	bought := Bid{UserID: 1, ItemID: item.ID}
+	assert.NoError(t, bsvc.TxBuyNow(&bought))
+	wg.Wait()
+
+	// no bid can be stored once the item has been bought
+	bids, _ := bsvc.ListBidsByItemID(item.ID)
+	for _, b := range bids {
+		assert.LessOrEqual(t, b.ID, bought.ID)
+	}
+
+	winning, err := bsvc.GetWinningBid(item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, bought, winning)
+}
@@ -0,0 +1,302 @@
+package models
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	// MaxCategoryDepth is the number of levels of the category tree.
+	MaxCategoryDepth = 5
+	// MaxTags is the number of tags an item may carry.
+	MaxTags = 10
+
+	maxLabel = 50
+)
+
+// normalizeCategory returns the category c in its canonical form, e.g. "Electronics / Phones" becomes
+// "electronics/phones". Categories are paths of the category tree, from its root to the category.
+func normalizeCategory(c string) (string, error) {
+	c = strings.Trim(strings.TrimSpace(c), "/")
+	if c == "" {
+		return "", nil
+	}
+
+	levels := strings.Split(c, "/")
+	if len(levels) > MaxCategoryDepth {
+		return "", ErrInvalid
+	}
+
+	for n, l := range levels {
+		l, err := normalizeLabel(l)
+		if err != nil {
+			return "", err
+		}
+		levels[n] = l
+	}
+
+	return strings.Join(levels, "/"), nil
+}
+
+// normalizeTags returns tags in their canonical form: lower case, sorted and without duplicates.
+func normalizeTags(tags []string) ([]string, error) {
+	seen := map[string]bool{}
+	var ret []string
+	for _, t := range tags {
+		t, err := normalizeLabel(t)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[t] {
+			seen[t] = true
+			ret = append(ret, t)
+		}
+	}
+
+	if len(ret) > MaxTags {
+		return nil, ErrInvalid
+	}
+
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// normalizeLabel returns a category level or a tag in lower case, without surrounding spaces. It
+// cannot be empty nor hold a slash.
+func normalizeLabel(l string) (string, error) {
+	l = strings.ToLower(strings.TrimSpace(l))
+	if l == "" || len(l) > maxLabel || strings.Contains(l, "/") {
+		return "", ErrInvalid
+	}
+	return l, nil
+}
+
+// categoryPath returns the category c, which must be normalized, preceded by its parents, from the
+// root of the tree: "a/b/c" gives "a", "a/b" and "a/b/c".
+func categoryPath(c string) []string {
+	if c == "" {
+		return nil
+	}
+
+	var path []string
+	for n := range c {
+		if c[n] == '/' {
+			path = append(path, c[:n])
+		}
+	}
+	return append(path, c)
+}
+
+func (iv *itemValidator) validCategory() (string, itemValFn) {
+	return "category", func(i *Item) error {
+		c, err := normalizeCategory(i.Category)
+		if err != nil {
+			return err
+		}
+		i.Category = c
+		return nil
+	}
+}
+
+func (iv *itemValidator) validTags() (string, itemValFn) {
+	return "tags", func(i *Item) error {
+		tags, err := normalizeTags(i.Tags)
+		if err != nil {
+			return err
+		}
+		i.Tags = tags
+		return nil
+	}
+}
+
+// ItemFilter selects the items listed by ListItemsByFilter. Every filter set must match, and the zero
+// value selects every item.
+type ItemFilter struct {
+	// Category selects the items of the category and of its subcategories.
+	Category string
+	// Tags selects the items carrying all of them.
+	Tags []string
+	// MinPrice and MaxPrice select the items whose winning bid is within the range, bounds included.
+	// Items without a winning bid, in another currency, or whose bids are sealed, are left out.
+	MinPrice *Money
+	MaxPrice *Money
+	// HasBids selects the items with active bids, or without any if it is false.
+	HasBids *bool
+}
+
+// ListItemsByFilter lists the items selected by f, sorted by identification number. The items are
+// looked up in the indexes of the storage: the category and tag indexes of the items, and the index
+// of the best bids of every item, which also gives the price of the winning bid.
+func (iv *itemValidator) ListItemsByFilter(f ItemFilter) ([]Item, error) {
+	if err := iv.normalizeFilter(&f); err != nil {
+		return nil, err
+	}
+
+	// nil means every item, until a filter narrows them down
+	var ids map[int64]bool
+	narrow := func(matching []int64) {
+		next := make(map[int64]bool, len(matching))
+		for _, id := range matching {
+			if ids == nil || ids[id] {
+				next[id] = true
+			}
+		}
+		ids = next
+	}
+
+	if f.Category != "" {
+		narrow(iv.ItemDB.ListItemIDsByCategory(f.Category))
+	}
+	for _, t := range f.Tags {
+		narrow(iv.ItemDB.ListItemIDsByTag(t))
+	}
+
+	withBids := iv.bids.ListItemIDsWithBids()
+	switch {
+	case f.HasBids != nil && !*f.HasBids:
+		if ids == nil {
+			ids = make(map[int64]bool)
+			for _, i := range iv.ItemDB.ListItems() {
+				ids[i.ID] = true
+			}
+		}
+		for _, id := range withBids {
+			delete(ids, id)
+		}
+	case f.HasBids != nil || f.MinPrice != nil || f.MaxPrice != nil:
+		// only items with bids have a winning bid
+		narrow(withBids)
+	}
+
+	var items []Item
+	if ids == nil {
+		items = iv.ItemDB.ListItems()
+	} else {
+		list := make([]int64, 0, len(ids))
+		for id := range ids {
+			list = append(list, id)
+		}
+
+		var err error
+		if items, err = iv.ItemDB.ListItemsByIDs(list...); err != nil {
+			return nil, err
+		}
+	}
+
+	ret := []Item{}
+	for _, i := range items {
+		ok, err := iv.priceMatches(i, f)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ret = append(ret, i)
+		}
+	}
+
+	sort.Slice(ret, func(a, b int) bool { return ret[a].ID < ret[b].ID })
+	return ret, nil
+}
+
+// normalizeFilter checks f, putting its category and tags in their canonical form.
+func (iv *itemValidator) normalizeFilter(f *ItemFilter) error {
+	ve := ValidationError{}
+
+	var err error
+	if f.Category, err = normalizeCategory(f.Category); err != nil {
+		ve["category"] = ErrInvalid
+	}
+
+	// the number of tags of a filter is not limited, it simply matches nothing
+	for n, t := range f.Tags {
+		if f.Tags[n], err = normalizeLabel(t); err != nil {
+			ve["tags"] = ErrInvalid
+		}
+	}
+
+	for field, p := range map[string]*Money{"minPrice": f.MinPrice, "maxPrice": f.MaxPrice} {
+		if p == nil {
+			continue
+		}
+		if err, ok := p.Validate().(ValidationError); ok {
+			// the amount is reported as the bound, and the currency as is
+			for k, e := range err {
+				if k == "amount" {
+					k = field
+				}
+				ve[k] = e
+			}
+		}
+	}
+
+	if len(ve) == 0 && f.MinPrice != nil && f.MaxPrice != nil {
+		if cmp, err := f.MinPrice.Cmp(*f.MaxPrice); err != nil {
+			ve["maxPrice"] = ErrCurrency
+		} else if cmp > 0 {
+			ve["maxPrice"] = ErrInvalid
+		}
+	}
+
+	if len(ve) > 0 {
+		return ve
+	}
+	return nil
+}
+
+// priceMatches reports whether the winning bid of item i is within the price range of f.
+func (iv *itemValidator) priceMatches(i Item, f ItemFilter) (bool, error) {
+	if f.MinPrice == nil && f.MaxPrice == nil {
+		return true, nil
+	}
+
+	amount, ok, err := iv.winningAmount(i)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	for _, bound := range []struct {
+		price *Money
+		sign  int
+	}{{f.MinPrice, -1}, {f.MaxPrice, 1}} {
+		if bound.price == nil {
+			continue
+		}
+
+		cmp, err := amount.Cmp(*bound.price)
+		if err != nil || cmp == bound.sign {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// winningAmount returns the amount of the winning bid of item i from the index of the best bids, and
+// false if it has none or its bids are sealed.
+func (iv *itemValidator) winningAmount(i Item) (Money, bool, error) {
+	if i.Type.Sealed() && !i.closed() {
+		return Money{}, false, nil
+	}
+
+	var winning Bid
+	var err error
+	switch {
+	case i.WinningBidID != 0:
+		winning, err = iv.bids.Get(i.WinningBidID)
+	case i.closed():
+		return Money{}, false, nil
+	case i.Direction == AuctionReverse:
+		winning, err = iv.bids.GetLowestBid(i.ID)
+	default:
+		winning, err = iv.bids.GetWinningBid(i.ID)
+	}
+
+	if err == ErrNotFound {
+		return Money{}, false, nil
+	}
+	if err != nil {
+		return Money{}, false, err
+	}
+
+	return winning.Amount, true, nil
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestItemService_Categories(t *testing.T) {
+	db := CreateDatabase()
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	seller := testSeller(usvc)
+
+	var cases = []struct {
+		name     string
+		category string
+		tags     []string
+		outcat   string
+		outtags  []string
+		outerr   error
+	}{
+		{"none", "", nil, "", nil, nil},
+		{"normalized", " Electronics / Phones/ ", []string{"Vintage", " boxed", "vintage"}, "electronics/phones", []string{"boxed", "vintage"}, nil},
+		{"empty level", "electronics//phones", nil, "", nil, ValidationError{"category": ErrInvalid}},
+		{"too deep", "a/b/c/d/e/f", nil, "", nil, ValidationError{"category": ErrInvalid}},
+		{"empty tag", "", []string{" "}, "", nil, ValidationError{"tags": ErrInvalid}},
+		{"tag with slash", "", []string{"a/b"}, "", nil, ValidationError{"tags": ErrInvalid}},
+		{"too many tags", "", []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"}, "", nil, ValidationError{"tags": ErrInvalid}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			i := Item{Name: "phone", SellerID: seller, Value: gbp(10), Category: tt.category, Tags: tt.tags}
+			err := isvc.TxCreate(&i)
+			assert.Equal(t, tt.outerr, err)
+			if err != nil {
+				return
+			}
+
+			assert.Equal(t, tt.outcat, i.Category)
+			assert.Equal(t, tt.outtags, i.Tags)
+		})
+	}
+}
+
+func TestItemService_ListItemsByFilter(t *testing.T) {
+	db := CreateDatabase()
+	db.SetClock(testClock())
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	seller := testSeller(usvc)
+
+	items := []Item{
+		{Name: "phone", Category: "electronics/phones", Tags: []string{"vintage", "boxed"}},
+		{Name: "radio", Category: "electronics", Tags: []string{"vintage"}},
+		{Name: "chair", Category: "furniture"},
+		{Name: "sealed phone", Category: "electronics/phones", Type: AuctionSealedFirstPrice},
+		{Name: "tender", Category: "services", Direction: AuctionReverse, Value: gbp(100)},
+		{Name: "euro radio", Category: "electronics", Value: Money{Amount: 10, Currency: "EUR"}},
+	}
+	for n := range items {
+		items[n].SellerID = seller
+		if items[n].Value.IsZero() {
+			items[n].Value = gbp(10)
+		}
+		assert.NoError(t, isvc.TxCreate(&items[n]))
+	}
+	phone, radio, chair, sealed, tender, euro := items[0], items[1], items[2], items[3], items[4], items[5]
+
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: phone.ID, Amount: gbp(20)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: phone.ID, Amount: gbp(50)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: radio.ID, Amount: gbp(15)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: sealed.ID, Amount: gbp(30)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: tender.ID, Amount: gbp(90)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: tender.ID, Amount: gbp(40)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: euro.ID, Amount: Money{Amount: 20, Currency: "EUR"}}))
+
+	yes, no := true, false
+
+	var cases = []struct {
+		name     string
+		filter   ItemFilter
+		outitems []Item
+		outerr   error
+	}{
+		{"no filter", ItemFilter{}, items, nil},
+		{"category", ItemFilter{Category: "Electronics"}, []Item{phone, radio, sealed, euro}, nil},
+		{"subcategory", ItemFilter{Category: "electronics/phones"}, []Item{phone, sealed}, nil},
+		{"unknown category", ItemFilter{Category: "toys"}, []Item{}, nil},
+		{"tag", ItemFilter{Tags: []string{"vintage"}}, []Item{phone, radio}, nil},
+		{"every tag", ItemFilter{Tags: []string{"vintage", "BOXED"}}, []Item{phone}, nil},
+		{"category and tag", ItemFilter{Category: "electronics", Tags: []string{"boxed"}}, []Item{phone}, nil},
+		{"with bids", ItemFilter{HasBids: &yes}, []Item{phone, radio, sealed, tender, euro}, nil},
+		{"without bids", ItemFilter{HasBids: &no}, []Item{chair}, nil},
+		{"category without bids", ItemFilter{Category: "furniture", HasBids: &no}, []Item{chair}, nil},
+		// sealed bids and bids in other currencies are left out of price ranges
+		{"min price", ItemFilter{MinPrice: gbpPtr(20)}, []Item{phone, tender}, nil},
+		{"max price", ItemFilter{MaxPrice: gbpPtr(40)}, []Item{radio, tender}, nil},
+		{"price range", ItemFilter{MinPrice: gbpPtr(15), MaxPrice: gbpPtr(15)}, []Item{radio}, nil},
+		{"price and category", ItemFilter{Category: "electronics", MaxPrice: gbpPtr(100)}, []Item{phone, radio}, nil},
+		{"invalid category", ItemFilter{Category: "a//b"}, nil, ValidationError{"category": ErrInvalid}},
+		{"invalid tag", ItemFilter{Tags: []string{""}}, nil, ValidationError{"tags": ErrInvalid}},
+		{"no currency", ItemFilter{MinPrice: &Money{Amount: 10}}, nil, ValidationError{"currency": ErrRequired}},
+		{"negative price", ItemFilter{MaxPrice: gbpPtr(-1)}, nil, ValidationError{"maxPrice": ErrInvalid}},
+		{"inverted range", ItemFilter{MinPrice: gbpPtr(20), MaxPrice: gbpPtr(10)}, nil, ValidationError{"maxPrice": ErrInvalid}},
+		{"currencies differ", ItemFilter{MinPrice: gbpPtr(10), MaxPrice: &Money{Amount: 20, Currency: "EUR"}}, nil, ValidationError{"maxPrice": ErrCurrency}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := isvc.ListItemsByFilter(tt.filter)
+			assert.Equal(t, tt.outerr, err)
+			assert.Equal(t, tt.outitems, got)
+		})
+	}
+
+	// closed items are priced with the winning bid they were frozen with
+	assert.NoError(t, isvc.TxUpdate(sealed.ID, func(i *Item) error {
+		i.EndsAt = testNow.Add(-time.Minute)
+		i.State = ItemClosed
+		i.WinningBidID = 4
+		return nil
+	}))
+	got, err := isvc.ListItemsByFilter(ItemFilter{MinPrice: gbpPtr(30), MaxPrice: gbpPtr(30)})
+	assert.NoError(t, err)
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, sealed.ID, got[0].ID)
+	}
+}
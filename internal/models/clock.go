@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// Clock is the source of the current time used by the services. Swapping it allows the auction
+// lifecycle to be driven deterministically (e.g. in tests).
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts an ordinary function to the Clock interface.
+type ClockFunc func() time.Time
+
+// Now returns the result of calling f.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is the Clock backed by the wall clock of the machine.
+var SystemClock Clock = ClockFunc(time.Now)
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"time"
+)
+
+// DutchSchedule is the way the price of a Dutch auction drops: starting at the initial value of the
+// item, it goes down by Decrement every Interval, but never below Floor. Like the increments, the
+// decrement is in the minor units of the currency of the item.
+type DutchSchedule struct {
+	Decrement int64    `json:"decrement"`
+	Interval  Duration `json:"interval"`
+	Floor     Money    `json:"floor"`
+}
+
+// PriceAt returns the price asked at time t on the Dutch auction of i. It is the initial value of
+// the item for any other auction type.
+func (i Item) PriceAt(t time.Time) Money {
+	if i.Type != AuctionDutch || i.Dutch == nil || !t.After(i.StartsAt) {
+		return i.Value
+	}
+
+	drops := int64(t.Sub(i.StartsAt) / time.Duration(i.Dutch.Interval))
+
+	// checked before multiplying, so a long running auction cannot overflow the price
+	if drops > (i.Value.Amount-i.Dutch.Floor.Amount)/i.Dutch.Decrement {
+		return i.Dutch.Floor
+	}
+
+	return Money{Amount: i.Value.Amount - drops*i.Dutch.Decrement, Currency: i.Value.Currency}
+}
+
+// Validate checks that ds is a well formed schedule for an item with the given initial value. It
+// returns a ValidationError with the offending fields.
+func (ds DutchSchedule) Validate(value Money) error {
+	ve := ValidationError{}
+
+	if ds.Decrement <= 0 {
+		ve["decrement"] = ErrInvalid
+	}
+
+	if ds.Interval <= 0 {
+		ve["interval"] = ErrInvalid
+	}
+
+	if err := ds.Floor.Validate(); err != nil {
+		ve["floor"] = ErrInvalid
+	} else if !ds.Floor.sameCurrency(value) {
+		ve["floor"] = ErrCurrency
+	} else if ds.Floor.Amount >= value.Amount {
+		ve["floor"] = ErrInvalid
+	}
+
+	if len(ve) > 0 {
+		return ve
+	}
+
+	return nil
+}
+
+// TxAccept accepts the current price of the Dutch auction of the item of b on behalf of the user
+// of b. The first user to accept wins: the acceptance is stored as the winning bid and the item is
+// closed straight away.
+func (bs *bidValidator) TxAccept(b *Bid) error {
+	if err := bs.runValFuncs(b,
+		bs.itemExists,
+		bs.userExists,
+		bs.notSeller,
+		bs.itemOpen,
+	); err != nil {
+		return err
+	}
+
+	// the item is locked while accepting, so only one user can get it
+	return bs.txBidding(b.ItemID, func(i *Item) error {
+		now := bs.clock.Now()
+
+		if i.StateAt(now) != ItemOpen {
+			return ValidationError{"item": ErrNotOpen}
+		}
+
+		if i.Type != AuctionDutch {
+			return ValidationError{"item": ErrUnsupported}
+		}
+
+		b.Amount = i.PriceAt(now)
+		b.Kind = BidAccept
+		if err := bs.hold(*i, b.UserID, b.Amount, true); err != nil {
+			return err
+		}
+		if err := bs.storeBid(b); err != nil {
+			return err
+		}
+
+		sellTo(i, *b, now)
+
+		// the item is not advanced anymore once closed, so the funds held by the other users, such
+		// as the proxies bidding above the price, are released now
+		return bs.releaseHolds(*i)
+	})
+}
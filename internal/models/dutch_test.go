@@ -0,0 +1,99 @@
+package models
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestItem_PriceAt(t *testing.T) {
+	item := Item{
+		Value:    gbp(100),
+		StartsAt: testNow,
+		Type:     AuctionDutch,
+		Dutch:    &DutchSchedule{Decrement: 15, Interval: Duration(time.Minute), Floor: gbp(20)},
+	}
+
+	var cases = []struct {
+		name     string
+		at       time.Time
+		outprice int64
+	}{
+		{"before_start", testNow.Add(-time.Minute), 100},
+		{"at_start", testNow, 100},
+		{"before_first_drop", testNow.Add(59 * time.Second), 100},
+		{"first_drop", testNow.Add(time.Minute), 85},
+		{"third_drop", testNow.Add(3*time.Minute + 30*time.Second), 55},
+		{"floor", testNow.Add(time.Hour), 20},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, gbp(tt.outprice), item.PriceAt(tt.at))
+		})
+	}
+}
+
+func TestBidService_TxAccept(t *testing.T) {
+	now := testNow
+	db := CreateDatabase()
+	db.SetClock(ClockFunc(func() time.Time { return now }))
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc)
+
+	for n := 0; n < 10; n++ {
+		usvc.TxCreate(&User{Name: "Morty"})
+	}
+
+	item := Item{
+		Name:     "portal gun",
+		SellerID: testSeller(usvc),
+		Value:    gbp(100),
+		Type:     AuctionDutch,
+		Dutch:    &DutchSchedule{Decrement: 10, Interval: Duration(time.Minute), Floor: gbp(50)},
+	}
+	assert.NoError(t, isvc.TxCreate(&item))
+
+	err := bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: gbp(200)})
+	assert.True(t, errors.Is(err, ValidationError{"item": ErrUnsupported}))
+
+	now = now.Add(2 * time.Minute)
+
+	// only one of the users competing to accept the price gets the item
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var accepted []Bid
+	for n := 1; n <= 10; n++ {
+		wg.Add(1)
+		go func(userID int64) {
+			defer wg.Done()
+
+			b := Bid{UserID: userID, ItemID: item.ID}
+			if err := bsvc.TxAccept(&b); err == nil {
+				mu.Lock()
+				accepted = append(accepted, b)
+				mu.Unlock()
+			} else {
+				assert.True(t, errors.Is(err, ValidationError{"item": ErrNotOpen}))
+			}
+		}(int64(n))
+	}
+	wg.Wait()
+
+	assert.Len(t, accepted, 1)
+	assert.Equal(t, gbp(80), accepted[0].Amount)
+	assert.Equal(t, BidAccept, accepted[0].Kind)
+
+	winning, err := bsvc.GetWinningBid(item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, accepted[0], winning)
+
+	item, _ = isvc.Get(item.ID)
+	assert.Equal(t, ItemClosed, item.State)
+	assert.Equal(t, gbpPtr(80), item.ClearingPrice)
+	assert.True(t, item.Sold)
+}
@@ -1,5 +1,10 @@
 package models
 
+import (
+	"errors"
+	"fmt"
+)
+
 // These errors are returned by the services and can be used to provide error codes to the
 // API results.
 const (
@@ -7,6 +12,22 @@ const (
 	ErrLowValue ModelError = "models: low_value, bid amount should be higher than highest"
 	ErrConflict ModelError = "models: conflict, resource is being used"
 	ErrRequired ModelError = "models: required, value cannot be empty"
+	ErrInvalid  ModelError = "models: invalid, value is not valid"
+	ErrNotOpen  ModelError = "models: not_open, item is not open for bidding"
+	ErrNoBuyNow ModelError = "models: no_buy_now, item cannot be bought now"
+	ErrSealed   ModelError = "models: sealed, bids are sealed until the item closes"
+
+	ErrUnsupported ModelError = "models: unsupported, operation not supported by the auction type"
+	ErrHighValue   ModelError = "models: high_value, bid amount should be lower than lowest"
+	ErrNoRetract   ModelError = "models: no_retract, bid cannot be retracted anymore"
+	ErrSelfBid     ModelError = "models: self_bid, sellers cannot bid on their own items"
+	ErrCurrency    ModelError = "models: currency_mismatch, amount is not in the currency of the item"
+	ErrOverflow    ModelError = "models: overflow, amount is too large"
+	ErrNoRate      ModelError = "models: no_rate, there is no exchange rate for the currency"
+	ErrNoFunds     ModelError = "models: insufficient_funds, available balance is too low"
+	ErrExpired     ModelError = "models: expired, offer is not valid anymore"
+	ErrFrozen      ModelError = "models: frozen, value cannot be changed anymore"
+	ErrTooMany     ModelError = "models: too_many, limit reached"
 )
 
 // PublicError is an error that returns a string code that can be presented to the API user.
@@ -63,6 +84,58 @@ func (e ModelError) Detail() string {
 	return s
 }
 
+// BidTooLowError is returned when a bid does not reach the minimum acceptable amount. It shares the
+// error code of ErrLowValue, and its detail tells the client the minimum amount to bid next.
+type BidTooLowError struct {
+	Minimum Money
+}
+
+// Error returns the message of the error, following the format of ModelError.
+func (e BidTooLowError) Error() string {
+	return fmt.Sprintf("models: low_value, bid amount should be at least %s", e.Minimum)
+}
+
+// Public returns the error code of ErrLowValue.
+func (e BidTooLowError) Public() string {
+	return ModelError(e.Error()).Public()
+}
+
+// Detail returns the error detail, which includes the minimum amount.
+func (e BidTooLowError) Detail() string {
+	return ModelError(e.Error()).Detail()
+}
+
+// Is makes e match ErrLowValue, so callers do not need to know about the minimum.
+func (e BidTooLowError) Is(err error) bool {
+	return err == ErrLowValue
+}
+
+// BidTooHighError is the counterpart of BidTooLowError for reverse auctions. It shares the error
+// code of ErrHighValue, and its detail tells the client the maximum amount to bid next.
+type BidTooHighError struct {
+	Maximum Money
+}
+
+// Error returns the message of the error, following the format of ModelError.
+func (e BidTooHighError) Error() string {
+	return fmt.Sprintf("models: high_value, bid amount should be at most %s", e.Maximum)
+}
+
+// Public returns the error code of ErrHighValue.
+func (e BidTooHighError) Public() string {
+	return ModelError(e.Error()).Public()
+}
+
+// Detail returns the error detail, which includes the maximum amount.
+func (e BidTooHighError) Detail() string {
+	return ModelError(e.Error()).Detail()
+}
+
+// Is makes e match ErrHighValue, so callers do not need to know about the maximum.
+func (e BidTooHighError) Is(err error) bool {
+	return err == ErrHighValue
+}
+
 type ValidationError map[string]PublicError
 
 // Error returns the list of fields with validation errors. The specific error for each field is not included.
@@ -76,8 +149,8 @@ func (v ValidationError) Error() string {
 }
 
 // Is helps xerrors.Is check if a target error is a ValidationError. If err (the target) contains
-// fields, the error values for each field are compared to the ones in v and their values must
-// match. If err contains a subset of the fields in v, it is considered to match.
+// fields, the error values for each field are compared to the ones in v with errors.Is and they
+// must match. If err contains a subset of the fields in v, it is considered to match.
 func (v ValidationError) Is(err error) bool {
 	ve, ok := err.(ValidationError)
 	if !ok {
@@ -85,7 +158,7 @@ func (v ValidationError) Is(err error) bool {
 	}
 
 	for k := range ve {
-		if v[k] != ve[k] {
+		if !errors.Is(v[k], ve[k]) {
 			return false
 		}
 	}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"time"
+)
+
+// EventType is the kind of an Event.
+type EventType string
+
+const (
+	EventOutbid EventType = "outbid" // the user does not lead the bidding of the item anymore
+	EventWon    EventType = "won"    // the auction ended and the user won the item
+	EventLost   EventType = "lost"   // the auction ended and the user did not win the item
+	EventEnded  EventType = "ended"  // the auction of an item of the user, the seller, ended
+
+	EventCancelled EventType = "cancelled" // the auction of an item the user bid on was cancelled
+)
+
+// eventTypes are the kinds of events the users can be notified of.
+var eventTypes = map[EventType]bool{EventOutbid: true, EventWon: true, EventLost: true, EventEnded: true, EventCancelled: true}
+
+// Event is something that happened to an item which concerns a user. Amount is the amount to beat
+// after an outbid, and the clearing price of an item that was sold when its auction ends.
+type Event struct {
+	Type     EventType `json:"type"`
+	UserID   int64     `json:"userId"`
+	ItemID   int64     `json:"itemId"`
+	ItemName string    `json:"itemName"`
+	Amount   *Money    `json:"amount,omitempty"`
+	Sold     bool      `json:"sold,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Publisher receives the events of the services once the changes behind them are stored. Publish is
+// called on the paths serving the users, so it must not block.
+type Publisher interface {
+	Publish(...Event)
+}
+
+// PublisherFunc allows a function to be used as a Publisher.
+type PublisherFunc func(...Event)
+
+func (f PublisherFunc) Publish(events ...Event) {
+	f(events...)
+}
+
+// discard is the Publisher used until one is set on the database.
+var discard = PublisherFunc(func(...Event) {})
+
+// outbidEvents returns the events of the users who led the bidding of item i before, and do not lead
+// it among bids anymore. Amount is the amount to beat.
+func outbidEvents(i Item, before map[int64]bool, bids []Bid, now time.Time) []Event {
+	after := leaders(i, bids)
+
+	var amount *Money
+	if _, price, ok := closingResult(i, bids); ok {
+		amount = &price
+	}
+
+	var events []Event
+	for userID := range before {
+		if after[userID] {
+			continue
+		}
+
+		events = append(events, Event{
+			Type:     EventOutbid,
+			UserID:   userID,
+			ItemID:   i.ID,
+			ItemName: i.Name,
+			Amount:   amount,
+			At:       now,
+		})
+	}
+
+	return events
+}
+
+// closedEvents returns the events of the auction of item i ending with bids: the seller learns
+// whether the item was sold, and every bidder whether they won it. The bidders who lost only learn the
+// price if it does not disclose the reserve.
+func closedEvents(i Item, bids []Bid, now time.Time) []Event {
+	event := Event{ItemID: i.ID, ItemName: i.Name, Sold: i.Sold, At: now}
+	if i.Sold {
+		event.Amount = i.ClearingPrice
+	}
+
+	ended := event
+	ended.Type, ended.UserID = EventEnded, i.SellerID
+	events := []Event{ended}
+
+	winners := leaders(i, bids)
+
+	seen := map[int64]bool{}
+	for _, b := range activeBids(bids) {
+		if seen[b.UserID] {
+			continue
+		}
+		seen[b.UserID] = true
+
+		e := event
+		e.Type, e.UserID = EventLost, b.UserID
+		if winners[b.UserID] {
+			e.Type = EventWon
+		} else if i.Sold {
+			e.Amount = i.PublicClearingPrice()
+		}
+		events = append(events, e)
+	}
+
+	return events
+}
+
+// cancelledEvents returns the events of the auction of item i being cancelled, for every user with an
+// active bid on it.
+func cancelledEvents(i Item, bids []Bid, now time.Time) []Event {
+	var events []Event
+
+	seen := map[int64]bool{}
+	for _, b := range activeBids(bids) {
+		if seen[b.UserID] {
+			continue
+		}
+		seen[b.UserID] = true
+
+		events = append(events, Event{
+			Type:     EventCancelled,
+			UserID:   b.UserID,
+			ItemID:   i.ID,
+			ItemName: i.Name,
+			At:       now,
+		})
+	}
+
+	return events
+}
@@ -0,0 +1,80 @@
+package models
+
+// FeeSchedule is a fee charged on the sale price of an item, such as the buyer's premium or the
+// seller commission. The rate of every tier applies to the part of the price that falls within it,
+// as with income tax brackets:
+//
+//	{"tiers": [{"below": 100000, "basisPoints": 2500}, {"basisPoints": 2000}]}
+//
+// charges 25% on the first 1000.00 and 20% on the rest. An empty schedule charges nothing.
+type FeeSchedule struct {
+	Tiers []FeeTier `json:"tiers,omitempty"`
+}
+
+// FeeTier is the rate, in basis points (hundredths of a per cent), applied to the part of the price
+// lower than Below. A zero Below means there is no upper bound, so it can only be used on the last
+// tier.
+type FeeTier struct {
+	Below       int64 `json:"below,omitempty"`
+	BasisPoints int64 `json:"basisPoints"`
+}
+
+// Fee returns the fee charged on price, rounded down. Tiers are currency agnostic: their bounds are in
+// the minor units of the currency of price. It returns ErrOverflow if the fee does not fit in an
+// int64.
+func (fs FeeSchedule) Fee(price Money) (Money, error) {
+	fee := Money{Currency: price.Currency}
+
+	var lower int64
+	for _, t := range fs.Tiers {
+		if price.Amount <= lower {
+			break
+		}
+
+		upper := price.Amount
+		if t.Below != 0 && t.Below < upper {
+			upper = t.Below
+		}
+
+		// basis points are hundredths of a per cent, rounding down twice rounds down once
+		pct, err := Money{Amount: upper - lower, Currency: price.Currency}.Percent(t.BasisPoints)
+		if err != nil {
+			return Money{}, err
+		}
+		part, err := pct.Percent(1)
+		if err != nil {
+			return Money{}, err
+		}
+
+		if fee, err = fee.Add(part); err != nil {
+			return Money{}, err
+		}
+
+		if t.Below == 0 {
+			break
+		}
+		lower = t.Below
+	}
+
+	return fee, nil
+}
+
+// Validate checks that fs is a well formed schedule. It returns a ValidationError with the offending
+// fields.
+func (fs FeeSchedule) Validate() error {
+	ve := ValidationError{}
+
+	for n, t := range fs.Tiers {
+		last := n == len(fs.Tiers)-1
+		if t.BasisPoints < 0 || t.BasisPoints > 10000 || t.Below < 0 || (t.Below == 0 && !last) ||
+			(n > 0 && t.Below != 0 && t.Below <= fs.Tiers[n-1].Below) {
+			ve["tiers"] = ErrInvalid
+		}
+	}
+
+	if len(ve) > 0 {
+		return ve
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeeSchedule_Fee(t *testing.T) {
+	tiered := FeeSchedule{Tiers: []FeeTier{
+		{Below: 10000, BasisPoints: 2500},
+		{Below: 50000, BasisPoints: 2000},
+		{BasisPoints: 1000},
+	}}
+
+	var cases = []struct {
+		name   string
+		fs     FeeSchedule
+		price  int64
+		outfee int64
+	}{
+		{"empty", FeeSchedule{}, 10000, 0},
+		{"flat", FeeSchedule{Tiers: []FeeTier{{BasisPoints: 1250}}}, 10000, 1250},
+		{"flat_rounds_down", FeeSchedule{Tiers: []FeeTier{{BasisPoints: 1250}}}, 99, 12},
+		{"tiered_first", tiered, 8000, 2000},
+		{"tiered_boundary", tiered, 10000, 2500},
+		{"tiered_marginal", tiered, 20000, 4500},
+		{"tiered_unbounded", tiered, 60000, 11500},
+		{"tiered_without_unbounded_tier", FeeSchedule{Tiers: tiered.Tiers[:2]}, 60000, 10500},
+		{"zero_price", tiered, 0, 0},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			fee, err := tt.fs.Fee(gbp(tt.price))
+			assert.NoError(t, err)
+			assert.Equal(t, Money{Amount: tt.outfee, Currency: "GBP"}, fee)
+		})
+	}
+}
+
+func TestFeeSchedule_Validate(t *testing.T) {
+	var cases = []struct {
+		name   string
+		fs     FeeSchedule
+		outerr error
+	}{
+		{"empty", FeeSchedule{}, nil},
+		{"tiered", FeeSchedule{Tiers: []FeeTier{{Below: 100, BasisPoints: 2500}, {BasisPoints: 2000}}}, nil},
+		{"negative_rate", FeeSchedule{Tiers: []FeeTier{{BasisPoints: -1}}}, ValidationError{"tiers": ErrInvalid}},
+		{"rate_above_whole_price", FeeSchedule{Tiers: []FeeTier{{BasisPoints: 10001}}}, ValidationError{"tiers": ErrInvalid}},
+		{"unbounded_not_last", FeeSchedule{Tiers: []FeeTier{{BasisPoints: 2500}, {Below: 100, BasisPoints: 2000}}}, ValidationError{"tiers": ErrInvalid}},
+		{"not_ascending", FeeSchedule{Tiers: []FeeTier{{Below: 100, BasisPoints: 2500}, {Below: 50, BasisPoints: 2000}}}, ValidationError{"tiers": ErrInvalid}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.fs.Validate()
+
+			if tt.outerr != nil {
+				assert.Error(t, err)
+				assert.True(t, errors.Is(err, tt.outerr), "errors must match, expected %v, got %v", tt.outerr, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"math"
+)
+
+// IncrementType is the way an Increment computes the minimum step between two bids.
+type IncrementType string
+
+const (
+	IncrementFixed      IncrementType = "fixed"      // always the same amount
+	IncrementPercentage IncrementType = "percentage" // a percentage of the current amount
+	IncrementTiered     IncrementType = "tiered"     // a fixed amount that depends on the current amount
+)
+
+// DefaultIncrement only requires bids to be one unit above the current winning bid.
+var DefaultIncrement = Increment{Type: IncrementFixed, Amount: 1}
+
+// Increment is a minimum bid increment rule. Only the fields related to its Type are used:
+//
+//	{"type": "fixed", "amount": 5}
+//	{"type": "percentage", "percent": 10}
+//	{"type": "tiered", "tiers": [{"below": 100, "step": 5}, {"below": 1000, "step": 25}, {"step": 100}]}
+type Increment struct {
+	Type    IncrementType   `json:"type"`
+	Amount  int64           `json:"amount,omitempty"`
+	Percent int64           `json:"percent,omitempty"`
+	Tiers   []IncrementTier `json:"tiers,omitempty"`
+}
+
+// IncrementTier is the step applied to the amounts lower than Below. A zero Below means there is
+// no upper bound, so it can only be used on the last tier.
+type IncrementTier struct {
+	Below int64 `json:"below,omitempty"`
+	Step  int64 `json:"step"`
+}
+
+// Step returns the minimum increment over amount. It is never lower than one, so bids are always
+// strictly ascending. Increments are currency agnostic: amounts and steps are in the minor units of
+// the currency of the item.
+func (inc Increment) Step(amount int64) int64 {
+	var step int64
+
+	switch inc.Type {
+	case IncrementFixed:
+		step = inc.Amount
+
+	case IncrementPercentage:
+		var rounded, ok bool
+		if step, rounded, ok = mulPercent(amount, inc.Percent); !ok {
+			return math.MaxInt64 // no bid can reach it
+		}
+		if rounded {
+			step++ // rounded up
+		}
+
+	case IncrementTiered:
+		for _, t := range inc.Tiers {
+			step = t.Step
+			if t.Below == 0 || amount < t.Below {
+				break
+			}
+		}
+	}
+
+	if step < 1 {
+		return 1
+	}
+
+	return step
+}
+
+// NextMinimum returns the minimum acceptable bid after a bid of amount. It returns ErrOverflow if
+// there is no such amount.
+func (inc Increment) NextMinimum(amount Money) (Money, error) {
+	return amount.Add(Money{Amount: inc.Step(amount.Amount), Currency: amount.Currency})
+}
+
+// NextMaximum returns the maximum acceptable bid after a bid of amount on a reverse auction.
+func (inc Increment) NextMaximum(amount Money) (Money, error) {
+	return amount.Sub(Money{Amount: inc.Step(amount.Amount), Currency: amount.Currency})
+}
+
+// Validate checks that inc is a well formed rule. It returns a ValidationError with the offending
+// fields.
+func (inc Increment) Validate() error {
+	ve := ValidationError{}
+
+	switch inc.Type {
+	case IncrementFixed:
+		if inc.Amount <= 0 {
+			ve["amount"] = ErrInvalid
+		}
+
+	case IncrementPercentage:
+		if inc.Percent <= 0 {
+			ve["percent"] = ErrInvalid
+		}
+
+	case IncrementTiered:
+		if len(inc.Tiers) == 0 {
+			ve["tiers"] = ErrRequired
+		}
+
+		for n, t := range inc.Tiers {
+			last := n == len(inc.Tiers)-1
+			if t.Step <= 0 || (t.Below == 0 && !last) || (n > 0 && t.Below != 0 && t.Below <= inc.Tiers[n-1].Below) {
+				ve["tiers"] = ErrInvalid
+			}
+		}
+
+	case "":
+		ve["type"] = ErrRequired
+
+	default:
+		ve["type"] = ErrInvalid
+	}
+
+	if len(ve) > 0 {
+		return ve
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrement_Step(t *testing.T) {
+	tiered := Increment{Type: IncrementTiered, Tiers: []IncrementTier{
+		{Below: 100, Step: 5},
+		{Below: 1000, Step: 25},
+		{Step: 100},
+	}}
+
+	var cases = []struct {
+		name    string
+		inc     Increment
+		amount  int64
+		outstep int64
+	}{
+		{"fixed", Increment{Type: IncrementFixed, Amount: 5}, 120, 5},
+		{"percentage", Increment{Type: IncrementPercentage, Percent: 10}, 120, 12},
+		{"percentage_rounds_up", Increment{Type: IncrementPercentage, Percent: 10}, 121, 13},
+		{"percentage_at_least_one", Increment{Type: IncrementPercentage, Percent: 10}, 0, 1},
+		{"tiered_first", tiered, 99, 5},
+		{"tiered_boundary", tiered, 100, 25},
+		{"tiered_unbounded", tiered, 5000, 100},
+		{"tiered_without_unbounded_tier", Increment{Type: IncrementTiered, Tiers: tiered.Tiers[:2]}, 5000, 25},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.outstep, tt.inc.Step(tt.amount))
+
+			next, err := tt.inc.NextMinimum(gbp(tt.amount))
+			assert.NoError(t, err)
+			assert.Equal(t, gbp(tt.amount+tt.outstep), next)
+		})
+	}
+}
+
+func TestIncrement_Validate(t *testing.T) {
+	var cases = []struct {
+		name   string
+		inc    Increment
+		outerr error
+	}{
+		{"fixed", Increment{Type: IncrementFixed, Amount: 5}, nil},
+		{"fixed_zero", Increment{Type: IncrementFixed}, ValidationError{"amount": ErrInvalid}},
+		{"percentage_zero", Increment{Type: IncrementPercentage}, ValidationError{"percent": ErrInvalid}},
+		{"tiered", Increment{Type: IncrementTiered, Tiers: []IncrementTier{{Below: 100, Step: 5}, {Step: 25}}}, nil},
+		{"tiered_empty", Increment{Type: IncrementTiered}, ValidationError{"tiers": ErrRequired}},
+		{"tiered_unbounded_not_last", Increment{Type: IncrementTiered, Tiers: []IncrementTier{{Step: 5}, {Below: 100, Step: 25}}}, ValidationError{"tiers": ErrInvalid}},
+		{"tiered_not_ascending", Increment{Type: IncrementTiered, Tiers: []IncrementTier{{Below: 100, Step: 5}, {Below: 50, Step: 25}}}, ValidationError{"tiers": ErrInvalid}},
+		{"missing_type", Increment{Amount: 5}, ValidationError{"type": ErrRequired}},
+		{"unknown_type", Increment{Type: "random"}, ValidationError{"type": ErrInvalid}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.inc.Validate()
+
+			if tt.outerr != nil {
+				assert.Error(t, err)
+				assert.True(t, errors.Is(err, tt.outerr), "errors must match, expected %v, got %v", tt.outerr, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
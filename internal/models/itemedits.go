@@ -0,0 +1,148 @@
+package models
+
+import (
+	"errors"
+)
+
+// errHasBids tells TxWithdraw that the item cannot be deleted, as it has bids.
+var errHasBids = errors.New("models: item has bids")
+
+// ItemEdit holds the changes made to an item by TxEdit: only the fields set are changed. The name,
+// description, category and tags of an item can be changed at any time, while its starting price can
+// only be changed until the item gets its first bid, and as long as it is not closed.
+type ItemEdit struct {
+	Name        *string   `json:"name"`
+	Description *string   `json:"description"`
+	Category    *string   `json:"category"`
+	Tags        *[]string `json:"tags"`
+	Value       *Money    `json:"initialValue"`
+}
+
+// TxEdit applies e to the item identified by itemID and returns the item changed.
+func (iv *itemValidator) TxEdit(itemID int64, e ItemEdit) (Item, error) {
+	var edited Item
+	err := iv.ItemDB.TxUpdate(itemID, func(i *Item) error {
+		if e.Value != nil && *e.Value != i.Value {
+			// bids are placed while the item is locked, so none can slip in before the change is stored
+			bids, err := iv.bids.ListBidsByItemID(i.ID)
+			if err != nil && err != ErrNotFound {
+				return err
+			}
+
+			if len(bids) > 0 || i.closed() {
+				return ValidationError{"initialValue": ErrFrozen}
+			}
+			i.Value = *e.Value
+		}
+
+		if e.Name != nil {
+			i.Name = *e.Name
+		}
+		if e.Description != nil {
+			i.Description = *e.Description
+		}
+		if e.Category != nil {
+			i.Category = *e.Category
+		}
+		if e.Tags != nil {
+			i.Tags = *e.Tags
+		}
+
+		// the prices of the item are checked against its new starting price
+		if err := iv.runValFuncs(i,
+			iv.validValue,
+			iv.reserveAboveValue,
+			iv.buyNowAboveReserve,
+			iv.validDutchSchedule,
+			iv.validCategory,
+			iv.validTags,
+			iv.validDescription,
+		); err != nil {
+			return err
+		}
+
+		edited = *i
+		return nil
+	})
+	if err == ErrNotFound {
+		return Item{}, ValidationError{"item": ErrNotFound}
+	}
+
+	return edited, err
+}
+
+// TxWithdraw withdraws the item identified by itemID from sale. Items without bids are deleted, while
+// the auction of the items with bids is cancelled instead, keeping them for the record along with their
+// bids: the funds held for the bids are released and the bidders notified. Items that closed with bids
+// cannot be withdrawn anymore. It returns the item cancelled, or as it was before being deleted.
+func (iv *itemValidator) TxWithdraw(itemID int64) (Item, error) {
+	var deleted Item
+	err := iv.ItemDB.TxDelete(itemID, func(i Item) error {
+		bids, err := iv.bids.ListBidsByItemID(i.ID)
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+
+		if len(bids) > 0 {
+			return errHasBids
+		}
+
+		deleted = i
+		return nil
+	})
+
+	switch err {
+	case nil:
+		return deleted, nil
+	case ErrNotFound:
+		return Item{}, ValidationError{"item": ErrNotFound}
+	case errHasBids:
+		// bids are never deleted, so the item still has them
+		return iv.txCancel(itemID)
+	}
+
+	return Item{}, err
+}
+
+// txCancel cancels the auction of the item identified by itemID, which must have bids.
+func (iv *itemValidator) txCancel(itemID int64) (Item, error) {
+	var cancelled Item
+	var events []Event
+	err := iv.ItemDB.TxUpdate(itemID, func(i *Item) error {
+		if i.closed() {
+			return ValidationError{"item": ErrFrozen}
+		}
+
+		now := iv.clock.Now()
+		i.State = ItemCancelled
+		if now.Before(i.EndsAt) {
+			i.EndsAt = now
+		}
+		i.WinningBidID, i.Sold, i.ClearingPrice = 0, false, nil
+
+		bids, err := iv.bids.ListBidsByItemID(i.ID)
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+
+		// nobody leads a cancelled auction, so every bidder gets their funds back
+		if err := releaseHolds(iv.wallets, iv.bids, *i); err != nil {
+			return err
+		}
+
+		events = cancelledEvents(*i, bids, now)
+		cancelled = *i
+		return nil
+	})
+	if err == ErrNotFound {
+		return Item{}, ValidationError{"item": ErrNotFound}
+	}
+	if err != nil {
+		return Item{}, err
+	}
+
+	if len(events) > 0 {
+		iv.events.Publish(events...)
+	}
+	return cancelled, nil
+}
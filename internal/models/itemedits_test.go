@@ -0,0 +1,187 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestItemService_TxEdit(t *testing.T) {
+	db := CreateDatabase()
+	db.SetClock(testClock())
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	seller := testSeller(usvc)
+
+	quiet := Item{Name: "meeseeks box", SellerID: seller, Value: gbp(10), ReservePrice: gbpPtr(50)}
+	bidden := Item{Name: "portal gun", SellerID: seller, Value: gbp(10)}
+	closed := Item{Name: "plumbus", SellerID: seller, Value: gbp(10)}
+	for _, i := range []*Item{&quiet, &bidden, &closed} {
+		assert.NoError(t, isvc.TxCreate(i))
+	}
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: bidden.ID, Amount: gbp(20)}))
+	assert.NoError(t, isvc.TxUpdate(closed.ID, func(i *Item) error {
+		i.State = ItemClosed
+		return nil
+	}))
+
+	name, description, category := "Portal Gun", "  Travel between dimensions. ", "Gadgets/Weapons"
+	tags := []string{"Sci-Fi"}
+
+	var cases = []struct {
+		name    string
+		itemID  int64
+		edit    ItemEdit
+		outitem func(i *Item)
+		outerr  error
+	}{
+		{"descriptive fields with bids", bidden.ID, ItemEdit{Name: &name, Description: &description, Category: &category, Tags: &tags}, func(i *Item) {
+			i.Name, i.Description, i.Category, i.Tags = name, "Travel between dimensions.", "gadgets/weapons", []string{"sci-fi"}
+		}, nil},
+		{"descriptive fields once closed", closed.ID, ItemEdit{Name: &name}, func(i *Item) { i.Name = name }, nil},
+		{"value without bids", quiet.ID, ItemEdit{Value: gbpPtr(20)}, func(i *Item) { i.Value = gbp(20) }, nil},
+		{"same value with bids", bidden.ID, ItemEdit{Value: gbpPtr(10)}, func(i *Item) {}, nil},
+		{"value with bids", bidden.ID, ItemEdit{Value: gbpPtr(5), Name: &name}, nil, ValidationError{"initialValue": ErrFrozen}},
+		{"value once closed", closed.ID, ItemEdit{Value: gbpPtr(5)}, nil, ValidationError{"initialValue": ErrFrozen}},
+		{"value above reserve", quiet.ID, ItemEdit{Value: gbpPtr(60)}, nil, ValidationError{"reservePrice": ErrInvalid}},
+		{"value in another currency", quiet.ID, ItemEdit{Value: &Money{Amount: 10, Currency: "EUR"}}, nil, ValidationError{"reservePrice": ErrCurrency}},
+		{"cleared category", quiet.ID, ItemEdit{Category: new(string)}, func(i *Item) {}, nil},
+		{"invalid tags", quiet.ID, ItemEdit{Tags: &[]string{""}}, nil, ValidationError{"tags": ErrInvalid}},
+		{"unknown item", 42, ItemEdit{Name: &name}, nil, ValidationError{"item": ErrNotFound}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			before, _ := isvc.Get(tt.itemID)
+
+			got, err := isvc.TxEdit(tt.itemID, tt.edit)
+			assert.Equal(t, tt.outerr, err)
+
+			stored, _ := isvc.Get(tt.itemID)
+			if err != nil {
+				// failed edits change nothing
+				assert.Equal(t, before, stored)
+				return
+			}
+
+			want := before
+			tt.outitem(&want)
+			assert.Equal(t, want, got)
+			assert.Equal(t, want, stored)
+		})
+	}
+
+	// the indexes follow the edits
+	items, err := isvc.ListItemsByFilter(ItemFilter{Category: "gadgets"})
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+
+	results, err := isvc.Search("dimensions")
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+}
+
+func TestItemService_TxWithdraw(t *testing.T) {
+	now := testNow
+	db := CreateDatabase()
+	db.SetClock(ClockFunc(func() time.Time { return now }))
+	tp := &testPublisher{}
+	db.SetPublisher(tp)
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc, WithHolds())
+	wsvc := NewWalletService(db, usvc)
+	wlsvc := NewWatchlistService(db, isvc, usvc, bsvc)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+	seller := testSeller(usvc)
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 1, Amount: gbp(100)}))
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 2, Amount: gbp(100)}))
+
+	quiet := Item{Name: "meeseeks box", SellerID: seller, Value: gbp(10), Category: "gadgets"}
+	bidden := Item{Name: "portal gun", SellerID: seller, Value: gbp(10), EndsAt: now.Add(time.Hour)}
+	closed := Item{Name: "plumbus", SellerID: seller, Value: gbp(10), EndsAt: now.Add(time.Hour)}
+	for _, i := range []*Item{&quiet, &bidden, &closed} {
+		assert.NoError(t, isvc.TxCreate(i))
+	}
+
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: bidden.ID, Amount: gbp(20)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: bidden.ID, Amount: gbp(30)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: bidden.ID, Amount: gbp(40)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: closed.ID, Amount: gbp(20)}))
+	assert.NoError(t, wlsvc.TxWatch(&Watch{UserID: 1, ItemID: quiet.ID}))
+	assert.NoError(t, wlsvc.TxWatch(&Watch{UserID: 1, ItemID: bidden.ID}))
+	tp.take()
+
+	held := func(userID int64) Money {
+		wallet, err := wsvc.Get(userID)
+		assert.NoError(t, err)
+		return wallet.Balances[0].Held
+	}
+	assert.Equal(t, gbp(40), held(2))
+
+	// items without bids are deleted
+	got, err := isvc.TxWithdraw(quiet.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, quiet.ID, got.ID)
+	assert.Equal(t, ItemOpen, got.State)
+
+	_, err = isvc.Get(quiet.ID)
+	assert.Equal(t, ErrNotFound, err)
+	assert.Empty(t, db.items.ListItemIDsByCategory("gadgets"))
+	assert.Empty(t, tp.take())
+
+	entries, err := wlsvc.ListWatchlist(1)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, bidden.ID, entries[0].Item.ID)
+	}
+
+	// items with bids are cancelled instead, releasing the holds and notifying the bidders once
+	now = now.Add(time.Minute)
+	got, err = isvc.TxWithdraw(bidden.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, ItemCancelled, got.State)
+	assert.Equal(t, now, got.EndsAt)
+
+	stored, err := isvc.Get(bidden.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, got, stored)
+	assert.Equal(t, gbp(0), held(2))
+
+	assert.ElementsMatch(t, []Event{
+		{Type: EventCancelled, UserID: 1, ItemID: bidden.ID, ItemName: bidden.Name, At: now},
+		{Type: EventCancelled, UserID: 2, ItemID: bidden.ID, ItemName: bidden.Name, At: now},
+	}, tp.take())
+
+	// nobody wins a cancelled auction, which takes no more bids and stays cancelled
+	_, err = bsvc.GetWinningBid(bidden.ID)
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, ValidationError{"item": ErrNotOpen}, bsvc.TxCreate(&Bid{UserID: 1, ItemID: bidden.ID, Amount: gbp(50)}))
+	assert.Equal(t, ItemCancelled, stored.StateAt(now.Add(2*time.Hour)))
+
+	bids, err := bsvc.ListBidsByItemID(bidden.ID)
+	assert.NoError(t, err)
+	assert.Len(t, bids, 3)
+
+	_, err = isvc.TxWithdraw(bidden.ID)
+	assert.Equal(t, ValidationError{"item": ErrFrozen}, err)
+
+	// items that closed with bids cannot be withdrawn anymore
+	assert.NoError(t, isvc.TxUpdate(closed.ID, func(i *Item) error {
+		i.State = ItemClosed
+		return nil
+	}))
+	_, err = isvc.TxWithdraw(closed.ID)
+	assert.Equal(t, ValidationError{"item": ErrFrozen}, err)
+
+	_, err = isvc.TxWithdraw(42)
+	assert.Equal(t, ValidationError{"item": ErrNotFound}, err)
+}
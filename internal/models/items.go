@@ -1,41 +1,473 @@
 package models
 
+import (
+	"time"
+)
+
+// DefaultAuctionDuration is the time an item stays open for bidding when no end time is given on
+// its creation.
+const DefaultAuctionDuration = 7 * 24 * time.Hour
+
 type ItemService interface {
 	ItemDB
+	ListItemsByFilter(ItemFilter) ([]Item, error)
+	Search(query string) ([]SearchResult, error)
+	TxPublish(int64) (Item, error)
+	TxEdit(int64, ItemEdit) (Item, error)
+	TxWithdraw(int64) (Item, error)
 }
 
 type ItemDB interface {
-	TxCreate(*Item)
+	TxCreate(*Item) error
+	TxUpdate(int64, func(*Item) error) error
+	// TxDelete deletes the item if fn, applied to it while it is locked, returns no error.
+	TxDelete(int64, func(Item) error) error
 	Get(int64) (Item, error)
 	ListItems() []Item
 	ListItemsByIDs(...int64) ([]Item, error)
+	ListItemsBySellerID(int64) ([]Item, error)
+	ListItemIDsByCategory(string) []int64
+	ListItemIDsByTag(string) []int64
+	SearchItems([]string) []SearchResult
+}
+
+// ItemState is the point of the auction lifecycle an item is at. Items move forward through
+// draft → scheduled → open → closed → settled, and never back. The auction of an open item may also be
+// cancelled by its seller instead of closing.
+type ItemState string
+
+const (
+	ItemDraft     ItemState = "draft"     // not published yet, ignored by the scheduler
+	ItemScheduled ItemState = "scheduled" // published, waiting for its start time
+	ItemOpen      ItemState = "open"      // accepting bids
+	ItemClosed    ItemState = "closed"    // end time reached, the winner is frozen
+	ItemSettled   ItemState = "settled"   // the sale has been completed
+	ItemCancelled ItemState = "cancelled" // withdrawn by the seller, nobody wins it
+)
+
+// AuctionType is the set of rules used to run the auction of an item.
+type AuctionType string
+
+const (
+	// AuctionEnglish is an open ascending auction, where every bid has to beat the winning one.
+	AuctionEnglish AuctionType = "english"
+	// AuctionSealedFirstPrice is a sealed auction: every user places (and may replace) a single bid,
+	// and amounts are hidden until the item closes. The highest bidder pays their bid.
+	AuctionSealedFirstPrice AuctionType = "sealed_first_price"
+	// AuctionVickrey is a sealed auction where the highest bidder pays the second highest bid.
+	AuctionVickrey AuctionType = "vickrey"
+	// AuctionDutch is a descending auction: the price drops on a schedule and the first user to
+	// accept the current price wins.
+	AuctionDutch AuctionType = "dutch"
+)
+
+// Sealed reports whether the bids of the auction type are hidden until the item closes.
+func (t AuctionType) Sealed() bool {
+	return t == AuctionSealedFirstPrice || t == AuctionVickrey
+}
+
+// AuctionDirection tells which bids are better on an auction.
+type AuctionDirection string
+
+const (
+	// AuctionForward auctions are won by the highest bid, this is what sellers run.
+	AuctionForward AuctionDirection = "forward"
+	// AuctionReverse auctions are won by the lowest bid, as in procurement tenders where suppliers
+	// bid their price downwards. The initial value of the item is then the highest acceptable bid.
+	AuctionReverse AuctionDirection = "reverse"
+)
+
+// beats reports whether amount a is better than amount b on an auction going in direction d. The
+// amounts must be in the same currency, which the services check before accepting any amount.
+func (d AuctionDirection) beats(a, b Money) bool {
+	if d == AuctionReverse {
+		return a.Amount < b.Amount
+	}
+	return a.Amount > b.Amount
 }
 
 type Item struct {
-	ID    int64  `json:"id"`
-	Name  string `json:"name"`
-	Value int    `json:"initialValue"`
+	ID        int64            `json:"id"`
+	Name      string           `json:"name"`
+	SellerID  int64            `json:"sellerId"`
+	Value     Money            `json:"initialValue"`
+	StartsAt  time.Time        `json:"startsAt"`
+	EndsAt    time.Time        `json:"endsAt"`
+	State     ItemState        `json:"state"`
+	Type      AuctionType      `json:"type"`
+	Direction AuctionDirection `json:"direction"`
+
+	// Quantity is the number of identical units in the lot. Zero means a single unit.
+	Quantity int `json:"quantity,omitempty"`
+
+	// CreatedAt is the time the item was listed by its seller.
+	CreatedAt time.Time `json:"createdAt"`
+
+	// ExtendedBy is the time the end of the auction has been pushed back by the soft close.
+	ExtendedBy time.Duration `json:"-"`
+
+	// ReservePrice is the minimum amount the seller accepts to sell the item for (or the maximum
+	// amount accepted on reverse auctions). Bids may start below it, but the item is not sold
+	// unless the winning bid meets it. Nil means no reserve, and Dutch auctions have none.
+	ReservePrice *Money `json:"reservePrice,omitempty"`
+
+	// BuyNowPrice allows a user to buy the item straight away, ending the auction. It is withdrawn
+	// once the bids get close to it. Nil means the item cannot be bought now.
+	BuyNowPrice *Money `json:"buyNowPrice,omitempty"`
+
+	// Increment overrides the minimum bid increment configured for the bid service.
+	Increment *Increment `json:"increment,omitempty"`
+
+	// Description is free text about the item, searched along with its name.
+	Description string `json:"description,omitempty"`
+
+	// Category is the path of the item in the category tree, from its root, e.g.
+	// "electronics/phones". Tags are free-form labels. Both are stored in lower case.
+	Category string   `json:"category,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+
+	// Dutch is the price schedule of a Dutch auction. It is required by, and only used on, these
+	// auctions.
+	Dutch *DutchSchedule `json:"dutch,omitempty"`
+
+	// WinningBidID is frozen when the item closes. It is zero while the item is open or if it
+	// closed without bids.
+	WinningBidID int64 `json:"winningBidId,omitempty"`
+	// Sold is set when the item closes with a winning bid that meets the reserve price.
+	Sold bool `json:"sold"`
+	// ClearingPrice is the price paid by the winner, set when the item closes.
+	ClearingPrice *Money `json:"clearingPrice,omitempty"`
+}
+
+// PublicClearingPrice returns the clearing price of i as shown to the users other than its seller and
+// winners: none until the item closes, nor when it is the reserve price, which must stay hidden but
+// which a Vickrey auction clears at when the runner-up did not reach it.
+func (i Item) PublicClearingPrice() *Money {
+	if !i.closed() || i.ClearingPrice == nil {
+		return nil
+	}
+	if i.ReservePrice != nil && *i.ClearingPrice == *i.ReservePrice {
+		return nil
+	}
+	return i.ClearingPrice
+}
+
+// ReserveMet reports whether a bid of the given amount meets the reserve price of i.
+func (i Item) ReserveMet(amount Money) bool {
+	return i.ReservePrice == nil || amount == *i.ReservePrice || i.Direction.beats(amount, *i.ReservePrice)
+}
+
+// units returns the number of units of i.
+func (i Item) units() int {
+	return units(i.Quantity)
+}
+
+// multiUnit reports whether i is a lot of several units, which may be shared among several winners.
+func (i Item) multiUnit() bool {
+	return i.units() > 1
+}
+
+// closed reports whether the auction of i is over and its winner, if any, has been worked out.
+// Cancelled auctions are over without a winner.
+func (i Item) closed() bool {
+	return i.State == ItemClosed || i.State == ItemSettled || i.State == ItemCancelled
+}
+
+// StateAt returns the state i should be in at time t according to its schedule. Draft items and
+// items that already closed are not affected by the passing of time.
+func (i Item) StateAt(t time.Time) ItemState {
+	switch i.State {
+	case ItemDraft, ItemClosed, ItemSettled, ItemCancelled:
+		return i.State
+	}
+
+	switch {
+	case t.Before(i.StartsAt):
+		return ItemScheduled
+	case t.Before(i.EndsAt):
+		return ItemOpen
+	}
+
+	return ItemClosed
 }
 
 // itemService wraps the ItemService interface to allow mocking by interfaces
 type itemService struct {
 	ItemService
-	userService UserService
-}
-
-type itemCapsule struct {
-	ItemDB
 }
 
 func NewItemService(db *DB, usvc UserService) ItemService {
 	return itemService{
-		ItemService: &itemCapsule{
-			ItemDB: &ItemStorage{
-				db.items.mu,
-				db.items.data,
-				0,
-			},
+		ItemService: &itemValidator{
+			ItemDB:      &db.items,
+			bids:        &db.bids,
+			wallets:     &db.wallets,
+			userService: usvc,
+			clock:       db.clock,
+			events:      db.events,
 		},
-		userService: usvc,
+	}
+}
+
+type itemValidator struct {
+	ItemDB
+	bids        BidDB
+	wallets     WalletDB
+	userService UserService
+	clock       Clock
+	events      Publisher
+}
+
+func (iv *itemValidator) TxCreate(i *Item) error {
+	if err := iv.runValFuncs(i,
+		iv.sellerExists,
+		iv.defaultSchedule,
+		iv.initialState,
+		iv.auctionType,
+		iv.auctionDirection,
+		iv.validQuantity,
+		iv.validValue,
+		iv.endsAfterStart,
+		iv.reserveAboveValue,
+		iv.buyNowAboveReserve,
+		iv.validIncrement,
+		iv.validDutchSchedule,
+		iv.validCategory,
+		iv.validTags,
+		iv.validDescription,
+	); err != nil {
+		return err
+	}
+
+	if i.State != ItemDraft {
+		i.State = i.StateAt(iv.clock.Now())
+	}
+	i.WinningBidID, i.Sold, i.ClearingPrice = 0, false, nil
+	i.ExtendedBy = 0
+	i.CreatedAt = iv.clock.Now()
+
+	return iv.ItemDB.TxCreate(i)
+}
+
+// TxPublish publishes the draft item identified by itemID, which moves to the state of its schedule:
+// scheduled until it starts, then open. The item must not have ended in the meantime.
+func (iv *itemValidator) TxPublish(itemID int64) (Item, error) {
+	var published Item
+	err := iv.ItemDB.TxUpdate(itemID, func(i *Item) error {
+		now := iv.clock.Now()
+
+		if i.State != ItemDraft {
+			return ValidationError{"state": ErrInvalid}
+		}
+		if !now.Before(i.EndsAt) {
+			return ValidationError{"endsAt": ErrInvalid}
+		}
+
+		// the item leaves the draft state first, as StateAt leaves drafts alone. The scheduler is woken
+		// up by the update, and opens and closes the item on time from now on
+		i.State = ItemScheduled
+		i.State = i.StateAt(now)
+
+		published = *i
+		return nil
+	})
+	if err == ErrNotFound {
+		return Item{}, ValidationError{"item": ErrNotFound}
+	}
+
+	return published, err
+}
+
+// ListItemsBySellerID lists the items sold by the user identified by sellerID.
+func (iv *itemValidator) ListItemsBySellerID(sellerID int64) ([]Item, error) {
+	if err := iv.runValFuncs(&Item{SellerID: sellerID},
+		iv.sellerExists,
+	); err != nil {
+		return nil, err
+	}
+
+	return iv.ItemDB.ListItemsBySellerID(sellerID)
+}
+
+type itemValFn func(i *Item) error
+
+func (iv *itemValidator) runValFuncs(i *Item, fns ...func() (string, itemValFn)) error {
+	return runValidationFunctions(i, fns)
+}
+
+func (iv *itemValidator) sellerExists() (string, itemValFn) {
+	return "sellerId", func(i *Item) error {
+		if i.SellerID == 0 {
+			return ErrRequired
+		}
+
+		if _, err := iv.userService.Get(i.SellerID); err != nil {
+			return ErrNotFound
+		}
+		return nil
+	}
+}
+
+// auctionType defaults to an English auction and checks the type is known.
+func (iv *itemValidator) auctionType() (string, itemValFn) {
+	return "type", func(i *Item) error {
+		switch i.Type {
+		case "":
+			i.Type = AuctionEnglish
+		case AuctionEnglish, AuctionSealedFirstPrice, AuctionVickrey, AuctionDutch:
+		default:
+			return ErrInvalid
+		}
+		return nil
+	}
+}
+
+// auctionDirection defaults to a forward auction and checks the direction is known. Reverse auctions
+// can only be run as English or sealed auctions.
+func (iv *itemValidator) auctionDirection() (string, itemValFn) {
+	return "direction", func(i *Item) error {
+		switch i.Direction {
+		case "":
+			i.Direction = AuctionForward
+		case AuctionForward:
+		case AuctionReverse:
+			if i.Type == AuctionDutch {
+				return ErrInvalid
+			}
+		default:
+			return ErrInvalid
+		}
+		return nil
+	}
+}
+
+// validQuantity checks the number of units of the item. Lots of several units can only be sold through
+// English or sealed first-price auctions, where all the winners pay the same price.
+func (iv *itemValidator) validQuantity() (string, itemValFn) {
+	return "quantity", func(i *Item) error {
+		if i.Quantity < 0 {
+			return ErrInvalid
+		}
+
+		if i.multiUnit() && i.Type != AuctionEnglish && i.Type != AuctionSealedFirstPrice {
+			return ErrInvalid
+		}
+		return nil
+	}
+}
+
+// defaultSchedule opens the item straight away and keeps it open for DefaultAuctionDuration when
+// the times are not provided.
+func (iv *itemValidator) defaultSchedule() (string, itemValFn) {
+	return "", func(i *Item) error {
+		if i.StartsAt.IsZero() {
+			i.StartsAt = iv.clock.Now()
+		}
+
+		if i.EndsAt.IsZero() {
+			i.EndsAt = i.StartsAt.Add(DefaultAuctionDuration)
+		}
+
+		return nil
+	}
+}
+
+// initialState only allows new items to be created as drafts, any other state is computed.
+func (iv *itemValidator) initialState() (string, itemValFn) {
+	return "state", func(i *Item) error {
+		if i.State != "" && i.State != ItemDraft {
+			return ErrInvalid
+		}
+		return nil
+	}
+}
+
+func (iv *itemValidator) endsAfterStart() (string, itemValFn) {
+	return "endsAt", func(i *Item) error {
+		if !i.EndsAt.After(i.StartsAt) {
+			return ErrInvalid
+		}
+		return nil
+	}
+}
+
+// validValue checks the starting price of the item, which sets the currency of every other amount
+// of the item and its bids.
+func (iv *itemValidator) validValue() (string, itemValFn) {
+	return "initialValue", func(i *Item) error {
+		return i.Value.Validate()
+	}
+}
+
+// checkPrice checks that the optional price p is a valid amount in the currency of item i.
+func (iv *itemValidator) checkPrice(i *Item, p *Money) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	if !p.sameCurrency(i.Value) {
+		return ErrCurrency
+	}
+	return nil
+}
+
+// reserveAboveValue checks that the reserve price, when set, is not worse than the starting price.
+// Dutch auctions take no reserve, as the floor of their schedule is the lowest price they sell at.
+func (iv *itemValidator) reserveAboveValue() (string, itemValFn) {
+	return "reservePrice", func(i *Item) error {
+		if i.ReservePrice == nil {
+			return nil
+		}
+
+		if err := iv.checkPrice(i, i.ReservePrice); err != nil {
+			return err
+		}
+
+		if i.Type == AuctionDutch || i.Direction.beats(i.Value, *i.ReservePrice) {
+			return ErrInvalid
+		}
+		return nil
+	}
+}
+
+// buyNowAboveReserve checks that the buy-now price, when set, is higher than the starting price and
+// meets the reserve price.
+func (iv *itemValidator) buyNowAboveReserve() (string, itemValFn) {
+	return "buyNowPrice", func(i *Item) error {
+		if i.BuyNowPrice == nil {
+			return nil
+		}
+
+		if err := iv.checkPrice(i, i.BuyNowPrice); err != nil {
+			return err
+		}
+
+		// the buy-now price must beat the open bids, which only exist on English auctions
+		if i.Type != AuctionEnglish || i.Direction != AuctionForward || i.multiUnit() || !i.Direction.beats(*i.BuyNowPrice, i.Value) || !i.ReserveMet(*i.BuyNowPrice) {
+			return ErrInvalid
+		}
+		return nil
+	}
+}
+
+func (iv *itemValidator) validIncrement() (string, itemValFn) {
+	return "increment", func(i *Item) error {
+		if i.Increment == nil {
+			return nil
+		}
+		return i.Increment.Validate()
+	}
+}
+
+func (iv *itemValidator) validDutchSchedule() (string, itemValFn) {
+	return "dutch", func(i *Item) error {
+		if i.Type != AuctionDutch {
+			return nil
+		}
+
+		if i.Dutch == nil {
+			return ErrRequired
+		}
+		return i.Dutch.Validate(i.Value)
 	}
 }
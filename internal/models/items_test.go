@@ -1,7 +1,9 @@
 package models
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -9,14 +11,29 @@ import (
 type testItemDB struct {
 	ItemDB
 	txCreate       func(*Item)
+	txUpdate       func(int64, func(*Item) error) error
 	get            func(int64) (Item, error)
 	listItemsByIDs func(...int64) ([]Item, error)
 }
 
-func (t *testItemDB) TxCreate(i *Item) {
+func (t *testItemDB) TxCreate(i *Item) error {
 	if t.txCreate != nil {
 		t.txCreate(i)
 	}
+	return nil
+}
+
+// TxUpdate applies fn over the item returned by Get unless a txUpdate function is set.
+func (t *testItemDB) TxUpdate(itemID int64, fn func(*Item) error) error {
+	if t.txUpdate != nil {
+		return t.txUpdate(itemID, fn)
+	}
+
+	i, err := t.Get(itemID)
+	if err != nil {
+		return err
+	}
+	return fn(&i)
 }
 
 func (t *testItemDB) Get(itemID int64) (Item, error) {
@@ -33,30 +50,198 @@ func (t *testItemDB) ListItemsByIDs(itemIDs ...int64) ([]Item, error) {
 	return nil, nil
 }
 
+// testSeller creates the user selling the items of a test. It is created after the bidders, so their
+// identification numbers are not affected.
+func testSeller(usvc UserService) int64 {
+	seller := User{Name: "Jerry"}
+	usvc.TxCreate(&seller)
+	return seller.ID
+}
+
 func TestItemService_TxCreate(t *testing.T) {
 	tudb := &testItemDB{}
+	tusdb := &testUserDB{}
 
 	db := CreateDatabase()
-	usvc := NewItemService(db, nil)
+	db.SetClock(testClock())
+	users := NewUserService(db)
+	usvc := NewItemService(db, users)
 
-	usvc.(itemService).ItemService.(*itemCapsule).ItemDB = tudb
+	users.(userService).UserService.(*userCapsule).UserDB = tusdb
+	usvc.(itemService).ItemService.(*itemValidator).ItemDB = tudb
+
+	tusdb.get = func(id int64) (User, error) {
+		if id != 1 {
+			return User{}, ErrNotFound
+		}
+		return User{ID: 1, Name: "Jerry"}, nil
+	}
 
 	var cases = []struct {
 		name    string
+		initem  Item
 		outitem map[int64]Item
+		outerr  error
 		setup   func(*testing.T)
 	}{
 		{
 			"ok",
+			Item{Name: "test", SellerID: 1, Value: gbp(10)},
+			map[int64]Item{
+				1: {ID: 1, Name: "test", SellerID: 1, Value: gbp(10), StartsAt: testNow, EndsAt: testNow.Add(DefaultAuctionDuration), State: ItemOpen, Type: AuctionEnglish, Direction: AuctionForward, CreatedAt: testNow},
+			},
+			nil,
+			func(t *testing.T) {
+				tudb.txCreate = func(u *Item) {
+					db.items.Create(u)
+				}
+			},
+		},
+		{
+			"scheduled",
+			Item{Name: "test", SellerID: 1, Value: gbp(10), StartsAt: testNow.Add(time.Hour), EndsAt: testNow.Add(2 * time.Hour)},
+			map[int64]Item{
+				1: {ID: 1, Name: "test", SellerID: 1, Value: gbp(10), StartsAt: testNow.Add(time.Hour), EndsAt: testNow.Add(2 * time.Hour), State: ItemScheduled, Type: AuctionEnglish, Direction: AuctionForward, CreatedAt: testNow},
+			},
+			nil,
+			func(t *testing.T) {
+				tudb.txCreate = func(u *Item) {
+					db.items.Create(u)
+				}
+			},
+		},
+		{
+			"draft",
+			Item{Name: "test", SellerID: 1, Value: gbp(10), State: ItemDraft, Type: AuctionEnglish, Direction: AuctionForward},
 			map[int64]Item{
-				1: {ID: 1, Name: "test", Value: 10},
+				1: {ID: 1, Name: "test", SellerID: 1, Value: gbp(10), StartsAt: testNow, EndsAt: testNow.Add(DefaultAuctionDuration), State: ItemDraft, Type: AuctionEnglish, Direction: AuctionForward, CreatedAt: testNow},
 			},
+			nil,
 			func(t *testing.T) {
 				tudb.txCreate = func(u *Item) {
 					db.items.Create(u)
 				}
 			},
 		},
+		{
+			"seller_required",
+			Item{Name: "test", Value: gbp(10)},
+			map[int64]Item{},
+			ValidationError{"sellerId": ErrRequired},
+			nil,
+		},
+		{
+			"seller_not_found",
+			Item{Name: "test", SellerID: 2, Value: gbp(10)},
+			map[int64]Item{},
+			ValidationError{"sellerId": ErrNotFound},
+			nil,
+		},
+		{
+			"ends_before_start",
+			Item{Name: "test", SellerID: 1, Value: gbp(10), StartsAt: testNow, EndsAt: testNow.Add(-time.Hour)},
+			map[int64]Item{},
+			ValidationError{"endsAt": ErrInvalid},
+			nil,
+		},
+		{
+			"reserve_below_value",
+			Item{Name: "test", SellerID: 1, Value: gbp(10), ReservePrice: gbpPtr(5)},
+			map[int64]Item{},
+			ValidationError{"reservePrice": ErrInvalid},
+			nil,
+		},
+		{
+			"buy_now_below_reserve",
+			Item{Name: "test", SellerID: 1, Value: gbp(10), ReservePrice: gbpPtr(100), BuyNowPrice: gbpPtr(50)},
+			map[int64]Item{},
+			ValidationError{"buyNowPrice": ErrInvalid},
+			nil,
+		},
+		{
+			"buy_now_on_sealed_auction",
+			Item{Name: "test", SellerID: 1, Value: gbp(10), Type: AuctionVickrey, BuyNowPrice: gbpPtr(50)},
+			map[int64]Item{},
+			ValidationError{"buyNowPrice": ErrInvalid},
+			nil,
+		},
+		{
+			"reverse_reserve_above_value",
+			Item{Name: "test", SellerID: 1, Value: gbp(100), Direction: AuctionReverse, ReservePrice: gbpPtr(150)},
+			map[int64]Item{},
+			ValidationError{"reservePrice": ErrInvalid},
+			nil,
+		},
+		{
+			"reverse_dutch_auction",
+			Item{Name: "test", SellerID: 1, Value: gbp(10), Type: AuctionDutch, Direction: AuctionReverse},
+			map[int64]Item{},
+			ValidationError{"direction": ErrInvalid},
+			nil,
+		},
+		{
+			"buy_now_on_reverse_auction",
+			Item{Name: "test", SellerID: 1, Value: gbp(100), Direction: AuctionReverse, BuyNowPrice: gbpPtr(150)},
+			map[int64]Item{},
+			ValidationError{"buyNowPrice": ErrInvalid},
+			nil,
+		},
+		{
+			"unknown_auction_type",
+			Item{Name: "test", SellerID: 1, Value: gbp(10), Type: "candle"},
+			map[int64]Item{},
+			ValidationError{"type": ErrInvalid},
+			nil,
+		},
+		{
+			"dutch_without_schedule",
+			Item{Name: "test", SellerID: 1, Value: gbp(10), Type: AuctionDutch},
+			map[int64]Item{},
+			ValidationError{"dutch": ErrRequired},
+			nil,
+		},
+		{
+			"dutch_floor_above_value",
+			Item{Name: "test", SellerID: 1, Value: gbp(10), Type: AuctionDutch, Dutch: &DutchSchedule{Decrement: 1, Interval: Duration(time.Minute), Floor: gbp(10)}},
+			map[int64]Item{},
+			ValidationError{"dutch.floor": ErrInvalid},
+			nil,
+		},
+		{
+			"reserve_on_dutch_auction",
+			Item{Name: "test", SellerID: 1, Value: gbp(10), Type: AuctionDutch, ReservePrice: gbpPtr(20), Dutch: &DutchSchedule{Decrement: 1, Interval: Duration(time.Minute), Floor: gbp(5)}},
+			map[int64]Item{},
+			ValidationError{"reservePrice": ErrInvalid},
+			nil,
+		},
+		{
+			"invalid_increment",
+			Item{Name: "test", SellerID: 1, Value: gbp(10), Increment: &Increment{Type: IncrementFixed}},
+			map[int64]Item{},
+			ValidationError{"increment.amount": ErrInvalid},
+			nil,
+		},
+		{
+			"value_currency_required",
+			Item{Name: "test", SellerID: 1, Value: Money{Amount: 10}},
+			map[int64]Item{},
+			ValidationError{"initialValue.currency": ErrRequired},
+			nil,
+		},
+		{
+			"reserve_currency_mismatch",
+			Item{Name: "test", SellerID: 1, Value: gbp(10), ReservePrice: &Money{Amount: 50, Currency: "EUR"}},
+			map[int64]Item{},
+			ValidationError{"reservePrice": ErrCurrency},
+			nil,
+		},
+		{
+			"state_not_allowed",
+			Item{Name: "test", SellerID: 1, Value: gbp(10), State: ItemClosed},
+			map[int64]Item{},
+			ValidationError{"state": ErrInvalid},
+			nil,
+		},
 	}
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
@@ -64,13 +249,54 @@ func TestItemService_TxCreate(t *testing.T) {
 				tt.setup(t)
 			}
 
-			for _, v := range tt.outitem {
-				usvc.TxCreate(&v)
+			err := usvc.TxCreate(&tt.initem)
+
+			if tt.outerr != nil {
+				assert.Error(t, err)
+				assert.True(t, errors.Is(err, tt.outerr), "errors must match, expected %v, got %v", tt.outerr, err)
+			} else {
+				assert.NoError(t, err)
 			}
 
 			assert.Equal(t, tt.outitem, db.items.data)
 
 			*tudb = testItemDB{}
+			db.items = ItemStorage{data: make(map[int64]Item), changed: make(chan struct{}, 1)}
+		})
+	}
+}
+
+func TestItem_PublicClearingPrice(t *testing.T) {
+	var cases = []struct {
+		name     string
+		item     Item
+		outprice *Money
+	}{
+		{
+			"open",
+			Item{State: ItemOpen, ClearingPrice: gbpPtr(60)},
+			nil,
+		},
+		{
+			"closed",
+			Item{State: ItemClosed, ReservePrice: gbpPtr(40), ClearingPrice: gbpPtr(60)},
+			gbpPtr(60),
+		},
+		{
+			"settled",
+			Item{State: ItemSettled, ClearingPrice: gbpPtr(60)},
+			gbpPtr(60),
+		},
+		{
+			"cleared_at_reserve",
+			Item{State: ItemClosed, ReservePrice: gbpPtr(40), ClearingPrice: gbpPtr(40)},
+			nil,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.outprice, tt.item.PublicClearingPrice())
 		})
 	}
 }
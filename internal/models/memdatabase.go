@@ -1,7 +1,9 @@
 package models
 
 import (
+	"sort"
 	"sync"
+	"sync/atomic"
 )
 
 // Mutex keeps track of a sync.RWMutex and a state (0=false, 1=true).
@@ -9,47 +11,107 @@ type Mutex struct {
 	rw sync.RWMutex
 
 	// state 1 means locked, 0 unlocked and is used to avoid reflection
-	// when checking if a mutex is locked
-	state int
+	// when checking if a mutex is locked. It is read and written atomically,
+	// as it is checked without holding the mutex.
+	state int32
 }
 
 func (m *Mutex) Lock() {
 	m.rw.Lock()
-	m.state = 1 // Set state to locked
+	atomic.StoreInt32(&m.state, 1) // Set state to locked
 }
 
 func (m *Mutex) Unlock() {
+	atomic.StoreInt32(&m.state, 0) // Set state to unlocked, before anybody else may lock it
 	m.rw.Unlock()
-	m.state = 0 // Set state to unlocked
+}
+
+func (m *Mutex) RLock() {
+	m.rw.RLock()
+}
+
+func (m *Mutex) RUnlock() {
+	m.rw.RUnlock()
 }
 
 func (m *Mutex) isLocked() bool {
-	return m.state == 1
+	return atomic.LoadInt32(&m.state) == 1
 }
 
 // DedicatedMutex keeps track of a sync.RWMutex, a state (0=false, 1=true)
 // and points to a specific (int64 identifier) element to be more strict with the blocking policy.
+// The state and the element are read and written atomically, like the state of Mutex.
 type DedicatedMutex struct {
 	rw sync.RWMutex
 
-	state   int
+	state   int32
 	element int64
 }
 
 func (m *DedicatedMutex) Lock(element int64) {
 	m.rw.Lock()
-	m.state = 1
-	m.element = element
+	atomic.StoreInt64(&m.element, element)
+	atomic.StoreInt32(&m.state, 1)
 }
 
 func (m *DedicatedMutex) Unlock(element int64) {
+	atomic.StoreInt32(&m.state, 0)
+	atomic.StoreInt64(&m.element, 0)
 	m.rw.Unlock()
-	m.state = 0
-	m.element = 0
+}
+
+func (m *DedicatedMutex) RLock() {
+	m.rw.RLock()
+}
+
+func (m *DedicatedMutex) RUnlock() {
+	m.rw.RUnlock()
 }
 
 func (m *DedicatedMutex) isIDLocked(id int64) bool {
-	return m.state == 1 && m.element == id
+	return atomic.LoadInt32(&m.state) == 1 && atomic.LoadInt64(&m.element) == id
+}
+
+// KeyedMutex locks the elements identified by an int64 independently of each other, so writing an
+// element does not hold up the writers of the others. The lock of an element is dropped once nobody
+// holds it or waits for it.
+type KeyedMutex struct {
+	mu    sync.Mutex
+	locks map[int64]*keyedLock
+}
+
+// keyedLock is the lock of an element, along with the number of users holding it or waiting for it.
+type keyedLock struct {
+	mu    sync.Mutex
+	users int
+}
+
+func (m *KeyedMutex) Lock(key int64) {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = make(map[int64]*keyedLock)
+	}
+	l, found := m.locks[key]
+	if !found {
+		l = &keyedLock{}
+		m.locks[key] = l
+	}
+	l.users++
+	m.mu.Unlock()
+
+	l.mu.Lock()
+}
+
+func (m *KeyedMutex) Unlock(key int64) {
+	m.mu.Lock()
+	l := m.locks[key]
+	l.users--
+	if l.users == 0 {
+		delete(m.locks, key)
+	}
+	m.mu.Unlock()
+
+	l.mu.Unlock()
 }
 
 // BidStorage contains a data structure that stores the Bids and allows for data consistency.
@@ -57,18 +119,50 @@ type BidStorage struct {
 	mu   DedicatedMutex
 	data map[int64]Bid
 
+	// byItem indexes the bids of every item, and best their highest and lowest active bids, so
+	// reading the bids of an item does not go through the bids of every other item
+	byItem map[int64][]int64
+	best   map[int64]bestBids
+
+	// feed receives the bids once the bidding that stored them is over, for the streams following
+	// their item
+	feed bidFeed
+
 	incrementalID int64
 }
 
-// BidStorage contains a data structure that stores the Items and allows for data consistency.
+// bestBids are the identification numbers of the highest and lowest active bids of an item, zero if
+// there is none.
+type bestBids struct {
+	highest int64
+	lowest  int64
+}
+
+// ItemStorage contains a data structure that stores the Items and allows for data consistency.
 type ItemStorage struct {
 	mu   Mutex
 	data map[int64]Item
 
+	// itemMu locks every item while it is updated, so the updates of an item are applied one after the
+	// other without holding up the readers nor the updates of the other items
+	itemMu KeyedMutex
+
+	// byCategory indexes the items of every category, subcategories included, and byTag the items
+	// carrying every tag, so items are filtered without going through every other item
+	byCategory map[string]map[int64]bool
+	byTag      map[string]map[int64]bool
+
+	// search indexes the words of the names and descriptions of the items
+	search searchIndex
+
 	incrementalID int64
+
+	// changed is signalled (without blocking) every time an item is created or deleted, or its
+	// schedule or state changes
+	changed chan struct{}
 }
 
-// BidStorage contains a data structure that stores the Users and allows for data consistency.
+// UserStorage contains a data structure that stores the Users and allows for data consistency.
 type UserStorage struct {
 	mu   Mutex
 	data map[int64]User
@@ -76,34 +170,156 @@ type UserStorage struct {
 	incrementalID int64
 }
 
-// DB contains all the data structures used by the service
+// ProxyBidStorage contains a data structure that stores the ProxyBids and allows for data consistency.
+type ProxyBidStorage struct {
+	mu   Mutex
+	data map[int64]ProxyBid
+
+	incrementalID int64
+}
+
+// WalletStorage contains a data structure that stores the money of the users and the funds held by
+// their bids, and allows for data consistency.
+type WalletStorage struct {
+	mu   Mutex
+	data map[int64]*walletData
+
+	// payments are the references of the payments already made, so they are never made twice
+	payments map[string]bool
+}
+
+// walletData is the money of a user: the total in every currency and the holds by item.
+type walletData struct {
+	totals map[string]int64
+	holds  map[int64]Hold
+}
+
+// OrderStorage contains a data structure that stores the Orders and allows for data consistency.
+type OrderStorage struct {
+	mu   Mutex
+	data map[int64]Order
+
+	incrementalID int64
+}
+
+// OfferStorage contains a data structure that stores the second-chance Offers and allows for data
+// consistency.
+type OfferStorage struct {
+	mu   Mutex
+	data map[int64]Offer
+
+	incrementalID int64
+}
+
+// WatchlistStorage contains a data structure that stores the items followed by every user and allows for
+// data consistency.
+type WatchlistStorage struct {
+	mu   Mutex
+	data map[int64]map[int64]Watch
+}
+
+// PreferenceStorage contains a data structure that stores the notification preferences of every user
+// and allows for data consistency.
+type PreferenceStorage struct {
+	mu   Mutex
+	data map[int64]NotificationPreferences
+}
+
+// DB contains all the data structures used by the service, as well as the clock shared by the
+// services built on top of it.
 type DB struct {
-	bids  BidStorage
-	items ItemStorage
-	users UserStorage
+	bids    BidStorage
+	items   ItemStorage
+	users   UserStorage
+	proxies ProxyBidStorage
+	wallets WalletStorage
+	orders  OrderStorage
+	offers  OfferStorage
+	watches WatchlistStorage
+	prefs   PreferenceStorage
+
+	clock  Clock
+	events Publisher
+
+	// cursorKey signs the cursors of the pages of the lists
+	cursorKey []byte
 }
 
 func CreateDatabase() *DB {
 	db := &DB{
-		bids:  BidStorage{data: make(map[int64]Bid)},
-		items: ItemStorage{data: make(map[int64]Item)},
-		users: UserStorage{data: make(map[int64]User)},
+		bids:    BidStorage{data: make(map[int64]Bid), byItem: make(map[int64][]int64), best: make(map[int64]bestBids)},
+		items:   ItemStorage{data: make(map[int64]Item), changed: make(chan struct{}, 1)},
+		users:   UserStorage{data: make(map[int64]User)},
+		proxies: ProxyBidStorage{data: make(map[int64]ProxyBid)},
+		wallets: WalletStorage{data: make(map[int64]*walletData), payments: make(map[string]bool)},
+		orders:  OrderStorage{data: make(map[int64]Order)},
+		offers:  OfferStorage{data: make(map[int64]Offer)},
+		watches: WatchlistStorage{data: make(map[int64]map[int64]Watch)},
+		prefs:   PreferenceStorage{data: make(map[int64]NotificationPreferences)},
+		clock:   SystemClock,
+		events:  discard,
 	}
+	db.cursorKey = newCursorKey()
 	return db
 }
 
+// SetClock replaces the clock of the database. It must be called before creating the services,
+// as they keep the clock they were built with.
+func (db *DB) SetClock(c Clock) {
+	db.clock = c
+}
+
+// SetPublisher sets the publisher receiving the events of the services. Like SetClock, it must be
+// called before creating the services.
+func (db *DB) SetPublisher(p Publisher) {
+	db.events = p
+}
+
 // Create an entity Bid in the in-memory database
 func (bdb *BidStorage) Create(b *Bid) {
 	bdb.incrementalID = bdb.incrementalID + 1
 
-	bdb.data[bdb.incrementalID] = Bid{
-		ID:     bdb.incrementalID,
-		ItemID: b.ItemID,
-		UserID: b.UserID,
-		Amount: b.Amount,
+	b.ID = bdb.incrementalID
+	bdb.data[bdb.incrementalID] = *b
+
+	bdb.byItem[b.ItemID] = append(bdb.byItem[b.ItemID], b.ID)
+	if b.active() {
+		best := bdb.best[b.ItemID]
+		if best.highest == 0 || b.Amount.Amount > bdb.data[best.highest].Amount.Amount {
+			best.highest = b.ID
+		}
+		if best.lowest == 0 || b.Amount.Amount < bdb.data[best.lowest].Amount.Amount {
+			best.lowest = b.ID
+		}
+		bdb.best[b.ItemID] = best
 	}
+}
 
-	b.ID = bdb.incrementalID
+// reindex works out again the best bids of an item after one of its bids changed or was removed.
+func (bdb *BidStorage) reindex(itemID int64) {
+	var ids []int64
+	var best bestBids
+	for _, id := range bdb.byItem[itemID] {
+		v, found := bdb.data[id]
+		if !found {
+			continue
+		}
+		ids = append(ids, id)
+
+		// ids are ascending, so the earliest bid is kept on ties
+		if !v.active() {
+			continue
+		}
+		if best.highest == 0 || v.Amount.Amount > bdb.data[best.highest].Amount.Amount {
+			best.highest = id
+		}
+		if best.lowest == 0 || v.Amount.Amount < bdb.data[best.lowest].Amount.Amount {
+			best.lowest = id
+		}
+	}
+
+	bdb.byItem[itemID] = ids
+	bdb.best[itemID] = best
 }
 
 // Create a Bid entity in the in-memory database ensuring that the creation of an entity is transactional.
@@ -120,8 +336,99 @@ func (bdb *BidStorage) TxCreate(b *Bid) error {
 	return nil
 }
 
+// Replace the Bid identified by id with b, ensuring that the replacement is transactional. The new bid
+// gets a new identification number, so it is considered to be placed after any other existing bid.
+// Will raise an error if the itemID pointed is already being used by another thread.
+func (bdb *BidStorage) TxReplace(id int64, b *Bid) error {
+	if bdb.mu.isIDLocked(b.ItemID) {
+		return ErrConflict
+	}
+
+	bdb.mu.Lock(b.ItemID)
+
+	if _, found := bdb.data[id]; !found {
+		bdb.mu.Unlock(b.ItemID)
+		return ErrNotFound
+	}
+
+	delete(bdb.data, id)
+	bdb.Create(b)
+	bdb.reindex(b.ItemID)
+	bdb.mu.Unlock(b.ItemID)
+	return nil
+}
+
+// Update the Bid identified by id applying fn, ensuring that the update is transactional. If fn returns
+// an error, the Bid is left untouched. The identification number and the item of the bid cannot be changed.
+// Will raise an error if the itemID of the bid is already being used by another thread.
+func (bdb *BidStorage) TxUpdate(id int64, fn func(*Bid) error) error {
+	v, err := bdb.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if bdb.mu.isIDLocked(v.ItemID) {
+		return ErrConflict
+	}
+
+	_, err = bdb.update(id, v.ItemID, fn)
+	return err
+}
+
+// update applies fn to the Bid identified by id, of the item itemID, while the item is locked, and
+// returns the updated bid.
+func (bdb *BidStorage) update(id, itemID int64, fn func(*Bid) error) (Bid, error) {
+	bdb.mu.Lock(itemID)
+	defer bdb.mu.Unlock(itemID)
+
+	stored, found := bdb.data[id]
+	if !found {
+		return Bid{}, ErrNotFound
+	}
+
+	v := stored
+	if err := fn(&v); err != nil {
+		return Bid{}, err
+	}
+
+	v.ID, v.ItemID = stored.ID, stored.ItemID
+	bdb.data[id] = v
+	bdb.reindex(v.ItemID)
+	return v, nil
+}
+
+// TxRestore puts back bids as the bids of the item itemID, dropping those stored since, ensuring that the
+// operation is transactional. The identification numbers of the dropped bids are not given again.
+// Will raise an error if the itemID pointed is already being used by another thread.
+func (bdb *BidStorage) TxRestore(itemID int64, bids []Bid) error {
+	if bdb.mu.isIDLocked(itemID) {
+		return ErrConflict
+	}
+
+	bdb.mu.Lock(itemID)
+	defer bdb.mu.Unlock(itemID)
+
+	for _, id := range bdb.byItem[itemID] {
+		delete(bdb.data, id)
+	}
+
+	ids := make([]int64, 0, len(bids))
+	for _, b := range bids {
+		bdb.data[b.ID] = b
+		ids = append(ids, b.ID)
+	}
+	sort.Slice(ids, func(a, b int) bool { return ids[a] < ids[b] })
+
+	bdb.byItem[itemID] = ids
+	bdb.reindex(itemID)
+	return nil
+}
+
 // Lists the existing Items in the in-memory database
 func (idb *ItemStorage) ListItems() []Item {
+	idb.mu.RLock()
+	defer idb.mu.RUnlock()
+
 	items := []Item{}
 	for _, v := range idb.data {
 		items = append(items, v)
@@ -131,6 +438,9 @@ func (idb *ItemStorage) ListItems() []Item {
 
 // Get an Item by its identification number
 func (idb *ItemStorage) Get(id int64) (Item, error) {
+	idb.mu.RLock()
+	defer idb.mu.RUnlock()
+
 	if v, found := idb.data[id]; found {
 		return v, nil
 	}
@@ -141,25 +451,143 @@ func (idb *ItemStorage) Get(id int64) (Item, error) {
 func (idb *ItemStorage) Create(i *Item) {
 	idb.incrementalID = idb.incrementalID + 1
 
-	idb.data[idb.incrementalID] = Item{
-		ID:    idb.incrementalID,
-		Name:  i.Name,
-		Value: i.Value,
+	i.ID = idb.incrementalID
+	idb.data[idb.incrementalID] = *i
+	idb.index(*i)
+}
+
+// index adds i to the category, tag and search indexes.
+func (idb *ItemStorage) index(i Item) {
+	if idb.byCategory == nil {
+		idb.byCategory = make(map[string]map[int64]bool)
+		idb.byTag = make(map[string]map[int64]bool)
 	}
 
-	i.ID = idb.incrementalID
+	add := func(index map[string]map[int64]bool, key string) {
+		if index[key] == nil {
+			index[key] = make(map[int64]bool)
+		}
+		index[key][i.ID] = true
+	}
+
+	for _, c := range categoryPath(i.Category) {
+		add(idb.byCategory, c)
+	}
+	for _, t := range i.Tags {
+		add(idb.byTag, t)
+	}
+	idb.search.add(i)
+}
+
+// unindex removes i from the category, tag and search indexes.
+func (idb *ItemStorage) unindex(i Item) {
+	remove := func(index map[string]map[int64]bool, key string) {
+		delete(index[key], i.ID)
+		if len(index[key]) == 0 {
+			delete(index, key)
+		}
+	}
+
+	for _, c := range categoryPath(i.Category) {
+		remove(idb.byCategory, c)
+	}
+	for _, t := range i.Tags {
+		remove(idb.byTag, t)
+	}
+	idb.search.remove(i)
 }
 
 // Create an Item entity in the in-memory database ensuring that the creation of an entity is transactional.
 // Locking and unlocking the mutex attached to the data structure.
-func (idb *ItemStorage) TxCreate(i *Item) {
+func (idb *ItemStorage) TxCreate(i *Item) error {
 	idb.mu.Lock()
 	idb.Create(i)
 	idb.mu.Unlock()
+
+	idb.notify()
+	return nil
+}
+
+// TxUpdate applies fn to the Item identified by id while holding the lock of the item, so the changes
+// made by fn are applied atomically. If fn returns an error, the Item is left untouched. The other items
+// can be read and updated in the meantime, and the Item itself keeps being read as it was until fn is done.
+//
+// fn must not update nor delete the Item, as its lock is not reentrant.
+func (idb *ItemStorage) TxUpdate(id int64, fn func(*Item) error) error {
+	idb.itemMu.Lock(id)
+	defer idb.itemMu.Unlock(id)
+
+	old, err := idb.Get(id)
+	if err != nil {
+		return err
+	}
+
+	// fn gets its own tags, so the stored ones are still those indexed
+	v := old
+	v.Tags = append([]string(nil), v.Tags...)
+
+	if err := fn(&v); err != nil {
+		return err
+	}
+
+	v.ID = id
+	idb.mu.Lock()
+	idb.unindex(old)
+	idb.data[id] = v
+	idb.index(v)
+	idb.mu.Unlock()
+
+	// the schedule only looks at when the items open and close
+	if !v.StartsAt.Equal(old.StartsAt) || !v.EndsAt.Equal(old.EndsAt) || v.State != old.State {
+		idb.notify()
+	}
+	return nil
+}
+
+// TxDelete deletes the Item identified by id if fn, applied to it while holding the lock of the item,
+// returns no error. Otherwise the error of fn is returned and the Item is kept.
+//
+// fn must not update nor delete the Item, as its lock is not reentrant.
+func (idb *ItemStorage) TxDelete(id int64, fn func(Item) error) error {
+	idb.itemMu.Lock(id)
+	defer idb.itemMu.Unlock(id)
+
+	v, err := idb.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(v); err != nil {
+		return err
+	}
+
+	idb.mu.Lock()
+	idb.unindex(v)
+	delete(idb.data, id)
+	idb.mu.Unlock()
+
+	idb.notify()
+	return nil
+}
+
+// Changed returns a channel that receives a value after items are created or deleted, or their schedule
+// or state changes.
+func (idb *ItemStorage) Changed() <-chan struct{} {
+	return idb.changed
+}
+
+func (idb *ItemStorage) notify() {
+	select {
+	case idb.changed <- struct{}{}:
+	default: // a notification is already pending
+	}
 }
 
 // List the existing Users in the in-memory database
 func (idb *UserStorage) ListUsers() []User {
+	idb.mu.RLock()
+	defer idb.mu.RUnlock()
+
 	users := []User{}
 	for _, v := range idb.data {
 		users = append(users, v)
@@ -168,6 +596,9 @@ func (idb *UserStorage) ListUsers() []User {
 }
 
 func (idb *UserStorage) Get(id int64) (User, error) {
+	idb.mu.RLock()
+	defer idb.mu.RUnlock()
+
 	if v, found := idb.data[id]; found {
 		return v, nil
 	}
@@ -179,8 +610,9 @@ func (udb *UserStorage) Create(u *User) {
 	udb.incrementalID = udb.incrementalID + 1
 
 	udb.data[udb.incrementalID] = User{
-		ID:   udb.incrementalID,
-		Name: u.Name,
+		ID:        udb.incrementalID,
+		Name:      u.Name,
+		CreatedAt: u.CreatedAt,
 	}
 
 	u.ID = udb.incrementalID
@@ -194,14 +626,45 @@ func (udb *UserStorage) TxCreate(u *User) {
 	udb.mu.Unlock()
 }
 
+// TxStrike adds a strike to the User identified by id ensuring that the operation is transactional.
+func (udb *UserStorage) TxStrike(id int64) error {
+	udb.mu.Lock()
+	defer udb.mu.Unlock()
+
+	v, found := udb.data[id]
+	if !found {
+		return ErrNotFound
+	}
+
+	v.Strikes++
+	udb.data[id] = v
+	return nil
+}
+
+// Get a Bid by its identification number
+func (bdb *BidStorage) Get(id int64) (Bid, error) {
+	bdb.mu.RLock()
+	defer bdb.mu.RUnlock()
+
+	if v, found := bdb.data[id]; found {
+		return v, nil
+	}
+	return Bid{}, ErrNotFound
+}
+
 // ListBidsByItemID gets all the bids for a specific item
 func (bdb *BidStorage) ListBidsByItemID(itemID int64) ([]Bid, error) {
+	bdb.mu.RLock()
+	defer bdb.mu.RUnlock()
+
+	return bdb.listBidsByItemID(itemID)
+}
+
+func (bdb *BidStorage) listBidsByItemID(itemID int64) ([]Bid, error) {
 	var bids []Bid
 
-	for _, v := range bdb.data {
-		if itemID == v.ItemID {
-			bids = append(bids, v)
-		}
+	for _, id := range bdb.byItem[itemID] {
+		bids = append(bids, bdb.data[id])
 	}
 
 	if len(bids) == 0 {
@@ -213,24 +676,49 @@ func (bdb *BidStorage) ListBidsByItemID(itemID int64) ([]Bid, error) {
 
 // GetWinningBid gets the current winning bid for an item
 func (bdb *BidStorage) GetWinningBid(itemID int64) (Bid, error) {
-	var winningBid Bid
+	bdb.mu.RLock()
+	defer bdb.mu.RUnlock()
 
-	bids, err := bdb.ListBidsByItemID(itemID)
-	if err != nil {
-		return Bid{}, err
-	}
+	return bdb.bestBid(bdb.best[itemID].highest)
+}
+
+// GetLowestBid gets the current lowest bid for an item, which is the winning bid of reverse auctions
+func (bdb *BidStorage) GetLowestBid(itemID int64) (Bid, error) {
+	bdb.mu.RLock()
+	defer bdb.mu.RUnlock()
 
-	for _, v := range bids {
-		if v.Amount > winningBid.Amount {
-			winningBid = v
+	return bdb.bestBid(bdb.best[itemID].lowest)
+}
+
+// ListItemIDsWithBids lists the identification numbers of the items with active bids.
+func (bdb *BidStorage) ListItemIDsWithBids() []int64 {
+	bdb.mu.RLock()
+	defer bdb.mu.RUnlock()
+
+	var ids []int64
+	for itemID, best := range bdb.best {
+		if best.highest != 0 {
+			ids = append(ids, itemID)
 		}
 	}
+	return ids
+}
+
+// bestBid returns the bid identified by id, which is zero if there is no such bid.
+func (bdb *BidStorage) bestBid(id int64) (Bid, error) {
+	// every bid of the item may have been retracted or voided
+	if id == 0 {
+		return Bid{}, ErrNotFound
+	}
 
-	return winningBid, nil
+	return bdb.data[id], nil
 }
 
 // ListBidsByUserID gets all the bids on which a specific user has a bid
 func (bdb *BidStorage) ListBidsByUserID(userID int64) ([]Bid, error) {
+	bdb.mu.RLock()
+	defer bdb.mu.RUnlock()
+
 	var bids []Bid
 
 	for _, v := range bdb.data {
@@ -244,6 +732,9 @@ func (bdb *BidStorage) ListBidsByUserID(userID int64) ([]Bid, error) {
 
 // ListItemsByIDs fetches all items given a set of IDs
 func (idb *ItemStorage) ListItemsByIDs(itemIDs ...int64) ([]Item, error) {
+	idb.mu.RLock()
+	defer idb.mu.RUnlock()
+
 	var items []Item
 
 	for _, itemID := range itemIDs {
@@ -254,3 +745,583 @@ func (idb *ItemStorage) ListItemsByIDs(itemIDs ...int64) ([]Item, error) {
 
 	return items, nil
 }
+
+// ListItemIDsByCategory lists the identification numbers of the items of a category, which must be
+// normalized, and of its subcategories.
+func (idb *ItemStorage) ListItemIDsByCategory(category string) []int64 {
+	idb.mu.RLock()
+	defer idb.mu.RUnlock()
+
+	return indexedIDs(idb.byCategory[category])
+}
+
+// ListItemIDsByTag lists the identification numbers of the items carrying a tag, which must be
+// normalized.
+func (idb *ItemStorage) ListItemIDsByTag(tag string) []int64 {
+	idb.mu.RLock()
+	defer idb.mu.RUnlock()
+
+	return indexedIDs(idb.byTag[tag])
+}
+
+// SearchItems returns the items matching every term, which must be in lower case, along with their
+// score, the best first.
+func (idb *ItemStorage) SearchItems(terms []string) []SearchResult {
+	idb.mu.RLock()
+	defer idb.mu.RUnlock()
+
+	results := []SearchResult{}
+	for id, score := range idb.search.search(terms) {
+		results = append(results, SearchResult{Item: idb.data[id], Score: score})
+	}
+
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Score != results[b].Score {
+			return results[a].Score > results[b].Score
+		}
+		return results[a].Item.ID < results[b].Item.ID
+	})
+	return results
+}
+
+func indexedIDs(set map[int64]bool) []int64 {
+	ids := make([]int64, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// ListItemsBySellerID fetches all the items sold by a specific user
+func (idb *ItemStorage) ListItemsBySellerID(sellerID int64) ([]Item, error) {
+	idb.mu.RLock()
+	defer idb.mu.RUnlock()
+
+	items := []Item{}
+	for _, v := range idb.data {
+		if v.SellerID == sellerID {
+			items = append(items, v)
+		}
+	}
+
+	return items, nil
+}
+
+// Create a ProxyBid entity in the in-memory database. If the user already has a proxy on the item,
+// its maximum is replaced and it keeps its identification number, and so its priority.
+func (pdb *ProxyBidStorage) Create(p *ProxyBid) {
+	for _, v := range pdb.data {
+		if v.ItemID == p.ItemID && v.UserID == p.UserID {
+			p.ID = v.ID
+			pdb.data[v.ID] = *p
+			return
+		}
+	}
+
+	pdb.incrementalID = pdb.incrementalID + 1
+
+	p.ID = pdb.incrementalID
+	pdb.data[pdb.incrementalID] = *p
+}
+
+// Create a ProxyBid entity in the in-memory database ensuring that the creation of an entity is transactional.
+// Locking and unlocking the mutex attached to the data structure.
+func (pdb *ProxyBidStorage) TxCreate(p *ProxyBid) error {
+	pdb.mu.Lock()
+	pdb.Create(p)
+	pdb.mu.Unlock()
+	return nil
+}
+
+// TxRestore puts back proxies as the proxy bids of the item itemID, dropping those registered since,
+// ensuring that the operation is transactional.
+func (pdb *ProxyBidStorage) TxRestore(itemID int64, proxies []ProxyBid) error {
+	pdb.mu.Lock()
+	defer pdb.mu.Unlock()
+
+	for id, v := range pdb.data {
+		if v.ItemID == itemID {
+			delete(pdb.data, id)
+		}
+	}
+	for _, p := range proxies {
+		pdb.data[p.ID] = p
+	}
+	return nil
+}
+
+// ListProxyBidsByItemID gets all the proxy bids for a specific item
+func (pdb *ProxyBidStorage) ListProxyBidsByItemID(itemID int64) ([]ProxyBid, error) {
+	pdb.mu.RLock()
+	defer pdb.mu.RUnlock()
+
+	var proxies []ProxyBid
+	for _, v := range pdb.data {
+		if itemID == v.ItemID {
+			proxies = append(proxies, v)
+		}
+	}
+
+	return proxies, nil
+}
+
+// Get the Wallet of a user, with their balances sorted by currency and their holds by item
+func (wdb *WalletStorage) Get(userID int64) (Wallet, error) {
+	wdb.mu.RLock()
+	defer wdb.mu.RUnlock()
+
+	w := Wallet{UserID: userID, Balances: []Balance{}, Holds: []Hold{}}
+
+	wd, found := wdb.data[userID]
+	if !found {
+		return w, nil
+	}
+
+	for currency, total := range wd.totals {
+		held := wd.held(currency)
+		w.Balances = append(w.Balances, Balance{
+			Total:     Money{Amount: total, Currency: currency},
+			Held:      Money{Amount: held, Currency: currency},
+			Available: Money{Amount: total - held, Currency: currency},
+		})
+	}
+	sort.Slice(w.Balances, func(a, b int) bool {
+		return w.Balances[a].Total.Currency < w.Balances[b].Total.Currency
+	})
+
+	for _, h := range wd.holds {
+		w.Holds = append(w.Holds, h)
+	}
+	sort.Slice(w.Holds, func(a, b int) bool {
+		return w.Holds[a].ItemID < w.Holds[b].ItemID
+	})
+
+	return w, nil
+}
+
+// TxDeposit adds the amount of t to the wallet of its user ensuring that the operation is transactional.
+func (wdb *WalletStorage) TxDeposit(t *Transfer) error {
+	wdb.mu.Lock()
+	defer wdb.mu.Unlock()
+
+	wd := wdb.wallet(t.UserID)
+
+	total, err := Money{Amount: wd.totals[t.Amount.Currency], Currency: t.Amount.Currency}.Add(t.Amount)
+	if err != nil {
+		return err
+	}
+
+	wd.totals[t.Amount.Currency] = total.Amount
+	return nil
+}
+
+// TxWithdraw takes the amount of t out of the wallet of its user ensuring that the operation is
+// transactional. Will raise an error if the available balance is not enough.
+func (wdb *WalletStorage) TxWithdraw(t *Transfer) error {
+	wdb.mu.Lock()
+	defer wdb.mu.Unlock()
+
+	wd := wdb.wallet(t.UserID)
+
+	currency := t.Amount.Currency
+	if wd.totals[currency]-wd.held(currency) < t.Amount.Amount {
+		return ErrNoFunds
+	}
+
+	wd.totals[currency] -= t.Amount.Amount
+	return nil
+}
+
+// TxHold places the Hold h and releases the holds of the outbid users on the same item in a single
+// transactional step. Will raise an error, leaving every hold untouched, if the available balance of
+// the user of h is not enough.
+func (wdb *WalletStorage) TxHold(h Hold, outbid ...int64) error {
+	wdb.mu.Lock()
+	defer wdb.mu.Unlock()
+
+	wd := wdb.wallet(h.UserID)
+
+	currency := h.Amount.Currency
+	held := wd.held(currency)
+	if prev, found := wd.holds[h.ItemID]; found && prev.Amount.Currency == currency {
+		held -= prev.Amount.Amount
+	}
+
+	if wd.totals[currency]-held < h.Amount.Amount {
+		return ErrNoFunds
+	}
+
+	wd.holds[h.ItemID] = h
+	wdb.release(h.ItemID, outbid...)
+	return nil
+}
+
+// TxRelease releases the holds of the given users on an item ensuring that the operation is transactional.
+func (wdb *WalletStorage) TxRelease(itemID int64, userIDs ...int64) error {
+	wdb.mu.Lock()
+	defer wdb.mu.Unlock()
+
+	wdb.release(itemID, userIDs...)
+	return nil
+}
+
+func (wdb *WalletStorage) release(itemID int64, userIDs ...int64) {
+	for _, userID := range userIDs {
+		if wd, found := wdb.data[userID]; found {
+			delete(wd.holds, itemID)
+		}
+	}
+}
+
+// TxRestoreHolds puts back holds as the holds placed on the item itemID, releasing the others, ensuring
+// that the operation is transactional.
+func (wdb *WalletStorage) TxRestoreHolds(itemID int64, holds []Hold) error {
+	wdb.mu.Lock()
+	defer wdb.mu.Unlock()
+
+	for _, wd := range wdb.data {
+		delete(wd.holds, itemID)
+	}
+	for _, h := range holds {
+		wdb.wallet(h.UserID).holds[itemID] = h
+	}
+	return nil
+}
+
+// ListHoldsByItemID gets all the holds placed on a specific item
+func (wdb *WalletStorage) ListHoldsByItemID(itemID int64) ([]Hold, error) {
+	wdb.mu.RLock()
+	defer wdb.mu.RUnlock()
+
+	var holds []Hold
+	for _, wd := range wdb.data {
+		if h, found := wd.holds[itemID]; found {
+			holds = append(holds, h)
+		}
+	}
+
+	return holds, nil
+}
+
+// TxCapture takes the amount of h from the wallet of its user, releasing their hold on the item, ensuring
+// that the operation is transactional. Will raise an error if the balance not held by other items is not
+// enough, and do nothing if the payment identified by ref was already taken.
+func (wdb *WalletStorage) TxCapture(ref string, h Hold) error {
+	wdb.mu.Lock()
+	defer wdb.mu.Unlock()
+
+	if wdb.payments[ref] {
+		return nil
+	}
+
+	wd := wdb.wallet(h.UserID)
+
+	currency := h.Amount.Currency
+	held := wd.held(currency)
+	if prev, found := wd.holds[h.ItemID]; found && prev.Amount.Currency == currency {
+		held -= prev.Amount.Amount
+	}
+
+	if wd.totals[currency]-held < h.Amount.Amount {
+		return ErrNoFunds
+	}
+
+	wd.totals[currency] -= h.Amount.Amount
+	delete(wd.holds, h.ItemID)
+	wdb.payments[ref] = true
+	return nil
+}
+
+// TxCredit adds the amount of t to the wallet of its user ensuring that the operation is transactional.
+// Will do nothing if the payment identified by ref was already made.
+func (wdb *WalletStorage) TxCredit(ref string, t Transfer) error {
+	wdb.mu.Lock()
+	defer wdb.mu.Unlock()
+
+	if wdb.payments[ref] {
+		return nil
+	}
+
+	wd := wdb.wallet(t.UserID)
+
+	total, err := Money{Amount: wd.totals[t.Amount.Currency], Currency: t.Amount.Currency}.Add(t.Amount)
+	if err != nil {
+		return err
+	}
+
+	wd.totals[t.Amount.Currency] = total.Amount
+	wdb.payments[ref] = true
+	return nil
+}
+
+// wallet returns the wallet of a user, creating it if needed. The lock must be held for writing.
+func (wdb *WalletStorage) wallet(userID int64) *walletData {
+	wd, found := wdb.data[userID]
+	if !found {
+		wd = &walletData{totals: make(map[string]int64), holds: make(map[int64]Hold)}
+		wdb.data[userID] = wd
+	}
+	return wd
+}
+
+// held returns the funds held in currency.
+func (wd *walletData) held(currency string) int64 {
+	var held int64
+	for _, h := range wd.holds {
+		if h.Amount.Currency == currency {
+			held += h.Amount.Amount
+		}
+	}
+	return held
+}
+
+// TxCreate stores an Order in the in-memory database ensuring that the creation is transactional. If the
+// item of o already has an order, o is set to the stored one instead.
+func (odb *OrderStorage) TxCreate(o *Order) error {
+	odb.mu.Lock()
+	defer odb.mu.Unlock()
+
+	for _, v := range odb.data {
+		if v.ItemID == o.ItemID {
+			*o = v
+			return nil
+		}
+	}
+
+	odb.incrementalID = odb.incrementalID + 1
+
+	o.ID = odb.incrementalID
+	odb.data[o.ID] = *o
+	return nil
+}
+
+// TxUpdate applies fn to the Order identified by id ensuring that the operation is transactional. The
+// order is left unchanged if fn returns an error.
+func (odb *OrderStorage) TxUpdate(id int64, fn func(*Order) error) error {
+	odb.mu.Lock()
+	defer odb.mu.Unlock()
+
+	v, found := odb.data[id]
+	if !found {
+		return ErrNotFound
+	}
+
+	// the lines are copied so fn cannot change the stored ones before succeeding
+	v.Lines = append([]OrderLine(nil), v.Lines...)
+	if err := fn(&v); err != nil {
+		return err
+	}
+
+	v.ID = id
+	odb.data[id] = v
+	return nil
+}
+
+// GetByItemID gets the order of a specific item
+func (odb *OrderStorage) GetByItemID(itemID int64) (Order, error) {
+	odb.mu.RLock()
+	defer odb.mu.RUnlock()
+
+	for _, v := range odb.data {
+		if v.ItemID == itemID {
+			return v, nil
+		}
+	}
+
+	return Order{}, ErrNotFound
+}
+
+// ListOrdersByUserID gets the orders in which a user is a buyer or a seller, sorted by identifier
+func (odb *OrderStorage) ListOrdersByUserID(userID int64) ([]Order, error) {
+	return odb.list(func(o Order) bool {
+		for _, l := range o.Lines {
+			if l.BuyerID == userID || l.SellerID == userID {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+// ListUnpaidOrders gets the orders with lines left to pay, sorted by identifier
+func (odb *OrderStorage) ListUnpaidOrders() ([]Order, error) {
+	return odb.list(func(o Order) bool {
+		for _, l := range o.Lines {
+			if l.due() {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (odb *OrderStorage) list(match func(Order) bool) []Order {
+	odb.mu.RLock()
+	defer odb.mu.RUnlock()
+
+	var orders []Order
+	for _, v := range odb.data {
+		if match(v) {
+			orders = append(orders, v)
+		}
+	}
+	sort.Slice(orders, func(a, b int) bool {
+		return orders[a].ID < orders[b].ID
+	})
+
+	return orders
+}
+
+// TxCreate stores an Offer in the in-memory database ensuring that the creation is transactional.
+func (odb *OfferStorage) TxCreate(o *Offer) error {
+	odb.mu.Lock()
+	defer odb.mu.Unlock()
+
+	odb.incrementalID = odb.incrementalID + 1
+
+	o.ID = odb.incrementalID
+	odb.data[o.ID] = *o
+	return nil
+}
+
+// TxUpdate applies fn to the Offer identified by id ensuring that the operation is transactional. The
+// offer is left unchanged if fn returns an error.
+func (odb *OfferStorage) TxUpdate(id int64, fn func(*Offer) error) error {
+	odb.mu.Lock()
+	defer odb.mu.Unlock()
+
+	v, found := odb.data[id]
+	if !found {
+		return ErrNotFound
+	}
+
+	if err := fn(&v); err != nil {
+		return err
+	}
+
+	v.ID = id
+	odb.data[id] = v
+	return nil
+}
+
+// Get an Offer by its identification number
+func (odb *OfferStorage) Get(id int64) (Offer, error) {
+	odb.mu.RLock()
+	defer odb.mu.RUnlock()
+
+	if v, found := odb.data[id]; found {
+		return v, nil
+	}
+	return Offer{}, ErrNotFound
+}
+
+// ListOffersByItemID gets all the offers made for a specific item, sorted by identifier
+func (odb *OfferStorage) ListOffersByItemID(itemID int64) ([]Offer, error) {
+	return odb.list(func(o Offer) bool { return o.ItemID == itemID }), nil
+}
+
+// ListOffersByUserID gets all the offers made to a specific user, sorted by identifier
+func (odb *OfferStorage) ListOffersByUserID(userID int64) ([]Offer, error) {
+	return odb.list(func(o Offer) bool { return o.UserID == userID }), nil
+}
+
+// ListPendingOffers gets the offers waiting for an answer, sorted by identifier
+func (odb *OfferStorage) ListPendingOffers() ([]Offer, error) {
+	return odb.list(func(o Offer) bool { return o.Status == OfferPending }), nil
+}
+
+func (odb *OfferStorage) list(match func(Offer) bool) []Offer {
+	odb.mu.RLock()
+	defer odb.mu.RUnlock()
+
+	offers := []Offer{}
+	for _, v := range odb.data {
+		if match(v) {
+			offers = append(offers, v)
+		}
+	}
+	sort.Slice(offers, func(a, b int) bool {
+		return offers[a].ID < offers[b].ID
+	})
+
+	return offers
+}
+
+// TxAdd adds w to the watchlist of its user ensuring that the operation is transactional. If the item is
+// already in the watchlist, w is set to the stored entry instead.
+func (wdb *WatchlistStorage) TxAdd(w *Watch) error {
+	wdb.mu.Lock()
+	defer wdb.mu.Unlock()
+
+	watches, found := wdb.data[w.UserID]
+	if !found {
+		watches = make(map[int64]Watch)
+		wdb.data[w.UserID] = watches
+	}
+
+	if v, found := watches[w.ItemID]; found {
+		*w = v
+		return nil
+	}
+
+	watches[w.ItemID] = *w
+	return nil
+}
+
+// TxRemove removes an item from the watchlist of a user ensuring that the operation is transactional.
+// Will raise an error if the item is not in the watchlist.
+func (wdb *WatchlistStorage) TxRemove(userID, itemID int64) error {
+	wdb.mu.Lock()
+	defer wdb.mu.Unlock()
+
+	if _, found := wdb.data[userID][itemID]; !found {
+		return ErrNotFound
+	}
+
+	delete(wdb.data[userID], itemID)
+	return nil
+}
+
+// ListWatchesByUserID gets the watchlist of a user, the most recently added items first
+func (wdb *WatchlistStorage) ListWatchesByUserID(userID int64) ([]Watch, error) {
+	wdb.mu.RLock()
+	defer wdb.mu.RUnlock()
+
+	watches := []Watch{}
+	for _, v := range wdb.data[userID] {
+		watches = append(watches, v)
+	}
+	sort.Slice(watches, func(a, b int) bool {
+		if !watches[a].AddedAt.Equal(watches[b].AddedAt) {
+			return watches[a].AddedAt.After(watches[b].AddedAt)
+		}
+		return watches[a].ItemID > watches[b].ItemID
+	})
+
+	return watches, nil
+}
+
+// TxSet stores the notification preferences of a user ensuring that the operation is transactional,
+// replacing the previous ones.
+func (pdb *PreferenceStorage) TxSet(p *NotificationPreferences) error {
+	pdb.mu.Lock()
+	defer pdb.mu.Unlock()
+
+	p.Events = append([]EventType(nil), p.Events...)
+	pdb.data[p.UserID] = *p
+	return nil
+}
+
+// Get the notification preferences of a user. Will raise an error if the user did not set any.
+func (pdb *PreferenceStorage) Get(userID int64) (NotificationPreferences, error) {
+	pdb.mu.RLock()
+	defer pdb.mu.RUnlock()
+
+	p, found := pdb.data[userID]
+	if !found {
+		return NotificationPreferences{}, ErrNotFound
+	}
+
+	p.Events = append([]EventType(nil), p.Events...)
+	return p, nil
+}
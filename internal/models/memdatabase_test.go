@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -41,24 +42,24 @@ func TestBidStorage_TxCreate(t *testing.T) {
 		{
 			name:        "conflict",
 			wanterror:   ErrConflict,
-			blockingBid: &Bid{UserID: 1, ItemID: 1, Amount: 10},
+			blockingBid: &Bid{UserID: 1, ItemID: 1, Amount: gbp(10)},
 			manyBids: []Bid{
-				{UserID: 1, ItemID: 1, Amount: 10},
+				{UserID: 1, ItemID: 1, Amount: gbp(10)},
 			},
 			want: map[int64]Bid{
-				1: {ID: 1, UserID: 1, ItemID: 1, Amount: 10},
+				1: {ID: 1, UserID: 1, ItemID: 1, Amount: gbp(10)},
 			},
 		},
 		{
 			name:        "ok",
 			wanterror:   nil,
-			blockingBid: &Bid{UserID: 1, ItemID: 1, Amount: 10},
+			blockingBid: &Bid{UserID: 1, ItemID: 1, Amount: gbp(10)},
 			manyBids: []Bid{
-				{UserID: 1, ItemID: 2, Amount: 10},
+				{UserID: 1, ItemID: 2, Amount: gbp(10)},
 			},
 			want: map[int64]Bid{
-				1: {ID: 1, UserID: 1, ItemID: 1, Amount: 10},
-				2: {ID: 2, UserID: 1, ItemID: 2, Amount: 10},
+				1: {ID: 1, UserID: 1, ItemID: 1, Amount: gbp(10)},
+				2: {ID: 2, UserID: 1, ItemID: 2, Amount: gbp(10)},
 			},
 		},
 	}
@@ -71,8 +72,8 @@ func TestBidStorage_TxCreate(t *testing.T) {
 				assert.NoError(t, err)
 			}()
 
-			time.Sleep(1 * time.Second)                // Ensure previous creation goroutine is being executed
-			assert.Equal(t, 1, database.bids.mu.state) // Check if mutex is locked
+			time.Sleep(1 * time.Second)                                          // Ensure previous creation goroutine is being executed
+			assert.Equal(t, int32(1), atomic.LoadInt32(&database.bids.mu.state)) // Check if mutex is locked
 			assert.True(t, database.bids.mu.isIDLocked(tt.blockingBid.ItemID))
 
 			for _, v := range tt.manyBids {
@@ -87,7 +88,9 @@ func TestBidStorage_TxCreate(t *testing.T) {
 			}
 
 			time.Sleep(5 * time.Second) // Wait until everything finishes
+			database.bids.mu.RLock()
 			assert.Equal(t, tt.want, database.bids.data)
+			database.bids.mu.RUnlock()
 		})
 	}
 }
@@ -117,8 +120,8 @@ func TestUserStorage_TxCreate(t *testing.T) {
 
 			go database.users.testTxCreate(tt.blockingUser, time.Duration(3*time.Second))
 
-			time.Sleep(1 * time.Second)                 // Ensure previous creation goroutine is being executed
-			assert.Equal(t, 1, database.users.mu.state) // Check if mutex is locked
+			time.Sleep(1 * time.Second)                                           // Ensure previous creation goroutine is being executed
+			assert.Equal(t, int32(1), atomic.LoadInt32(&database.users.mu.state)) // Check if mutex is locked
 			assert.True(t, database.users.mu.isLocked())
 
 			for _, v := range tt.manyUsers {
@@ -126,7 +129,147 @@ func TestUserStorage_TxCreate(t *testing.T) {
 			}
 
 			time.Sleep(5 * time.Second) // Wait until everything finishes
+			database.users.mu.RLock()
 			assert.Equal(t, tt.want, database.users.data)
+			database.users.mu.RUnlock()
 		})
 	}
 }
+
+func TestBidStorage_BestBids(t *testing.T) {
+	database := CreateDatabase()
+	bids := &database.bids
+
+	for _, b := range []Bid{
+		{UserID: 1, ItemID: 1, Amount: gbp(20), Status: BidActive},
+		{UserID: 2, ItemID: 1, Amount: gbp(30), Status: BidActive},
+		{UserID: 3, ItemID: 1, Amount: gbp(30), Status: BidActive},
+		{UserID: 1, ItemID: 2, Amount: gbp(99), Status: BidActive},
+		{UserID: 4, ItemID: 1, Amount: gbp(10), Status: BidRetracted},
+	} {
+		assert.NoError(t, bids.TxCreate(&b))
+	}
+
+	best := func(itemID int64) (int64, int64) {
+		highest, err := bids.GetWinningBid(itemID)
+		assert.NoError(t, err)
+		lowest, err := bids.GetLowestBid(itemID)
+		assert.NoError(t, err)
+		return highest.ID, lowest.ID
+	}
+
+	// the earliest bid wins ties, and inactive bids are left out
+	highest, lowest := best(1)
+	assert.Equal(t, int64(2), highest)
+	assert.Equal(t, int64(1), lowest)
+
+	assert.NoError(t, bids.TxUpdate(2, func(b *Bid) error {
+		b.Status = BidVoided
+		return nil
+	}))
+	highest, _ = best(1)
+	assert.Equal(t, int64(3), highest)
+
+	assert.NoError(t, bids.TxReplace(1, &Bid{UserID: 1, ItemID: 1, Amount: gbp(40), Status: BidActive}))
+	highest, lowest = best(1)
+	assert.Equal(t, int64(6), highest)
+	assert.Equal(t, int64(3), lowest)
+
+	list, err := bids.ListBidsByItemID(1)
+	assert.NoError(t, err)
+	assert.Len(t, list, 4)
+
+	_, err = bids.GetWinningBid(3)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestItemStorage_TxUpdate(t *testing.T) {
+	database := CreateDatabase()
+	items := &database.items
+
+	changed := func() bool {
+		select {
+		case <-items.Changed():
+			return true
+		default:
+			return false
+		}
+	}
+
+	first, second := Item{Name: "phone", EndsAt: testNow}, Item{Name: "radio", EndsAt: testNow}
+	assert.NoError(t, items.TxCreate(&first))
+	assert.NoError(t, items.TxCreate(&second))
+	assert.True(t, changed())
+
+	// an item being updated is still read as it was, and the other items are updated meanwhile
+	assert.NoError(t, items.TxUpdate(first.ID, func(i *Item) error {
+		i.Name = "smartphone"
+
+		stored, err := items.Get(first.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "phone", stored.Name)
+
+		return items.TxUpdate(second.ID, func(i *Item) error {
+			i.Name = "transistor"
+			return nil
+		})
+	}))
+	assert.False(t, changed(), "the schedule did not change")
+
+	stored, err := items.ListItemsByIDs(first.ID, second.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "smartphone", stored[0].Name)
+	assert.Equal(t, "transistor", stored[1].Name)
+
+	assert.NoError(t, items.TxUpdate(first.ID, func(i *Item) error {
+		i.EndsAt = testNow.Add(time.Hour)
+		return nil
+	}))
+	assert.True(t, changed())
+
+	assert.NoError(t, items.TxUpdate(first.ID, func(i *Item) error {
+		i.State = ItemClosed
+		return nil
+	}))
+	assert.True(t, changed())
+
+	assert.Equal(t, ErrNotFound, items.TxUpdate(42, func(*Item) error { return nil }))
+	assert.Empty(t, items.itemMu.locks)
+}
+
+func TestItemStorage_Indexes(t *testing.T) {
+	database := CreateDatabase()
+	items := &database.items
+
+	for _, i := range []Item{
+		{Name: "phone", Category: "electronics/phones", Tags: []string{"boxed", "vintage"}},
+		{Name: "radio", Category: "electronics", Tags: []string{"vintage"}},
+		{Name: "chair"},
+	} {
+		assert.NoError(t, items.TxCreate(&i))
+	}
+
+	// categories list the items of their subcategories
+	assert.ElementsMatch(t, []int64{1, 2}, items.ListItemIDsByCategory("electronics"))
+	assert.ElementsMatch(t, []int64{1}, items.ListItemIDsByCategory("electronics/phones"))
+	assert.ElementsMatch(t, []int64{1, 2}, items.ListItemIDsByTag("vintage"))
+	assert.Empty(t, items.ListItemIDsByCategory("phones"))
+
+	assert.NoError(t, items.TxUpdate(1, func(i *Item) error {
+		i.Category = "toys"
+		i.Tags[0] = "broken"
+		return nil
+	}))
+	assert.ElementsMatch(t, []int64{2}, items.ListItemIDsByCategory("electronics"))
+	assert.Empty(t, items.ListItemIDsByCategory("electronics/phones"))
+	assert.ElementsMatch(t, []int64{1}, items.ListItemIDsByCategory("toys"))
+	assert.ElementsMatch(t, []int64{1}, items.ListItemIDsByTag("broken"))
+	assert.Empty(t, items.ListItemIDsByTag("boxed"))
+
+	// a failed update leaves the indexes untouched
+	assert.Error(t, items.TxUpdate(2, func(i *Item) error {
+		i.Category = "toys"
+		return ErrInvalid
+	}))
+	assert.ElementsMatch(t, []int64{1}, items.ListItemIDsByCategory("toys"))
+}
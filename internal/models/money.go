@@ -0,0 +1,188 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Money is an amount of money in the minor units of its currency (e.g. pence for GBP), along with
+// the ISO 4217 code of the currency:
+//
+//	{"amount": 1050, "currency": "GBP"}
+//
+// The services only compare and add amounts in the same currency, and the arithmetic of Money
+// reports an error instead of overflowing.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// currencies are the ISO 4217 currencies accepted by the services, along with the number of digits
+// of their minor unit.
+var currencies = map[string]int{
+	"AED": 2, "ARS": 2, "AUD": 2, "BGN": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2, "CLP": 0,
+	"CNY": 2, "COP": 2, "CZK": 2, "DKK": 2, "EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2, "IDR": 2,
+	"ILS": 2, "INR": 2, "ISK": 0, "JOD": 3, "JPY": 0, "KRW": 0, "KWD": 3, "MXN": 2, "MYR": 2,
+	"NOK": 2, "NZD": 2, "OMR": 3, "PHP": 2, "PLN": 2, "RON": 2, "SAR": 2, "SEK": 2, "SGD": 2,
+	"THB": 2, "TND": 3, "TRY": 2, "TWD": 2, "USD": 2, "VND": 0, "ZAR": 2,
+}
+
+// IsZero reports whether m has no amount, which is used to tell that an optional price is not set.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Validate checks that m is a non-negative amount of a known currency. It returns a ValidationError
+// with the offending fields.
+func (m Money) Validate() error {
+	ve := ValidationError{}
+
+	if m.Currency == "" {
+		ve["currency"] = ErrRequired
+	} else if _, ok := currencies[m.Currency]; !ok {
+		ve["currency"] = ErrInvalid
+	}
+
+	if m.Amount < 0 {
+		ve["amount"] = ErrInvalid
+	}
+
+	if len(ve) > 0 {
+		return ve
+	}
+
+	return nil
+}
+
+// sameCurrency reports whether m and o are amounts of the same currency.
+func (m Money) sameCurrency(o Money) bool {
+	return m.Currency == o.Currency
+}
+
+// Cmp compares m and o, returning -1, 0 or +1 when m is lower than, equal to or greater than o.
+// It returns ErrCurrency if they are not in the same currency.
+func (m Money) Cmp(o Money) (int, error) {
+	if !m.sameCurrency(o) {
+		return 0, ErrCurrency
+	}
+
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Add returns m plus o. It returns ErrCurrency if they are not in the same currency, and ErrOverflow
+// if the result does not fit in an int64.
+func (m Money) Add(o Money) (Money, error) {
+	if !m.sameCurrency(o) {
+		return Money{}, ErrCurrency
+	}
+
+	if (o.Amount > 0 && m.Amount > math.MaxInt64-o.Amount) || (o.Amount < 0 && m.Amount < math.MinInt64-o.Amount) {
+		return Money{}, ErrOverflow
+	}
+
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+// Sub returns m minus o, with the same errors as Add.
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+
+	return m.Add(Money{Amount: -o.Amount, Currency: o.Currency})
+}
+
+// Percent returns percent per cent of m, rounded down. It returns ErrOverflow if the result does not
+// fit in an int64.
+func (m Money) Percent(percent int64) (Money, error) {
+	amount, _, ok := mulPercent(m.Amount, percent)
+	if !ok {
+		return Money{}, ErrOverflow
+	}
+
+	return Money{Amount: amount, Currency: m.Currency}, nil
+}
+
+// Times returns m multiplied by n, which must not be negative. It returns ErrOverflow if the result
+// does not fit in an int64.
+func (m Money) Times(n int64) (Money, error) {
+	if n != 0 && (m.Amount > math.MaxInt64/n || m.Amount < math.MinInt64/n) {
+		return Money{}, ErrOverflow
+	}
+
+	return Money{Amount: m.Amount * n, Currency: m.Currency}, nil
+}
+
+// Convert returns m in currency, at rate units of currency per unit of the currency of m, rounded
+// down to the minor unit of currency. It returns ErrOverflow if the result does not fit in an int64.
+func (m Money) Convert(currency string, rate *big.Rat) (Money, error) {
+	if currency == m.Currency {
+		return m, nil
+	}
+
+	// the amounts are in minor units, whose size depends on the currency
+	scale := new(big.Rat).SetFrac(pow10(currencies[currency]), pow10(currencies[m.Currency]))
+
+	r := new(big.Rat).SetInt64(m.Amount)
+	r.Mul(r, rate).Mul(r, scale)
+
+	// big.Int.Div rounds towards minus infinity for positive divisors
+	amount := new(big.Int).Div(r.Num(), r.Denom())
+	if !amount.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+
+	return Money{Amount: amount.Int64(), Currency: currency}, nil
+}
+
+// pow10 returns 10 to the power of n.
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// String formats m in major units followed by its currency code, e.g. "10.50 GBP".
+func (m Money) String() string {
+	digits, ok := currencies[m.Currency]
+	if !ok || digits == 0 {
+		return strings.TrimSpace(fmt.Sprintf("%d %s", m.Amount, m.Currency))
+	}
+
+	sign, amount := "", m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+
+	unit := int64(math.Pow10(digits))
+	return fmt.Sprintf("%s%d.%0*d %s", sign, amount/unit, digits, amount%unit, m.Currency)
+}
+
+// mulPercent returns amount*percent/100 rounded down, along with whether it was rounded, without
+// overflowing on the intermediate product. The last result is false if the result itself overflows.
+// amount and percent must not be negative.
+func mulPercent(amount, percent int64) (int64, bool, bool) {
+	whole, rest := amount/100, amount%100
+
+	if percent != 0 && whole > math.MaxInt64/percent {
+		return 0, false, false
+	}
+
+	// rest is below 100, so rest*percent only overflows on absurd percentages
+	if percent > math.MaxInt64/100 {
+		return 0, false, false
+	}
+
+	high, low := whole*percent, rest*percent/100
+	if high > math.MaxInt64-low {
+		return 0, false, false
+	}
+
+	return high + low, rest*percent%100 != 0, true
+}
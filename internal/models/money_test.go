@@ -0,0 +1,131 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gbp returns amount pence.
+func gbp(amount int64) Money {
+	return Money{Amount: amount, Currency: "GBP"}
+}
+
+// gbpPtr returns amount pence as an optional price, which is not set when amount is zero.
+func gbpPtr(amount int64) *Money {
+	if amount == 0 {
+		return nil
+	}
+
+	m := gbp(amount)
+	return &m
+}
+
+func TestMoney_Validate(t *testing.T) {
+	var cases = []struct {
+		name   string
+		money  Money
+		outerr error
+	}{
+		{"ok", gbp(1050), nil},
+		{"zero", gbp(0), nil},
+		{"currency_required", Money{Amount: 10}, ValidationError{"currency": ErrRequired}},
+		{"unknown_currency", Money{Amount: 10, Currency: "XYZ"}, ValidationError{"currency": ErrInvalid}},
+		{"lowercase_currency", Money{Amount: 10, Currency: "gbp"}, ValidationError{"currency": ErrInvalid}},
+		{"negative", gbp(-1), ValidationError{"amount": ErrInvalid}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.money.Validate()
+
+			if tt.outerr != nil {
+				assert.Error(t, err)
+				assert.True(t, errors.Is(err, tt.outerr), "errors must match, expected %v, got %v", tt.outerr, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMoney_Arithmetic(t *testing.T) {
+	eur := Money{Amount: 10, Currency: "EUR"}
+
+	sum, err := gbp(1050).Add(gbp(25))
+	assert.NoError(t, err)
+	assert.Equal(t, gbp(1075), sum)
+
+	diff, err := gbp(1050).Sub(gbp(1075))
+	assert.NoError(t, err)
+	assert.Equal(t, gbp(-25), diff)
+
+	_, err = gbp(10).Add(eur)
+	assert.Equal(t, ErrCurrency, err)
+
+	_, err = gbp(math.MaxInt64).Add(gbp(1))
+	assert.Equal(t, ErrOverflow, err)
+
+	_, err = gbp(math.MinInt64).Sub(gbp(1))
+	assert.Equal(t, ErrOverflow, err)
+
+	cmp, err := gbp(10).Cmp(gbp(20))
+	assert.NoError(t, err)
+	assert.Equal(t, -1, cmp)
+
+	_, err = gbp(10).Cmp(eur)
+	assert.Equal(t, ErrCurrency, err)
+
+	pc, err := gbp(1999).Percent(50)
+	assert.NoError(t, err)
+	assert.Equal(t, gbp(999), pc)
+
+	pc, err = gbp(math.MaxInt64).Percent(100)
+	assert.NoError(t, err)
+	assert.Equal(t, gbp(math.MaxInt64), pc)
+
+	_, err = gbp(math.MaxInt64).Percent(101)
+	assert.Equal(t, ErrOverflow, err)
+
+	prod, err := gbp(1050).Times(3)
+	assert.NoError(t, err)
+	assert.Equal(t, gbp(3150), prod)
+
+	_, err = gbp(math.MaxInt64 / 2).Times(3)
+	assert.Equal(t, ErrOverflow, err)
+}
+
+func TestMoney_Convert(t *testing.T) {
+	var cases = []struct {
+		name     string
+		money    Money
+		currency string
+		rate     *big.Rat
+		out      Money
+		outerr   error
+	}{
+		{"same_currency", gbp(1050), "GBP", big.NewRat(2, 1), gbp(1050), nil},
+		{"rounds_down", gbp(1001), "EUR", big.NewRat(5, 4), Money{Amount: 1251, Currency: "EUR"}, nil},
+		{"to_zero_digits", gbp(1050), "JPY", big.NewRat(150, 1), Money{Amount: 1575, Currency: "JPY"}, nil},
+		{"from_zero_digits", Money{Amount: 1575, Currency: "JPY"}, "GBP", big.NewRat(1, 150), gbp(1050), nil},
+		{"to_three_digits", gbp(1050), "KWD", big.NewRat(2, 5), Money{Amount: 4200, Currency: "KWD"}, nil},
+		{"overflow", gbp(math.MaxInt64), "EUR", big.NewRat(2, 1), Money{}, ErrOverflow},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := tt.money.Convert(tt.currency, tt.rate)
+			assert.Equal(t, tt.outerr, err)
+			assert.Equal(t, tt.out, out)
+		})
+	}
+}
+
+func TestMoney_String(t *testing.T) {
+	assert.Equal(t, "10.50 GBP", gbp(1050).String())
+	assert.Equal(t, "0.05 GBP", gbp(5).String())
+	assert.Equal(t, "-1.00 GBP", gbp(-100).String())
+	assert.Equal(t, "1500 JPY", Money{Amount: 1500, Currency: "JPY"}.String())
+	assert.Equal(t, "1.234 KWD", Money{Amount: 1234, Currency: "KWD"}.String())
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBidService_MultiUnit(t *testing.T) {
+	var cases = []struct {
+		name      string
+		auction   AuctionType
+		reserve   int64
+		bids      []Bid
+		outerr    error
+		outallocs map[int64]int // units won by user
+		outprice  int64
+		outsold   bool
+	}{
+		{
+			"uniform_price",
+			AuctionEnglish,
+			0,
+			[]Bid{{UserID: 1, Amount: gbp(20), Quantity: 2}, {UserID: 2, Amount: gbp(30), Quantity: 2}, {UserID: 3, Amount: gbp(40)}},
+			nil,
+			map[int64]int{3: 1, 2: 2, 1: 2},
+			20,
+			true,
+		},
+		{
+			"partial_fill",
+			AuctionEnglish,
+			0,
+			[]Bid{{UserID: 1, Amount: gbp(20), Quantity: 4}, {UserID: 2, Amount: gbp(30), Quantity: 3}},
+			nil,
+			map[int64]int{2: 3, 1: 2},
+			20,
+			true,
+		},
+		{
+			"must_beat_lowest_winning_bid",
+			AuctionEnglish,
+			0,
+			[]Bid{{UserID: 1, Amount: gbp(20), Quantity: 5}, {UserID: 2, Amount: gbp(20)}},
+			ValidationError{"bid": BidTooLowError{Minimum: gbp(21)}},
+			map[int64]int{1: 5},
+			20,
+			true,
+		},
+		{
+			"raise_replaces_previous_bid",
+			AuctionEnglish,
+			0,
+			[]Bid{{UserID: 1, Amount: gbp(20), Quantity: 5}, {UserID: 2, Amount: gbp(21), Quantity: 2}, {UserID: 1, Amount: gbp(22), Quantity: 4}},
+			nil,
+			map[int64]int{1: 4, 2: 1},
+			21,
+			true,
+		},
+		{
+			"more_units_than_lot",
+			AuctionEnglish,
+			0,
+			[]Bid{{UserID: 1, Amount: gbp(20), Quantity: 6}},
+			ValidationError{"quantity": ErrInvalid},
+			map[int64]int{},
+			0,
+			false,
+		},
+		{
+			"reserve_not_met",
+			AuctionEnglish,
+			25,
+			[]Bid{{UserID: 1, Amount: gbp(20), Quantity: 2}, {UserID: 2, Amount: gbp(30), Quantity: 3}},
+			nil,
+			map[int64]int{2: 3, 1: 2},
+			20,
+			false,
+		},
+		{
+			"sealed_first_price",
+			AuctionSealedFirstPrice,
+			0,
+			[]Bid{{UserID: 1, Amount: gbp(50), Quantity: 3}, {UserID: 2, Amount: gbp(80), Quantity: 3}, {UserID: 3, Amount: gbp(40), Quantity: 3}},
+			nil,
+			map[int64]int{2: 3, 1: 2},
+			50,
+			true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			now := testNow
+			db := CreateDatabase()
+			db.SetClock(ClockFunc(func() time.Time { return now }))
+
+			usvc := NewUserService(db)
+			isvc := NewItemService(db, usvc)
+			bsvc := NewBidService(db, isvc, usvc)
+			asvc := NewAuctionService(db)
+
+			usvc.TxCreate(&User{Name: "Morty"})
+			usvc.TxCreate(&User{Name: "Rick"})
+			usvc.TxCreate(&User{Name: "Summer"})
+
+			item := Item{Name: "concert ticket", SellerID: testSeller(usvc), Value: gbp(10), Quantity: 5, ReservePrice: gbpPtr(tt.reserve), Type: tt.auction, EndsAt: now.Add(time.Hour)}
+			assert.NoError(t, isvc.TxCreate(&item))
+
+			var err error
+			for _, b := range tt.bids {
+				b.ItemID = item.ID
+				if err = bsvc.TxCreate(&b); err != nil {
+					break
+				}
+			}
+
+			if tt.outerr != nil {
+				assert.Error(t, err)
+				assert.True(t, errors.Is(err, tt.outerr), "errors must match, expected %v, got %v", tt.outerr, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			now = item.EndsAt
+			_, err = asvc.Advance()
+			assert.NoError(t, err)
+
+			item, _ = isvc.Get(item.ID)
+			assert.Equal(t, gbpPtr(tt.outprice), item.ClearingPrice)
+			assert.Equal(t, tt.outsold, item.Sold)
+
+			allocs, err := bsvc.GetWinningBids(item.ID)
+			if len(tt.outallocs) == 0 {
+				assert.Equal(t, ErrNotFound, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			won := map[int64]int{}
+			for _, a := range allocs {
+				won[a.Bid.UserID] += a.Quantity
+			}
+			assert.Equal(t, tt.outallocs, won)
+		})
+	}
+}
+
+func TestItemService_TxCreate_MultiUnit(t *testing.T) {
+	var cases = []struct {
+		name   string
+		item   Item
+		outerr error
+	}{
+		{"english", Item{Name: "ticket", SellerID: 1, Value: gbp(10), Quantity: 50}, nil},
+		{"negative_quantity", Item{Name: "ticket", SellerID: 1, Value: gbp(10), Quantity: -1}, ValidationError{"quantity": ErrInvalid}},
+		{"vickrey", Item{Name: "ticket", SellerID: 1, Value: gbp(10), Quantity: 50, Type: AuctionVickrey}, ValidationError{"quantity": ErrInvalid}},
+		{"buy_now", Item{Name: "ticket", SellerID: 1, Value: gbp(10), Quantity: 50, BuyNowPrice: gbpPtr(100)}, ValidationError{"buyNowPrice": ErrInvalid}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			db := CreateDatabase()
+			db.SetClock(testClock())
+
+			usvc := NewUserService(db)
+			isvc := NewItemService(db, usvc)
+
+			testSeller(usvc)
+
+			err := isvc.TxCreate(&tt.item)
+			if tt.outerr != nil {
+				assert.True(t, errors.Is(err, tt.outerr), "errors must match, expected %v, got %v", tt.outerr, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// NotificationPreferences tell how a user wants to be notified of the events concerning them. Every
+// channel with an address gets the notifications: an email to Email and a JSON POST request to
+// WebhookURL.
+type NotificationPreferences struct {
+	UserID     int64  `json:"userId"`
+	Email      string `json:"email,omitempty"`
+	WebhookURL string `json:"webhookUrl,omitempty"`
+
+	// Events are the kinds of events the user wants to be notified of. Empty means all of them.
+	Events []EventType `json:"events,omitempty"`
+}
+
+// Wants reports whether the user wants to be notified of the events of type t.
+func (p NotificationPreferences) Wants(t EventType) bool {
+	if len(p.Events) == 0 {
+		return true
+	}
+
+	for _, e := range p.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+type PreferenceDB interface {
+	TxSet(*NotificationPreferences) error
+	Get(int64) (NotificationPreferences, error)
+}
+
+type NotificationService interface {
+	// GetPreferences returns the notification preferences of the user. Users who did not set any
+	// get empty preferences, and are not notified.
+	GetPreferences(userID int64) (NotificationPreferences, error)
+	TxSetPreferences(*NotificationPreferences) error
+}
+
+// notificationService wraps the NotificationService interface to allow mocking by interfaces
+type notificationService struct {
+	NotificationService
+}
+
+func NewNotificationService(db *DB, usvc UserService) NotificationService {
+	return notificationService{
+		NotificationService: &notificationValidator{
+			PreferenceDB: &db.prefs,
+			userService:  usvc,
+		},
+	}
+}
+
+type notificationValidator struct {
+	PreferenceDB
+	userService UserService
+}
+
+type prefsValFn func(*NotificationPreferences) error
+
+func (nv *notificationValidator) runValFuncs(p *NotificationPreferences, fns ...func() (string, prefsValFn)) error {
+	return runValidationFunctions(p, fns)
+}
+
+func (nv *notificationValidator) GetPreferences(userID int64) (NotificationPreferences, error) {
+	if _, err := nv.userService.Get(userID); err != nil {
+		return NotificationPreferences{}, ValidationError{"user": ErrNotFound}
+	}
+
+	p, err := nv.PreferenceDB.Get(userID)
+	if err == ErrNotFound {
+		return NotificationPreferences{UserID: userID}, nil
+	}
+	return p, err
+}
+
+func (nv *notificationValidator) TxSetPreferences(p *NotificationPreferences) error {
+	if err := nv.runValFuncs(p,
+		nv.userExists,
+		nv.validEmail,
+		nv.validWebhookURL,
+		nv.validEvents,
+	); err != nil {
+		return err
+	}
+
+	return nv.PreferenceDB.TxSet(p)
+}
+
+func (nv *notificationValidator) userExists() (string, prefsValFn) {
+	return "user", func(p *NotificationPreferences) error {
+		if _, err := nv.userService.Get(p.UserID); err != nil {
+			return ErrNotFound
+		}
+		return nil
+	}
+}
+
+// validEmail only accepts bare addresses, as the address is used in the headers of the emails.
+func (nv *notificationValidator) validEmail() (string, prefsValFn) {
+	return "email", func(p *NotificationPreferences) error {
+		if p.Email == "" {
+			return nil
+		}
+
+		addr, err := mail.ParseAddress(p.Email)
+		if err != nil || addr.Name != "" || addr.Address != p.Email {
+			return ErrInvalid
+		}
+		return nil
+	}
+}
+
+// validWebhookURL rejects the webhooks on the server itself or on a private network, when their host
+// tells so. Names resolving to such addresses are rejected by the WebhookClient when connecting.
+func (nv *notificationValidator) validWebhookURL() (string, prefsValFn) {
+	return "webhookUrl", func(p *NotificationPreferences) error {
+		if p.WebhookURL == "" {
+			return nil
+		}
+
+		u, err := url.Parse(p.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalid
+		}
+
+		host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+		if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+			return ErrInvalid
+		}
+		if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+			return ErrInvalid
+		}
+		return nil
+	}
+}
+
+func (nv *notificationValidator) validEvents() (string, prefsValFn) {
+	return "events", func(p *NotificationPreferences) error {
+		for _, e := range p.Events {
+			if !eventTypes[e] {
+				return ErrInvalid
+			}
+		}
+		return nil
+	}
+}
+
+// Notifier delivers the notification of an event through a channel.
+type Notifier interface {
+	// Notify notifies the user with preferences p of e. It does nothing if the preferences do not
+	// give an address for the channel of the notifier.
+	Notify(ctx context.Context, p NotificationPreferences, e Event) error
+}
+
+// notifyTimeout is the time given to a notifier to deliver a notification.
+const notifyTimeout = 10 * time.Second
+
+// Dispatcher is a Publisher handing the events over to the notifiers in the background, so the
+// services never wait for the notifications to be delivered. The events are queued until one of the
+// goroutines calling Run picks them up, and are dropped when the queue is full.
+type Dispatcher struct {
+	queue     chan Event
+	prefs     NotificationService
+	notifiers []Notifier
+	dropped   int64
+}
+
+// NewDispatcher returns a Dispatcher queueing up to size events, which notifies the users through
+// notifiers according to the preferences of ns.
+func NewDispatcher(ns NotificationService, size int, notifiers ...Notifier) *Dispatcher {
+	return &Dispatcher{
+		queue:     make(chan Event, size),
+		prefs:     ns,
+		notifiers: notifiers,
+	}
+}
+
+// Publish queues events for delivery. It never blocks: the events that do not fit in the queue are
+// dropped.
+func (d *Dispatcher) Publish(events ...Event) {
+	for _, e := range events {
+		select {
+		case d.queue <- e:
+		default:
+			atomic.AddInt64(&d.dropped, 1)
+		}
+	}
+}
+
+// Dropped returns the number of events dropped because the queue was full.
+func (d *Dispatcher) Dropped() int64 {
+	return atomic.LoadInt64(&d.dropped)
+}
+
+// Run delivers the queued events until ctx is cancelled. Several goroutines may run it to deliver
+// events concurrently. The delivery failures are given to logf, the events are not sent again.
+func (d *Dispatcher) Run(ctx context.Context, logf func(format string, v ...interface{})) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-d.queue:
+			d.deliver(ctx, e, logf)
+		}
+	}
+}
+
+// deliver notifies the user of e through every notifier, if they want to be notified of it.
+func (d *Dispatcher) deliver(ctx context.Context, e Event, logf func(format string, v ...interface{})) {
+	p, err := d.prefs.GetPreferences(e.UserID)
+	if err != nil {
+		logf("notifications : user %d : %v", e.UserID, err)
+		return
+	}
+
+	if !p.Wants(e.Type) {
+		return
+	}
+
+	for _, n := range d.notifiers {
+		nctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		if err := n.Notify(nctx, p, e); err != nil {
+			logf("notifications : %s event of item %d for user %d : %v", e.Type, e.ItemID, e.UserID, err)
+		}
+		cancel()
+	}
+}
+
+// message returns the subject and the text of the notification of e.
+func message(e Event) (string, string) {
+	amount := ""
+	if e.Amount != nil {
+		amount = e.Amount.String()
+	}
+
+	switch e.Type {
+	case EventOutbid:
+		return fmt.Sprintf("You were outbid on %s", e.ItemName),
+			fmt.Sprintf("Your bid on %s (item %d) is not leading anymore. The amount to beat is %s.", e.ItemName, e.ItemID, amount)
+	case EventWon:
+		return fmt.Sprintf("You won %s", e.ItemName),
+			fmt.Sprintf("The auction of %s (item %d) ended and you won it at %s.", e.ItemName, e.ItemID, amount)
+	case EventLost:
+		return fmt.Sprintf("The auction of %s ended", e.ItemName),
+			fmt.Sprintf("The auction of %s (item %d) ended and your bid did not win.", e.ItemName, e.ItemID)
+	case EventEnded:
+		if e.Sold {
+			return fmt.Sprintf("Your item %s was sold", e.ItemName),
+				fmt.Sprintf("The auction of %s (item %d) ended and the item was sold at %s.", e.ItemName, e.ItemID, amount)
+		}
+		return fmt.Sprintf("Your item %s was not sold", e.ItemName),
+			fmt.Sprintf("The auction of %s (item %d) ended without a winning bid.", e.ItemName, e.ItemID)
+	case EventCancelled:
+		return fmt.Sprintf("The auction of %s was cancelled", e.ItemName),
+			fmt.Sprintf("The seller cancelled the auction of %s (item %d). The funds held for your bids are released.", e.ItemName, e.ItemID)
+	}
+
+	return string(e.Type), fmt.Sprintf("Event %s on %s (item %d).", e.Type, e.ItemName, e.ItemID)
+}
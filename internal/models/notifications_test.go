@@ -0,0 +1,382 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testPublisher records the events published by the services.
+type testPublisher struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (tp *testPublisher) Publish(events ...Event) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.events = append(tp.events, events...)
+}
+
+// take returns the events published since the last call.
+func (tp *testPublisher) take() []Event {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	events := tp.events
+	tp.events = nil
+	return events
+}
+
+func TestNotificationService_TxSetPreferences(t *testing.T) {
+	db := CreateDatabase()
+	usvc := NewUserService(db)
+	nsvc := NewNotificationService(db, usvc)
+	usvc.TxCreate(&User{Name: "Morty"})
+
+	var cases = []struct {
+		name   string
+		prefs  NotificationPreferences
+		outerr error
+	}{
+		{"nothing", NotificationPreferences{UserID: 1}, nil},
+		{"webhook on public address", NotificationPreferences{UserID: 1, WebhookURL: "https://93.184.216.34/hook"}, nil},
+		{"every channel", NotificationPreferences{UserID: 1, Email: "morty@example.com", WebhookURL: "https://example.com/hook", Events: []EventType{EventOutbid}}, nil},
+		{"unknown user", NotificationPreferences{UserID: 2}, ValidationError{"user": ErrNotFound}},
+		{"email with a name", NotificationPreferences{UserID: 1, Email: "Morty <morty@example.com>"}, ValidationError{"email": ErrInvalid}},
+		{"email with a new line", NotificationPreferences{UserID: 1, Email: "morty@example.com\r\nBcc: rick@example.com"}, ValidationError{"email": ErrInvalid}},
+		{"webhook without scheme", NotificationPreferences{UserID: 1, WebhookURL: "example.com/hook"}, ValidationError{"webhookUrl": ErrInvalid}},
+		{"webhook on ftp", NotificationPreferences{UserID: 1, WebhookURL: "ftp://example.com/hook"}, ValidationError{"webhookUrl": ErrInvalid}},
+		{"webhook on localhost", NotificationPreferences{UserID: 1, WebhookURL: "http://localhost:8080/hook"}, ValidationError{"webhookUrl": ErrInvalid}},
+		{"webhook on loopback", NotificationPreferences{UserID: 1, WebhookURL: "http://127.0.0.1/hook"}, ValidationError{"webhookUrl": ErrInvalid}},
+		{"webhook on ipv6 loopback", NotificationPreferences{UserID: 1, WebhookURL: "http://[::1]/hook"}, ValidationError{"webhookUrl": ErrInvalid}},
+		{"webhook on mapped loopback", NotificationPreferences{UserID: 1, WebhookURL: "http://[::ffff:127.0.0.1]/hook"}, ValidationError{"webhookUrl": ErrInvalid}},
+		{"webhook on private network", NotificationPreferences{UserID: 1, WebhookURL: "https://192.168.1.10/hook"}, ValidationError{"webhookUrl": ErrInvalid}},
+		{"webhook on metadata", NotificationPreferences{UserID: 1, WebhookURL: "http://169.254.169.254/latest/meta-data/"}, ValidationError{"webhookUrl": ErrInvalid}},
+		{"unknown event", NotificationPreferences{UserID: 1, Events: []EventType{"bid"}}, ValidationError{"events": ErrInvalid}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			prefs := tt.prefs
+			assert.Equal(t, tt.outerr, nsvc.TxSetPreferences(&prefs))
+		})
+	}
+
+	prefs, err := nsvc.GetPreferences(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "morty@example.com", prefs.Email)
+	assert.True(t, prefs.Wants(EventOutbid))
+	assert.False(t, prefs.Wants(EventWon))
+
+	_, err = nsvc.GetPreferences(2)
+	assert.Equal(t, ValidationError{"user": ErrNotFound}, err)
+}
+
+func TestBidService_Events(t *testing.T) {
+	tp := &testPublisher{}
+	db := CreateDatabase()
+	db.SetClock(testClock())
+	db.SetPublisher(tp)
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc, WithBuyNowThreshold(50))
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+	seller := testSeller(usvc)
+
+	english := Item{Name: "portal gun", SellerID: seller, Value: gbp(10), BuyNowPrice: gbpPtr(100)}
+	sealed := Item{Name: "plumbus", SellerID: seller, Value: gbp(10), Type: AuctionSealedFirstPrice}
+	for _, i := range []*Item{&english, &sealed} {
+		assert.NoError(t, isvc.TxCreate(i))
+	}
+
+	// the first bid outbids nobody
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: english.ID, Amount: gbp(20)}))
+	assert.Empty(t, tp.take())
+
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: english.ID, Amount: gbp(30)}))
+	assert.Equal(t, []Event{
+		{Type: EventOutbid, UserID: 1, ItemID: english.ID, ItemName: "portal gun", Amount: gbpPtr(30), At: testNow},
+	}, tp.take())
+
+	// failed bids publish nothing
+	assert.Error(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: english.ID, Amount: gbp(25)}))
+	assert.Empty(t, tp.take())
+
+	// sealed bids never outbid anybody
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: sealed.ID, Amount: gbp(20)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: sealed.ID, Amount: gbp(30)}))
+	assert.Empty(t, tp.take())
+
+	// buying the item now ends its auction
+	assert.NoError(t, bsvc.TxBuyNow(&Bid{UserID: 1, ItemID: english.ID}))
+	assert.ElementsMatch(t, []Event{
+		{Type: EventEnded, UserID: seller, ItemID: english.ID, ItemName: "portal gun", Amount: gbpPtr(100), Sold: true, At: testNow},
+		{Type: EventWon, UserID: 1, ItemID: english.ID, ItemName: "portal gun", Amount: gbpPtr(100), Sold: true, At: testNow},
+		{Type: EventLost, UserID: 2, ItemID: english.ID, ItemName: "portal gun", Amount: gbpPtr(100), Sold: true, At: testNow},
+	}, tp.take())
+}
+
+func TestAuctionService_Events(t *testing.T) {
+	now := testNow
+	tp := &testPublisher{}
+	db := CreateDatabase()
+	db.SetClock(ClockFunc(func() time.Time { return now }))
+	db.SetPublisher(tp)
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc)
+	asvc := NewAuctionService(db)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+	seller := testSeller(usvc)
+
+	sold := Item{Name: "portal gun", SellerID: seller, Value: gbp(10), EndsAt: now.Add(time.Hour)}
+	unsold := Item{Name: "plumbus", SellerID: seller, Value: gbp(10), EndsAt: now.Add(time.Hour), ReservePrice: gbpPtr(50)}
+	for _, i := range []*Item{&sold, &unsold} {
+		assert.NoError(t, isvc.TxCreate(i))
+	}
+
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: sold.ID, Amount: gbp(20)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: sold.ID, Amount: gbp(30)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: sold.ID, Amount: gbp(40)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: unsold.ID, Amount: gbp(20)}))
+	tp.take()
+
+	now = now.Add(time.Hour)
+	_, err := asvc.Advance()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []Event{
+		{Type: EventEnded, UserID: seller, ItemID: sold.ID, ItemName: "portal gun", Amount: gbpPtr(40), Sold: true, At: now},
+		{Type: EventWon, UserID: 1, ItemID: sold.ID, ItemName: "portal gun", Amount: gbpPtr(40), Sold: true, At: now},
+		{Type: EventLost, UserID: 2, ItemID: sold.ID, ItemName: "portal gun", Amount: gbpPtr(40), Sold: true, At: now},
+		{Type: EventEnded, UserID: seller, ItemID: unsold.ID, ItemName: "plumbus", At: now},
+		{Type: EventLost, UserID: 2, ItemID: unsold.ID, ItemName: "plumbus", At: now},
+	}, tp.take())
+}
+
+// testNotifier records the notifications it delivers to the users with a webhook.
+type testNotifier struct {
+	delivered chan Event
+}
+
+func (tn *testNotifier) Notify(ctx context.Context, p NotificationPreferences, e Event) error {
+	if p.WebhookURL != "" {
+		tn.delivered <- e
+	}
+	return nil
+}
+
+func TestDispatcher(t *testing.T) {
+	db := CreateDatabase()
+	usvc := NewUserService(db)
+	nsvc := NewNotificationService(db, usvc)
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+	assert.NoError(t, nsvc.TxSetPreferences(&NotificationPreferences{UserID: 1, WebhookURL: "https://example.com/hook", Events: []EventType{EventOutbid}}))
+
+	tn := &testNotifier{delivered: make(chan Event, 10)}
+	d := NewDispatcher(nsvc, 3, tn)
+
+	// nobody delivers the events yet, so the ones that do not fit are dropped without blocking
+	d.Publish(
+		Event{Type: EventWon, UserID: 1, ItemID: 1},
+		Event{Type: EventOutbid, UserID: 1, ItemID: 2},
+		Event{Type: EventOutbid, UserID: 2, ItemID: 3},
+		Event{Type: EventOutbid, UserID: 1, ItemID: 4},
+	)
+	assert.Equal(t, int64(1), d.Dropped())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx, t.Logf)
+
+	// user 1 does not want the won events, and user 2 did not set any preferences
+	select {
+	case e := <-tn.delivered:
+		assert.Equal(t, int64(2), e.ItemID)
+	case <-time.After(time.Second):
+		t.Fatal("event not delivered")
+	}
+
+	d.Publish(Event{Type: EventOutbid, UserID: 1, ItemID: 5})
+	select {
+	case e := <-tn.delivered:
+		assert.Equal(t, int64(5), e.ItemID)
+	case <-time.After(time.Second):
+		t.Fatal("event not delivered")
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got Event
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "outbid", r.Header.Get("X-Auction-Event"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	wn := NewWebhookNotifier(srv.Client())
+	e := Event{Type: EventOutbid, UserID: 1, ItemID: 2, ItemName: "portal gun", Amount: gbpPtr(30), At: testNow}
+
+	// users without a webhook are skipped
+	assert.NoError(t, wn.Notify(context.Background(), NotificationPreferences{UserID: 1}, e))
+
+	prefs := NotificationPreferences{UserID: 1, WebhookURL: srv.URL}
+	assert.NoError(t, wn.Notify(context.Background(), prefs, e))
+	assert.Equal(t, e, got)
+
+	status = http.StatusInternalServerError
+	assert.Error(t, wn.Notify(context.Background(), prefs, e))
+}
+
+func TestWebhookClient(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/hook", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	e := Event{Type: EventOutbid, UserID: 1, ItemID: 2, At: testNow}
+
+	// the server listens on the loopback, where webhooks are not sent
+	wn := NewWebhookNotifier(nil)
+	err := wn.Notify(context.Background(), NotificationPreferences{UserID: 1, WebhookURL: srv.URL + "/hook"}, e)
+	assert.True(t, errors.Is(err, errWebhookAddress), "unexpected error %v", err)
+	assert.Zero(t, hits)
+
+	// redirects are not followed, whatever the address of the webhook
+	client := WebhookClient()
+	client.Transport = srv.Client().Transport
+	wn = NewWebhookNotifier(client)
+	err = wn.Notify(context.Background(), NotificationPreferences{UserID: 1, WebhookURL: srv.URL + "/redirect"}, e)
+	assert.EqualError(t, err, "webhook: 302 Found")
+	assert.Equal(t, 1, hits)
+}
+
+func TestPublicIP(t *testing.T) {
+	for ip, public := range map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::":    true,
+		"127.0.0.1":            false,
+		"10.1.2.3":             false,
+		"172.31.255.255":       false,
+		"172.32.0.1":           true,
+		"192.168.0.1":          false,
+		"100.64.0.1":           false,
+		"169.254.169.254":      false,
+		"0.0.0.0":              false,
+		"224.0.0.1":            false,
+		"::":                   false,
+		"::1":                  false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:93.184.216.34": true,
+		"fd00::1":              false,
+		"fe80::1":              false,
+		"ff02::1":              false,
+	} {
+		assert.Equal(t, public, publicIP(net.ParseIP(ip)), ip)
+	}
+}
+
+// fakeSMTPServer accepts a single SMTP session on a local port and sends the mail it receives.
+func fakeSMTPServer(t *testing.T) (string, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	mails := make(chan string, 1)
+	go func() {
+		defer l.Close()
+
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		tc := textproto.NewConn(conn)
+		defer tc.Close()
+
+		var mail strings.Builder
+		tc.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := tc.ReadLine()
+			if err != nil {
+				return
+			}
+
+			switch verb := strings.ToUpper(strings.Fields(line)[0]); verb {
+			case "EHLO", "HELO":
+				tc.PrintfLine("250 localhost")
+			case "MAIL", "RCPT":
+				mail.WriteString(line + "\n")
+				tc.PrintfLine("250 OK")
+			case "DATA":
+				tc.PrintfLine("354 go ahead")
+				data, err := tc.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				mail.Write(data)
+				tc.PrintfLine("250 OK")
+			case "QUIT":
+				tc.PrintfLine("221 bye")
+				mails <- mail.String()
+				return
+			default:
+				tc.PrintfLine("502 %s not implemented", verb)
+			}
+		}
+	}()
+
+	return l.Addr().String(), mails
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, mails := fakeSMTPServer(t)
+	sn := NewSMTPNotifier(addr, "auctions@example.com", nil)
+
+	e := Event{Type: EventWon, UserID: 1, ItemID: 2, ItemName: "portal gun\r\nBcc: rick@example.com", Amount: gbpPtr(4050), Sold: true, At: testNow}
+
+	// users without an email address are skipped
+	assert.NoError(t, sn.Notify(context.Background(), NotificationPreferences{UserID: 1}, e))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, sn.Notify(ctx, NotificationPreferences{UserID: 1, Email: "morty@example.com"}, e))
+
+	var mail string
+	select {
+	case mail = <-mails:
+	case <-time.After(time.Second):
+		t.Fatal("mail not received")
+	}
+
+	assert.Contains(t, mail, "MAIL FROM:<auctions@example.com>")
+	assert.Contains(t, mail, "RCPT TO:<morty@example.com>")
+	assert.Contains(t, mail, "To: morty@example.com\n")
+	assert.Contains(t, mail, "ended and you won it at 40.50 GBP.")
+
+	// the name of the item cannot add headers
+	header := mail[:strings.Index(mail, "\n\n")]
+	assert.NotContains(t, header, "\nBcc:")
+	assert.Contains(t, header, "Subject: =?utf-8?q?")
+}
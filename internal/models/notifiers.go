@@ -0,0 +1,237 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"syscall"
+	"time"
+)
+
+// WebhookNotifier is a Notifier posting the events as JSON to the webhook URL of the users. The type
+// of the event is also given in the X-Auction-Event header.
+type WebhookNotifier struct {
+	client *http.Client
+}
+
+// NewWebhookNotifier returns a WebhookNotifier sending its requests with client, or with a
+// WebhookClient if it is nil.
+func NewWebhookNotifier(client *http.Client) *WebhookNotifier {
+	if client == nil {
+		client = WebhookClient()
+	}
+
+	return &WebhookNotifier{client: client}
+}
+
+// errWebhookAddress is returned when a webhook resolves to an address that is not public.
+var errWebhookAddress = errors.New("webhook: address not allowed")
+
+// WebhookClient returns a client for the webhooks, whose URLs are given by the users. As they could
+// otherwise reach the services of the private network of the server, or the server itself, the client
+// only connects to public addresses, checked once the name of the host is resolved, and does not go
+// through proxies nor follow redirects.
+func WebhookClient() *http.Client {
+	d := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return errWebhookAddress
+			}
+			return nil
+		},
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = d.DialContext
+
+	return &http.Client{
+		Transport: t,
+		// redirects are not followed, their response being an error like any other but a 2xx
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// nonPublicNetworks are the networks of the addresses that are not reachable on the internet: the
+// loopback, private, shared, link-local, multicast and reserved ranges.
+var nonPublicNetworks = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",
+		"10.0.0.0/8",
+		"100.64.0.0/10",
+		"127.0.0.0/8",
+		"169.254.0.0/16",
+		"172.16.0.0/12",
+		"192.0.0.0/24",
+		"192.168.0.0/16",
+		"198.18.0.0/15",
+		"224.0.0.0/4",
+		"240.0.0.0/4",
+		"::/128",
+		"::1/128",
+		"fc00::/7",
+		"fe80::/10",
+		"ff00::/8",
+	} {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// publicIP reports whether ip is reachable on the internet. IPv4 addresses mapped to IPv6 are
+// checked as IPv4 addresses.
+func publicIP(ip net.IP) bool {
+	for _, n := range nonPublicNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Notify posts e to the webhook URL of p. Any response other than a 2xx is an error.
+func (wn *WebhookNotifier) Notify(ctx context.Context, p NotificationPreferences, e Event) error {
+	if p.WebhookURL == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Auction-Event", string(e.Type))
+
+	resp, err := wn.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// the body is drained so the connection can be reused
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+// SMTPNotifier is a Notifier emailing the events to the users through an SMTP server. The connection
+// is upgraded with STARTTLS when the server supports it.
+type SMTPNotifier struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPNotifier returns an SMTPNotifier sending its emails from the address from through the server
+// at addr (host:port), authenticating with auth unless it is nil.
+func NewSMTPNotifier(addr, from string, auth smtp.Auth) *SMTPNotifier {
+	return &SMTPNotifier{addr: addr, from: from, auth: auth}
+}
+
+// Notify emails e to the address of p. The whole exchange with the server must end before the
+// deadline of ctx.
+func (sn *SMTPNotifier) Notify(ctx context.Context, p NotificationPreferences, e Event) error {
+	if p.Email == "" {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(sn.addr)
+	if err != nil {
+		return err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", sn.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if sn.auth != nil {
+		if err := c.Auth(sn.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(sn.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(p.Email); err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(sn.email(p.Email, e)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// email returns the email notifying to of e. The subject is encoded, as it holds the name of the item.
+func (sn *SMTPNotifier) email(to string, e Event) []byte {
+	subject, text := message(e)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", sn.from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", e.At.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(text)
+	b.WriteString("\r\n")
+
+	return b.Bytes()
+}
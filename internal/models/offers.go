@@ -0,0 +1,270 @@
+package models
+
+import (
+	"time"
+)
+
+// OfferStatus is the answer given to a second-chance Offer.
+type OfferStatus string
+
+const (
+	OfferPending  OfferStatus = "pending"  // waiting for the user to answer
+	OfferAccepted OfferStatus = "accepted" // the units were added to the order of the item
+	OfferDeclined OfferStatus = "declined" // the user turned it down
+	OfferExpired  OfferStatus = "expired"  // the user did not answer in time
+)
+
+// Offer is a second chance given to a bidder who did not win an item, to buy the units left by a
+// buyer who did not pay in time, at the amount of their last bid for each unit. Offers go down the
+// ranking of the bids, one at a time, until a bidder accepts.
+type Offer struct {
+	ID       int64       `json:"id"`
+	ItemID   int64       `json:"itemId"`
+	OrderID  int64       `json:"orderId"`
+	BidID    int64       `json:"bidId"`
+	UserID   int64       `json:"userId"`
+	Quantity int         `json:"quantity"`
+	Amount   Money       `json:"amount"`
+	Status   OfferStatus `json:"status"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type OfferDB interface {
+	TxCreate(*Offer) error
+	TxUpdate(int64, func(*Offer) error) error
+	Get(int64) (Offer, error)
+	ListOffersByItemID(int64) ([]Offer, error)
+	ListOffersByUserID(int64) ([]Offer, error)
+	ListPendingOffers() ([]Offer, error)
+}
+
+// enforceDeadlines defaults the lines of o whose buyer did not pay before their deadline: the buyer
+// gets a strike, their funds held on the item are released, and the units are offered to the next
+// bidder of item i. It must be called while the order is locked.
+func (ss *settlementService) enforceDeadlines(i Item, o *Order) error {
+	now := ss.clock.Now()
+
+	for n := range o.Lines {
+		l := &o.Lines[n]
+		if !l.due() || l.DueAt.IsZero() || now.Before(l.DueAt) {
+			continue
+		}
+
+		l.Defaulted = true
+
+		if err := ss.userService.TxStrike(l.BuyerID); err != nil {
+			return err
+		}
+		if err := ss.wallets.TxRelease(o.ItemID, l.BuyerID); err != nil {
+			return err
+		}
+		if err := ss.offerNext(i, *o, l.Quantity); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// offerNext makes an offer of quantity units of item i to the best ranked bidder who was not a buyer
+// on order o, nor made an offer for the item already, at their last active bid as long as it meets the
+// reserve price. Reverse auctions get no offers, as their bidders are the ones getting paid. It must be called while
+// the order is locked.
+func (ss *settlementService) offerNext(i Item, o Order, quantity int) error {
+	if ss.offerWindow == 0 || i.Direction == AuctionReverse {
+		return nil
+	}
+
+	bids, err := ss.bids.ListBidsByItemID(i.ID)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+
+	offers, err := ss.offers.ListOffersByItemID(i.ID)
+	if err != nil {
+		return err
+	}
+
+	skip := map[int64]bool{i.SellerID: true}
+	for _, l := range o.Lines {
+		skip[l.BuyerID] = true
+	}
+	for _, of := range offers {
+		skip[of.UserID] = true
+	}
+
+	ranked := activeBids(bids)
+	rankBids(i, ranked)
+
+	last := map[int64]Bid{}
+	for _, b := range ranked {
+		if v, found := last[b.UserID]; !found || b.ID > v.ID {
+			last[b.UserID] = b
+		}
+	}
+
+	for _, b := range ranked {
+		if skip[b.UserID] {
+			continue
+		}
+
+		// the users come in the order of their best bid, and are offered the item at their last one
+		skip[b.UserID] = true
+		b = last[b.UserID]
+		if !i.ReserveMet(b.Amount) {
+			continue
+		}
+
+		q := units(b.Quantity)
+		if q > quantity {
+			q = quantity
+		}
+
+		now := ss.clock.Now()
+		return ss.offers.TxCreate(&Offer{
+			ItemID:    i.ID,
+			OrderID:   o.ID,
+			BidID:     b.ID,
+			UserID:    b.UserID,
+			Quantity:  q,
+			Amount:    b.Amount,
+			Status:    OfferPending,
+			CreatedAt: now,
+			ExpiresAt: now.Add(ss.offerWindow),
+		})
+	}
+
+	return nil
+}
+
+// expireOffers expires the pending offers whose window is over, making them to the next bidders.
+func (ss *settlementService) expireOffers() error {
+	offers, err := ss.offers.ListPendingOffers()
+	if err != nil {
+		return err
+	}
+
+	now := ss.clock.Now()
+	for _, of := range offers {
+		if now.Before(of.ExpiresAt) {
+			continue
+		}
+
+		// the user may have answered in the meantime
+		if err := ss.answer(of, OfferExpired); err != nil && err != ErrConflict {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ss *settlementService) ListOffersByUserID(userID int64) ([]Offer, error) {
+	if _, err := ss.userService.Get(userID); err != nil {
+		return nil, ValidationError{"user": ErrNotFound}
+	}
+
+	return ss.offers.ListOffersByUserID(userID)
+}
+
+func (ss *settlementService) TxAcceptOffer(o *Offer) error {
+	stored, err := ss.userOffer(*o)
+	if err != nil {
+		return err
+	}
+
+	if err := ss.answer(stored, OfferAccepted); err != nil {
+		return answerError(err)
+	}
+
+	// the payment is taken straight away if the user has the funds
+	if err := ss.orders.TxUpdate(stored.OrderID, ss.collect); err != nil {
+		return err
+	}
+
+	*o, err = ss.offers.Get(stored.ID)
+	return err
+}
+
+func (ss *settlementService) TxDeclineOffer(o *Offer) error {
+	stored, err := ss.userOffer(*o)
+	if err != nil {
+		return err
+	}
+
+	if err := ss.answer(stored, OfferDeclined); err != nil {
+		return answerError(err)
+	}
+
+	*o, err = ss.offers.Get(stored.ID)
+	return err
+}
+
+// userOffer returns the stored offer identified by o.ID, which must have been made to o.UserID.
+func (ss *settlementService) userOffer(o Offer) (Offer, error) {
+	stored, err := ss.offers.Get(o.ID)
+	if err != nil || stored.UserID != o.UserID {
+		return Offer{}, ValidationError{"offer": ErrNotFound}
+	}
+
+	return stored, nil
+}
+
+// answer records status on the pending offer of. An accepted offer adds a line to the order of the
+// item, the others are made to the next bidder. It returns ErrConflict if the offer was answered
+// already, and ErrExpired if it is accepted or declined after its window.
+func (ss *settlementService) answer(of Offer, status OfferStatus) error {
+	item, err := ss.items.Get(of.ItemID)
+	if err != nil {
+		return err
+	}
+
+	var line OrderLine
+	if status == OfferAccepted {
+		price, err := of.Amount.Times(int64(of.Quantity))
+		if err != nil {
+			return err
+		}
+
+		if line, err = ss.line(item, Bid{ID: of.BidID, UserID: of.UserID}, of.Quantity, price); err != nil {
+			return err
+		}
+	}
+
+	// the order is locked first, so a single offer is made at a time for its units
+	return ss.orders.TxUpdate(of.OrderID, func(o *Order) error {
+		err := ss.offers.TxUpdate(of.ID, func(v *Offer) error {
+			if v.Status != OfferPending {
+				return ErrConflict
+			}
+
+			expired := !ss.clock.Now().Before(v.ExpiresAt)
+			if expired != (status == OfferExpired) {
+				return ErrExpired
+			}
+
+			v.Status = status
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if status != OfferAccepted {
+			return ss.offerNext(item, *o, of.Quantity)
+		}
+
+		o.Lines = append(o.Lines, line)
+		return nil
+	})
+}
+
+// answerError returns the error to give back when answering an offer fails.
+func answerError(err error) error {
+	if err == ErrConflict || err == ErrExpired {
+		return ValidationError{"offer": err.(PublicError)}
+	}
+	return err
+}
@@ -0,0 +1,203 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettlementService_Offers(t *testing.T) {
+	now := testNow
+	db := CreateDatabase()
+	db.SetClock(ClockFunc(func() time.Time { return now }))
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc)
+	wsvc := NewWalletService(db, usvc)
+	asvc := NewAuctionService(db)
+	ssvc := NewSettlementService(db, usvc, WithPaymentDeadline(time.Hour), WithOfferWindow(time.Hour))
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+	usvc.TxCreate(&User{Name: "Summer"})
+	usvc.TxCreate(&User{Name: "Beth"})
+	seller := testSeller(usvc)
+
+	item := Item{Name: "portal gun", SellerID: seller, Value: gbp(10), ReservePrice: gbpPtr(25), EndsAt: now.Add(time.Hour)}
+	assert.NoError(t, isvc.TxCreate(&item))
+
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 4, ItemID: item.ID, Amount: gbp(20)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 3, ItemID: item.ID, Amount: gbp(30)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: item.ID, Amount: gbp(40)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: gbp(50)}))
+
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 3, Amount: gbp(100)}))
+
+	now = item.EndsAt
+	_, err := asvc.Advance()
+	assert.NoError(t, err)
+	_, err = ssvc.Settle()
+	assert.NoError(t, err)
+
+	order, err := ssvc.GetOrderByItemID(item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(time.Hour), order.Lines[0].DueAt)
+
+	// the winner does not pay in time, so the runner-up gets an offer at their bid
+	now = now.Add(time.Hour)
+	_, err = ssvc.Settle()
+	assert.NoError(t, err)
+
+	winner, err := usvc.Get(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, winner.Strikes)
+
+	order, err = ssvc.GetOrderByItemID(item.ID)
+	assert.NoError(t, err)
+	assert.True(t, order.Lines[0].Defaulted)
+
+	offers, err := ssvc.ListOffersByUserID(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []Offer{{
+		ID:        1,
+		ItemID:    item.ID,
+		OrderID:   order.ID,
+		BidID:     3,
+		UserID:    2,
+		Quantity:  1,
+		Amount:    gbp(40),
+		Status:    OfferPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}}, offers)
+	assert.True(t, ssvc.Outstanding())
+
+	// offers are answered by the users they were made to only
+	err = ssvc.TxAcceptOffer(&Offer{ID: 1, UserID: 3})
+	assert.True(t, errors.Is(err, ValidationError{"offer": ErrNotFound}), "unexpected error %v", err)
+
+	offer := Offer{ID: 1, UserID: 2}
+	assert.NoError(t, ssvc.TxDeclineOffer(&offer))
+	assert.Equal(t, OfferDeclined, offer.Status)
+
+	err = ssvc.TxAcceptOffer(&Offer{ID: 1, UserID: 2})
+	assert.True(t, errors.Is(err, ValidationError{"offer": ErrConflict}), "unexpected error %v", err)
+
+	offers, err = ssvc.ListOffersByUserID(3)
+	assert.NoError(t, err)
+	assert.Len(t, offers, 1)
+	assert.Equal(t, gbp(30), offers[0].Amount)
+
+	// the offer is taken up and paid straight away
+	offer = Offer{ID: offers[0].ID, UserID: 3}
+	assert.NoError(t, ssvc.TxAcceptOffer(&offer))
+	assert.Equal(t, OfferAccepted, offer.Status)
+
+	order, err = ssvc.GetOrderByItemID(item.ID)
+	assert.NoError(t, err)
+	assert.Len(t, order.Lines, 2)
+	assert.Equal(t, int64(3), order.Lines[1].BuyerID)
+	assert.Equal(t, gbp(30), order.Lines[1].Price)
+	assert.True(t, order.Lines[1].Paid)
+	assert.False(t, ssvc.Outstanding())
+
+	wallet, err := wsvc.Get(seller)
+	assert.NoError(t, err)
+	assert.Equal(t, []Balance{{Total: gbp(30), Held: gbp(0), Available: gbp(30)}}, wallet.Balances)
+}
+
+func TestSettlementService_Offers_LastBid(t *testing.T) {
+	now := testNow
+	db := CreateDatabase()
+	db.SetClock(ClockFunc(func() time.Time { return now }))
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	asvc := NewAuctionService(db)
+	ssvc := NewSettlementService(db, usvc, WithPaymentDeadline(time.Hour), WithOfferWindow(time.Hour))
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+
+	item := Item{Name: "portal gun", SellerID: testSeller(usvc), Value: gbp(10), EndsAt: now.Add(time.Hour)}
+	assert.NoError(t, isvc.TxCreate(&item))
+
+	// the runner-up lowered their bid after their best one
+	for _, b := range []Bid{
+		{UserID: 2, ItemID: item.ID, Amount: gbp(45)},
+		{UserID: 1, ItemID: item.ID, Amount: gbp(50)},
+		{UserID: 2, ItemID: item.ID, Amount: gbp(35)},
+	} {
+		b.Status, b.CreatedAt = BidActive, now
+		assert.NoError(t, db.bids.TxCreate(&b))
+	}
+
+	now = item.EndsAt
+	_, err := asvc.Advance()
+	assert.NoError(t, err)
+	_, err = ssvc.Settle()
+	assert.NoError(t, err)
+
+	now = now.Add(time.Hour)
+	_, err = ssvc.Settle()
+	assert.NoError(t, err)
+
+	offers, err := ssvc.ListOffersByUserID(2)
+	assert.NoError(t, err)
+	if assert.Len(t, offers, 1) {
+		assert.Equal(t, int64(3), offers[0].BidID)
+		assert.Equal(t, gbp(35), offers[0].Amount)
+	}
+}
+
+func TestSettlementService_Offers_Expired(t *testing.T) {
+	now := testNow
+	db := CreateDatabase()
+	db.SetClock(ClockFunc(func() time.Time { return now }))
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc)
+	asvc := NewAuctionService(db)
+	ssvc := NewSettlementService(db, usvc, WithPaymentDeadline(time.Hour), WithOfferWindow(time.Hour))
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+
+	item := Item{Name: "portal gun", SellerID: testSeller(usvc), Value: gbp(10), EndsAt: now.Add(time.Hour)}
+	assert.NoError(t, isvc.TxCreate(&item))
+
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: item.ID, Amount: gbp(40)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: gbp(50)}))
+
+	now = item.EndsAt
+	_, err := asvc.Advance()
+	assert.NoError(t, err)
+	_, err = ssvc.Settle()
+	assert.NoError(t, err)
+
+	now = now.Add(time.Hour)
+	_, err = ssvc.Settle()
+	assert.NoError(t, err)
+
+	// the offer cannot be accepted once its window is over
+	now = now.Add(time.Hour)
+	err = ssvc.TxAcceptOffer(&Offer{ID: 1, UserID: 2})
+	assert.True(t, errors.Is(err, ValidationError{"offer": ErrExpired}), "unexpected error %v", err)
+
+	_, err = ssvc.Settle()
+	assert.NoError(t, err)
+
+	// there is no other bidder to make an offer to
+	offers, err := ssvc.ListOffersByUserID(2)
+	assert.NoError(t, err)
+	assert.Len(t, offers, 1)
+	assert.Equal(t, OfferExpired, offers[0].Status)
+	assert.False(t, ssvc.Outstanding())
+
+	_, err = ssvc.ListOffersByUserID(99)
+	assert.True(t, errors.Is(err, ValidationError{"user": ErrNotFound}), "unexpected error %v", err)
+}
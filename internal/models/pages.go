@@ -0,0 +1,352 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultPageLimit is the number of elements of a page unless another limit is asked for, and
+	// MaxPageLimit the largest limit accepted.
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+// Page asks for a page of a list: up to Limit elements sorted by Sort, following the position given by
+// Cursor. Sort is the name of a field, preceded by a minus sign to sort in descending order, and an
+// empty Cursor asks for the first page. List names the list, so the cursors of a list cannot be used
+// on another.
+type Page struct {
+	List   string
+	Sort   string
+	Limit  int
+	Cursor string
+}
+
+// pageKey is the position of an element in a sorted list. Elements are sorted by Text, then by
+// Number, then by ID, so no two elements of a list share a position.
+type pageKey struct {
+	Text   string `json:"t,omitempty"`
+	Number int64  `json:"n,omitempty"`
+	ID     int64  `json:"i"`
+}
+
+func (k pageKey) less(o pageKey) bool {
+	switch {
+	case k.Text != o.Text:
+		return k.Text < o.Text
+	case k.Number != o.Number:
+		return k.Number < o.Number
+	}
+	return k.ID < o.ID
+}
+
+// cursor is the position of the last element of a page, for the list and sort it was read with.
+type cursor struct {
+	List string  `json:"l"`
+	Sort string  `json:"s"`
+	Key  pageKey `json:"k"`
+}
+
+// Pager splits the lists into pages, sorted, and signs the cursors handed to the clients, so that a
+// cursor cannot be forged nor altered.
+type Pager struct {
+	key []byte
+}
+
+// NewPager returns a pager signing the cursors with the cursor key of db.
+func NewPager(db *DB) *Pager {
+	return &Pager{key: db.cursorKey}
+}
+
+// newCursorKey returns a random key to sign the cursors with, which is enough as long as they do not
+// outlive the data of the in-memory database.
+func newCursorKey() []byte {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// Items returns the page p of items, sorted by "id", "value" (the initial value), "createdAt",
+// "startsAt" or "endsAt", and the cursor of the next page, empty on the last one.
+func (pg *Pager) Items(items []Item, p Page) ([]Item, string, error) {
+	keys := map[string]func(int) pageKey{
+		"id":        func(n int) pageKey { return pageKey{} },
+		"value":     func(n int) pageKey { return moneyKey(items[n].Value) },
+		"createdAt": func(n int) pageKey { return timeKey(items[n].CreatedAt) },
+		"startsAt":  func(n int) pageKey { return timeKey(items[n].StartsAt) },
+		"endsAt":    func(n int) pageKey { return timeKey(items[n].EndsAt) },
+	}
+
+	indexes, next, err := pg.paginate(p, len(items), func(n int) int64 { return items[n].ID }, keys)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ret := make([]Item, 0, len(indexes))
+	for _, n := range indexes {
+		ret = append(ret, items[n])
+	}
+	return ret, next, nil
+}
+
+// Bids returns the page p of bids, sorted by "id", "amount" or "createdAt", and the cursor of the
+// next page, empty on the last one.
+func (pg *Pager) Bids(bids []Bid, p Page) ([]Bid, string, error) {
+	keys := map[string]func(int) pageKey{
+		"id":        func(n int) pageKey { return pageKey{} },
+		"amount":    func(n int) pageKey { return moneyKey(bids[n].Amount) },
+		"createdAt": func(n int) pageKey { return timeKey(bids[n].CreatedAt) },
+	}
+
+	indexes, next, err := pg.paginate(p, len(bids), func(n int) int64 { return bids[n].ID }, keys)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ret := make([]Bid, 0, len(indexes))
+	for _, n := range indexes {
+		ret = append(ret, bids[n])
+	}
+	return ret, next, nil
+}
+
+// Users returns the page p of users, sorted by "id", "name" or "createdAt", and the cursor of the next
+// page, empty on the last one.
+func (pg *Pager) Users(users []User, p Page) ([]User, string, error) {
+	keys := map[string]func(int) pageKey{
+		"id":        func(n int) pageKey { return pageKey{} },
+		"name":      func(n int) pageKey { return pageKey{Text: strings.ToLower(users[n].Name)} },
+		"createdAt": func(n int) pageKey { return timeKey(users[n].CreatedAt) },
+	}
+
+	indexes, next, err := pg.paginate(p, len(users), func(n int) int64 { return users[n].ID }, keys)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ret := make([]User, 0, len(indexes))
+	for _, n := range indexes {
+		ret = append(ret, users[n])
+	}
+	return ret, next, nil
+}
+
+// Watchlist returns the page p of the entries of a watchlist, sorted by "addedAt", "id" (the item) or
+// "endsAt", and the cursor of the next page, empty on the last one. Entries are sorted by "-addedAt",
+// the most recently added first, unless p asks for another sort.
+func (pg *Pager) Watchlist(entries []WatchlistEntry, p Page) ([]WatchlistEntry, string, error) {
+	keys := map[string]func(int) pageKey{
+		"id":      func(n int) pageKey { return pageKey{} },
+		"addedAt": func(n int) pageKey { return timeKey(entries[n].AddedAt) },
+		"endsAt":  func(n int) pageKey { return timeKey(entries[n].Item.EndsAt) },
+	}
+
+	if p.Sort == "" {
+		p.Sort = "-addedAt"
+	}
+
+	indexes, next, err := pg.paginate(p, len(entries), func(n int) int64 { return entries[n].Item.ID }, keys)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ret := make([]WatchlistEntry, 0, len(indexes))
+	for _, n := range indexes {
+		ret = append(ret, entries[n])
+	}
+	return ret, next, nil
+}
+
+// Invoices returns the page p of the invoices of a user, sorted by "issuedAt" or "total", and the
+// cursor of the next page, empty on the last one. Invoices are sorted by "issuedAt" unless p asks for
+// another sort, those of the same order by bid, as a user has at most one invoice per bid.
+func (pg *Pager) Invoices(invs []Invoice, p Page) ([]Invoice, string, error) {
+	keys := map[string]func(int) pageKey{
+		"issuedAt": func(n int) pageKey { return timeKey(invs[n].IssuedAt) },
+		"total":    func(n int) pageKey { return moneyKey(invs[n].Total) },
+	}
+
+	if p.Sort == "" {
+		p.Sort = "issuedAt"
+	}
+
+	indexes, next, err := pg.paginate(p, len(invs), func(n int) int64 { return invs[n].BidID }, keys)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ret := make([]Invoice, 0, len(indexes))
+	for _, n := range indexes {
+		ret = append(ret, invs[n])
+	}
+	return ret, next, nil
+}
+
+// Offers returns the page p of second-chance offers, sorted by "id", "amount", "createdAt" or
+// "expiresAt", and the cursor of the next page, empty on the last one.
+func (pg *Pager) Offers(offers []Offer, p Page) ([]Offer, string, error) {
+	keys := map[string]func(int) pageKey{
+		"id":        func(n int) pageKey { return pageKey{} },
+		"amount":    func(n int) pageKey { return moneyKey(offers[n].Amount) },
+		"createdAt": func(n int) pageKey { return timeKey(offers[n].CreatedAt) },
+		"expiresAt": func(n int) pageKey { return timeKey(offers[n].ExpiresAt) },
+	}
+
+	indexes, next, err := pg.paginate(p, len(offers), func(n int) int64 { return offers[n].ID }, keys)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ret := make([]Offer, 0, len(indexes))
+	for _, n := range indexes {
+		ret = append(ret, offers[n])
+	}
+	return ret, next, nil
+}
+
+// SearchResults returns the page p of search results, sorted by "score" or "id" (the item), and the
+// cursor of the next page, empty on the last one. Results are sorted by "-score", the most relevant
+// first, unless p asks for another sort.
+func (pg *Pager) SearchResults(results []SearchResult, p Page) ([]SearchResult, string, error) {
+	keys := map[string]func(int) pageKey{
+		"id": func(n int) pageKey { return pageKey{} },
+		// scores are never negative, and the bits of non-negative floats sort like the floats
+		"score": func(n int) pageKey { return pageKey{Number: int64(math.Float64bits(results[n].Score))} },
+	}
+
+	if p.Sort == "" {
+		p.Sort = "-score"
+	}
+
+	indexes, next, err := pg.paginate(p, len(results), func(n int) int64 { return results[n].Item.ID }, keys)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ret := make([]SearchResult, 0, len(indexes))
+	for _, n := range indexes {
+		ret = append(ret, results[n])
+	}
+	return ret, next, nil
+}
+
+// timeKey sorts times in chronological order.
+func timeKey(t time.Time) pageKey {
+	return pageKey{Number: t.UnixNano()}
+}
+
+// moneyKey sorts amounts of money by currency, then by amount.
+func moneyKey(m Money) pageKey {
+	return pageKey{Text: m.Currency, Number: m.Amount}
+}
+
+// paginate works out the page p of a list of size elements, identified by id and positioned by the
+// key of the sort of p. It returns the indexes of the elements of the page, in order, and the cursor
+// of the next page.
+func (pg *Pager) paginate(p Page, size int, id func(int) int64, keys map[string]func(int) pageKey) ([]int, string, error) {
+	ve := ValidationError{}
+
+	if p.Sort == "" {
+		p.Sort = "id"
+	}
+	desc := strings.HasPrefix(p.Sort, "-")
+	key, ok := keys[strings.TrimPrefix(p.Sort, "-")]
+	if !ok {
+		ve["sort"] = ErrInvalid
+	}
+
+	if p.Limit == 0 {
+		p.Limit = DefaultPageLimit
+	}
+	if p.Limit < 0 || p.Limit > MaxPageLimit {
+		ve["limit"] = ErrInvalid
+	}
+
+	var after *pageKey
+	if p.Cursor != "" {
+		c, err := pg.decode(p.Cursor)
+		if err != nil || c.List != p.List || c.Sort != p.Sort {
+			ve["cursor"] = ErrInvalid
+		}
+		after = &c.Key
+	}
+
+	if len(ve) > 0 {
+		return nil, "", ve
+	}
+
+	positions := make([]pageKey, size)
+	for n := range positions {
+		positions[n] = key(n)
+		positions[n].ID = id(n)
+	}
+
+	before := func(a, b pageKey) bool {
+		if desc {
+			return b.less(a)
+		}
+		return a.less(b)
+	}
+
+	var indexes []int
+	for n := range positions {
+		if after == nil || before(*after, positions[n]) {
+			indexes = append(indexes, n)
+		}
+	}
+	sort.Slice(indexes, func(a, b int) bool { return before(positions[indexes[a]], positions[indexes[b]]) })
+
+	if len(indexes) <= p.Limit {
+		return indexes, "", nil
+	}
+
+	indexes = indexes[:p.Limit]
+	next := pg.encode(cursor{List: p.List, Sort: p.Sort, Key: positions[indexes[len(indexes)-1]]})
+	return indexes, next, nil
+}
+
+// encode returns c as an opaque string: its JSON document and signature, both encoded in base64.
+func (pg *Pager) encode(c cursor) string {
+	payload, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(pg.sign(payload))
+}
+
+// decode returns the cursor encoded in s, checking its signature.
+func (pg *Pager) decode(s string) (cursor, error) {
+	var c cursor
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 2 {
+		return c, ErrInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return c, ErrInvalid
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(mac, pg.sign(payload)) {
+		return c, ErrInvalid
+	}
+
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return c, ErrInvalid
+	}
+	return c, nil
+}
+
+func (pg *Pager) sign(payload []byte) []byte {
+	h := hmac.New(sha256.New, pg.key)
+	h.Write(payload)
+	return h.Sum(nil)
+}
@@ -0,0 +1,300 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPager_Bids(t *testing.T) {
+	pg := NewPager(CreateDatabase())
+
+	bids := []Bid{
+		{ID: 3, Amount: gbp(20), CreatedAt: testNow.Add(2 * time.Minute)},
+		{ID: 1, Amount: gbp(30), CreatedAt: testNow},
+		{ID: 4, Amount: gbp(10), CreatedAt: testNow.Add(3 * time.Minute)},
+		{ID: 2, Amount: gbp(20), CreatedAt: testNow.Add(time.Minute)},
+		{ID: 5, Amount: Money{Amount: 5, Currency: "EUR"}, CreatedAt: testNow.Add(-time.Minute)},
+	}
+
+	// pages returns the identification numbers of the bids of every page read one after the other
+	pages := func(t *testing.T, p Page) [][]int64 {
+		var ret [][]int64
+		for {
+			page, next, err := pg.Bids(bids, p)
+			if !assert.NoError(t, err) {
+				return nil
+			}
+
+			var ids []int64
+			for _, b := range page {
+				ids = append(ids, b.ID)
+			}
+			ret = append(ret, ids)
+
+			if next == "" {
+				return ret
+			}
+			p.Cursor = next
+		}
+	}
+
+	var cases = []struct {
+		name   string
+		page   Page
+		outids [][]int64
+	}{
+		{"default", Page{}, [][]int64{{1, 2, 3, 4, 5}}},
+		{"by id", Page{Sort: "id", Limit: 2}, [][]int64{{1, 2}, {3, 4}, {5}}},
+		{"by id descending", Page{Sort: "-id", Limit: 2}, [][]int64{{5, 4}, {3, 2}, {1}}},
+		// ties are broken by id, and amounts are sorted by currency first
+		{"by amount", Page{Sort: "amount", Limit: 2}, [][]int64{{5, 4}, {2, 3}, {1}}},
+		{"by amount descending", Page{Sort: "-amount", Limit: 2}, [][]int64{{1, 3}, {2, 4}, {5}}},
+		{"by creation", Page{Sort: "createdAt", Limit: 3}, [][]int64{{5, 1, 2}, {3, 4}}},
+		{"exact pages", Page{Limit: 5}, [][]int64{{1, 2, 3, 4, 5}}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.outids, pages(t, tt.page))
+		})
+	}
+
+	// a bid placed between two pages is listed if it comes after the cursor
+	_, next, err := pg.Bids(bids, Page{Limit: 2})
+	assert.NoError(t, err)
+	page, _, err := pg.Bids(append(bids, Bid{ID: 6}), Page{Limit: 10, Cursor: next})
+	assert.NoError(t, err)
+	assert.Len(t, page, 4)
+
+	empty, next, err := pg.Bids(nil, Page{})
+	assert.NoError(t, err)
+	assert.Equal(t, []Bid{}, empty)
+	assert.Empty(t, next)
+}
+
+func TestPager_Errors(t *testing.T) {
+	pg := NewPager(CreateDatabase())
+
+	users := []User{{ID: 1, Name: "Morty"}, {ID: 2, Name: "jerry"}, {ID: 3, Name: "Rick"}}
+	page, next, err := pg.Users(users, Page{List: "/users/", Sort: "name", Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []User{users[1]}, page)
+
+	// the cursor is signed: changing a single character of it makes it invalid
+	tampered := "f" + strings.TrimPrefix(next, "e")
+	assert.NotEqual(t, next, tampered)
+	other := NewPager(CreateDatabase())
+
+	var cases = []struct {
+		name   string
+		pager  *Pager
+		page   Page
+		outerr error
+	}{
+		{"next page", pg, Page{List: "/users/", Sort: "name", Cursor: next}, nil},
+		{"unknown sort", pg, Page{List: "/users/", Sort: "amount"}, ValidationError{"sort": ErrInvalid}},
+		{"negative limit", pg, Page{List: "/users/", Limit: -1}, ValidationError{"limit": ErrInvalid}},
+		{"limit too high", pg, Page{List: "/users/", Limit: MaxPageLimit + 1}, ValidationError{"limit": ErrInvalid}},
+		{"garbage cursor", pg, Page{List: "/users/", Sort: "name", Cursor: "garbage"}, ValidationError{"cursor": ErrInvalid}},
+		{"tampered cursor", pg, Page{List: "/users/", Sort: "name", Cursor: tampered}, ValidationError{"cursor": ErrInvalid}},
+		{"other key", other, Page{List: "/users/", Sort: "name", Cursor: next}, ValidationError{"cursor": ErrInvalid}},
+		{"other sort", pg, Page{List: "/users/", Sort: "-name", Cursor: next}, ValidationError{"cursor": ErrInvalid}},
+		{"other list", pg, Page{List: "/items/", Sort: "name", Cursor: next}, ValidationError{"cursor": ErrInvalid}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := tt.pager.Users(users, tt.page)
+			assert.Equal(t, tt.outerr, err)
+		})
+	}
+}
+
+func TestPager_Items(t *testing.T) {
+	pg := NewPager(CreateDatabase())
+
+	items := []Item{
+		{ID: 1, Value: gbp(30), StartsAt: testNow, EndsAt: testNow.Add(3 * time.Hour), CreatedAt: testNow.Add(-time.Minute)},
+		{ID: 2, Value: gbp(10), StartsAt: testNow.Add(time.Hour), EndsAt: testNow.Add(time.Hour), CreatedAt: testNow.Add(-time.Hour)},
+		{ID: 3, Value: gbp(20), StartsAt: testNow.Add(-time.Hour), EndsAt: testNow.Add(2 * time.Hour), CreatedAt: testNow},
+	}
+
+	for sort, outids := range map[string][]int64{
+		"id":         {1, 2, 3},
+		"value":      {2, 3, 1},
+		"-value":     {1, 3, 2},
+		"createdAt":  {2, 1, 3},
+		"-createdAt": {3, 1, 2},
+		"startsAt":   {3, 1, 2},
+		"endsAt":     {2, 3, 1},
+	} {
+		page, next, err := pg.Items(items, Page{Sort: sort})
+		assert.NoError(t, err)
+		assert.Empty(t, next)
+
+		var ids []int64
+		for _, i := range page {
+			ids = append(ids, i.ID)
+		}
+		assert.Equal(t, outids, ids, sort)
+	}
+}
+
+func TestPager_Users(t *testing.T) {
+	pg := NewPager(CreateDatabase())
+
+	users := []User{
+		{ID: 1, Name: "Morty", CreatedAt: testNow.Add(time.Minute)},
+		{ID: 2, Name: "jerry", CreatedAt: testNow},
+		{ID: 3, Name: "Rick", CreatedAt: testNow.Add(-time.Minute)},
+	}
+
+	for sort, outids := range map[string][]int64{
+		"id":         {1, 2, 3},
+		"name":       {2, 1, 3},
+		"createdAt":  {3, 2, 1},
+		"-createdAt": {1, 2, 3},
+	} {
+		page, next, err := pg.Users(users, Page{Sort: sort})
+		assert.NoError(t, err)
+		assert.Empty(t, next)
+
+		var ids []int64
+		for _, u := range page {
+			ids = append(ids, u.ID)
+		}
+		assert.Equal(t, outids, ids, sort)
+	}
+}
+
+func TestPager_Watchlist(t *testing.T) {
+	pg := NewPager(CreateDatabase())
+
+	entries := []WatchlistEntry{
+		{Item: Item{ID: 1, EndsAt: testNow.Add(2 * time.Hour)}, AddedAt: testNow},
+		{Item: Item{ID: 2, EndsAt: testNow.Add(3 * time.Hour)}, AddedAt: testNow.Add(time.Minute)},
+		{Item: Item{ID: 3, EndsAt: testNow.Add(time.Hour)}, AddedAt: testNow.Add(-time.Minute)},
+	}
+
+	for sort, outids := range map[string][]int64{
+		"":        {2, 1, 3},
+		"id":      {1, 2, 3},
+		"addedAt": {3, 1, 2},
+		"endsAt":  {3, 1, 2},
+	} {
+		page, next, err := pg.Watchlist(entries, Page{Sort: sort})
+		assert.NoError(t, err)
+		assert.Empty(t, next)
+
+		var ids []int64
+		for _, e := range page {
+			ids = append(ids, e.Item.ID)
+		}
+		assert.Equal(t, outids, ids, sort)
+	}
+
+	page, next, err := pg.Watchlist(entries, Page{Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, page, 2)
+	page, next, err = pg.Watchlist(entries, Page{Limit: 2, Cursor: next})
+	assert.NoError(t, err)
+	assert.Equal(t, []WatchlistEntry{entries[2]}, page)
+	assert.Empty(t, next)
+}
+
+func TestPager_Invoices(t *testing.T) {
+	pg := NewPager(CreateDatabase())
+
+	invs := []Invoice{
+		{BidID: 2, Total: gbp(10), IssuedAt: testNow},
+		{BidID: 1, Total: gbp(30), IssuedAt: testNow.Add(time.Hour)},
+		{BidID: 3, Total: gbp(20), IssuedAt: testNow},
+	}
+
+	for sort, outids := range map[string][]int64{
+		"":          {2, 3, 1},
+		"issuedAt":  {2, 3, 1},
+		"-issuedAt": {1, 3, 2},
+		"total":     {2, 3, 1},
+		"-total":    {1, 3, 2},
+	} {
+		page, next, err := pg.Invoices(invs, Page{Sort: sort})
+		assert.NoError(t, err)
+		assert.Empty(t, next)
+
+		var ids []int64
+		for _, inv := range page {
+			ids = append(ids, inv.BidID)
+		}
+		assert.Equal(t, outids, ids, sort)
+	}
+
+	_, _, err := pg.Invoices(invs, Page{Sort: "id"})
+	assert.Equal(t, ValidationError{"sort": ErrInvalid}, err)
+}
+
+func TestPager_Offers(t *testing.T) {
+	pg := NewPager(CreateDatabase())
+
+	offers := []Offer{
+		{ID: 1, Amount: gbp(20), CreatedAt: testNow, ExpiresAt: testNow.Add(2 * time.Hour)},
+		{ID: 2, Amount: gbp(10), CreatedAt: testNow.Add(time.Minute), ExpiresAt: testNow.Add(time.Hour)},
+		{ID: 3, Amount: gbp(30), CreatedAt: testNow.Add(-time.Minute), ExpiresAt: testNow.Add(3 * time.Hour)},
+	}
+
+	for sort, outids := range map[string][]int64{
+		"id":         {1, 2, 3},
+		"amount":     {2, 1, 3},
+		"createdAt":  {3, 1, 2},
+		"-expiresAt": {3, 1, 2},
+	} {
+		page, next, err := pg.Offers(offers, Page{Sort: sort})
+		assert.NoError(t, err)
+		assert.Empty(t, next)
+
+		var ids []int64
+		for _, o := range page {
+			ids = append(ids, o.ID)
+		}
+		assert.Equal(t, outids, ids, sort)
+	}
+}
+
+func TestPager_SearchResults(t *testing.T) {
+	pg := NewPager(CreateDatabase())
+
+	results := []SearchResult{
+		{Item: Item{ID: 1}, Score: 0.5},
+		{Item: Item{ID: 2}, Score: 2.25},
+		{Item: Item{ID: 3}, Score: 1},
+		{Item: Item{ID: 4}, Score: 0},
+	}
+
+	for sort, outids := range map[string][]int64{
+		"":       {2, 3, 1, 4},
+		"score":  {4, 1, 3, 2},
+		"-score": {2, 3, 1, 4},
+		"id":     {1, 2, 3, 4},
+	} {
+		page, next, err := pg.SearchResults(results, Page{Sort: sort})
+		assert.NoError(t, err)
+		assert.Empty(t, next)
+
+		var ids []int64
+		for _, r := range page {
+			ids = append(ids, r.Item.ID)
+		}
+		assert.Equal(t, outids, ids, sort)
+	}
+
+	page, next, err := pg.SearchResults(results, Page{Limit: 3})
+	assert.NoError(t, err)
+	assert.Len(t, page, 3)
+	page, next, err = pg.SearchResults(results, Page{Limit: 3, Cursor: next})
+	assert.NoError(t, err)
+	assert.Equal(t, []SearchResult{results[3]}, page)
+	assert.Empty(t, next)
+}
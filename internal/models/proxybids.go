@@ -0,0 +1,178 @@
+package models
+
+import (
+	"sort"
+)
+
+// ProxyBid is the hidden maximum a user is willing to pay for an item. The bid service places the
+// smallest winning bid on behalf of the user every time they are outbid, up to MaxAmount.
+type ProxyBid struct {
+	ID        int64 `json:"id"`
+	UserID    int64 `json:"userId"`
+	ItemID    int64 `json:"itemId"`
+	MaxAmount Money `json:"maxAmount"`
+}
+
+type ProxyBidDB interface {
+	TxCreate(*ProxyBid) error
+	ListProxyBidsByItemID(int64) ([]ProxyBid, error)
+
+	// TxRestore puts back the proxy bids of an item as they were, dropping those registered since.
+	TxRestore(itemID int64, proxies []ProxyBid) error
+}
+
+// TxCreateProxy registers the proxy bid p, replacing the previous maximum of the user on the item,
+// and places the bids it is entitled to straight away.
+func (bs *bidValidator) TxCreateProxy(p *ProxyBid) error {
+	b := &Bid{ItemID: p.ItemID, UserID: p.UserID, Amount: p.MaxAmount}
+
+	if err := bs.runValFuncs(b,
+		bs.itemExists,
+		bs.userExists,
+		bs.notSeller,
+		bs.itemOpen,
+		bs.validAmount,
+		bs.beatsItemValue,
+	); err != nil {
+		return err
+	}
+
+	// maximums in another currency are converted once, when they are registered
+	p.MaxAmount = b.Amount
+
+	return bs.txBidding(p.ItemID, func(i *Item) error {
+		if i.StateAt(bs.clock.Now()) != ItemOpen {
+			return ValidationError{"item": ErrNotOpen}
+		}
+
+		if i.Type != AuctionEnglish || i.Direction != AuctionForward || i.multiUnit() {
+			return ValidationError{"item": ErrUnsupported}
+		}
+
+		// a proxy that cannot beat the current winning bid would never place a bid, unless it
+		// belongs to the current winner, who may be raising their maximum
+		winning, err := bs.BidDB.GetWinningBid(i.ID)
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+		if winning.UserID != p.UserID {
+			if err := bs.checkIncrement(*i, b); err != nil {
+				if pe, ok := err.(PublicError); ok {
+					return ValidationError{"bid": pe}
+				}
+				return err
+			}
+		}
+
+		if err := bs.hold(*i, p.UserID, p.MaxAmount, false); err != nil {
+			return err
+		}
+
+		if err := bs.proxies.TxCreate(p); err != nil {
+			return err
+		}
+
+		return bs.bidPlaced(i)
+	})
+}
+
+// resolveProxies places the bids the proxies registered on item are entitled to after the last bid.
+// It must be called while item is locked.
+//
+// The proxy with the highest maximum wins, and ties go to the proxy registered first. The winner
+// bids the minimum increment over the best competing amount, which is either the current winning
+// bid or the maximum of the best proxy of any other user. That competing proxy bids its maximum
+// first, so the bid history shows how the price was reached.
+func (bs *bidValidator) resolveProxies(item Item) error {
+	proxies, err := bs.proxies.ListProxyBidsByItemID(item.ID)
+	if err != nil || len(proxies) == 0 {
+		return err
+	}
+
+	sort.Slice(proxies, func(a, b int) bool {
+		if proxies[a].MaxAmount.Amount != proxies[b].MaxAmount.Amount {
+			return proxies[a].MaxAmount.Amount > proxies[b].MaxAmount.Amount
+		}
+		return proxies[a].ID < proxies[b].ID
+	})
+
+	winning, err := bs.BidDB.GetWinningBid(item.ID)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	hasWinning := err == nil
+
+	top := proxies[0]
+
+	var runnerUp *ProxyBid
+	for n := range proxies[1:] {
+		if proxies[n+1].UserID != top.UserID {
+			runnerUp = &proxies[n+1]
+			break
+		}
+	}
+
+	// the best amount offered by someone else than the top proxy user
+	competing, hasCompeting := Money{}, false
+	if hasWinning && winning.UserID != top.UserID {
+		competing, hasCompeting = winning.Amount, true
+	}
+	if runnerUp != nil && (!hasCompeting || runnerUp.MaxAmount.Amount > competing.Amount) {
+		competing, hasCompeting = runnerUp.MaxAmount, true
+	}
+
+	if !hasCompeting {
+		if hasWinning {
+			return nil // the top proxy user is already winning
+		}
+
+		// no bids yet, so the top proxy opens the auction with the lowest acceptable amount
+		opening, err := item.Value.Add(Money{Amount: 1, Currency: item.Value.Currency})
+		if err != nil {
+			return err
+		}
+		return bs.placeProxyBid(item, top, opening)
+	}
+
+	amount := top.MaxAmount
+	if top.MaxAmount.Amount > competing.Amount {
+		next, err := bs.itemIncrement(item).NextMinimum(competing)
+		if err != nil {
+			return err
+		}
+		if next.Amount < amount.Amount {
+			amount = next
+		}
+	}
+
+	if runnerUp != nil && runnerUp.MaxAmount.Amount < amount.Amount {
+		if err := bs.placeProxyBid(item, *runnerUp, runnerUp.MaxAmount); err != nil {
+			return err
+		}
+	}
+
+	return bs.placeProxyBid(item, top, amount)
+}
+
+// placeProxyBid places a bid of amount on behalf of the owner of p, as long as it beats the current
+// winning bid and does not go beyond the maximum of p.
+func (bs *bidValidator) placeProxyBid(item Item, p ProxyBid, amount Money) error {
+	if amount.Amount > p.MaxAmount.Amount || amount.Amount <= item.Value.Amount {
+		return nil
+	}
+
+	winning, err := bs.BidDB.GetWinningBid(item.ID)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	if err == nil && amount.Amount <= winning.Amount.Amount {
+		return nil
+	}
+
+	return bs.storeBid(&Bid{
+		UserID: p.UserID,
+		ItemID: p.ItemID,
+		Amount: amount,
+		Kind:   BidProxy,
+	})
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBidService_TxCreateProxy(t *testing.T) {
+	type step struct {
+		proxy *ProxyBid // either a proxy bid or a manual bid is placed on each step
+		bid   *Bid
+	}
+
+	var cases = []struct {
+		name    string
+		steps   []step
+		outbids []Bid
+		outerr  error
+	}{
+		{
+			"opens_at_lowest_amount",
+			[]step{
+				{proxy: &ProxyBid{UserID: 1, MaxAmount: gbp(100)}},
+			},
+			[]Bid{
+				{UserID: 1, Amount: gbp(11), Kind: BidProxy},
+			},
+			nil,
+		},
+		{
+			"answers_manual_bid",
+			[]step{
+				{proxy: &ProxyBid{UserID: 1, MaxAmount: gbp(100)}},
+				{bid: &Bid{UserID: 2, Amount: gbp(20)}},
+			},
+			[]Bid{
+				{UserID: 1, Amount: gbp(11), Kind: BidProxy},
+				{UserID: 2, Amount: gbp(20), Kind: BidManual},
+				{UserID: 1, Amount: gbp(21), Kind: BidProxy},
+			},
+			nil,
+		},
+		{
+			"manual_bid_above_maximum",
+			[]step{
+				{proxy: &ProxyBid{UserID: 1, MaxAmount: gbp(100)}},
+				{bid: &Bid{UserID: 2, Amount: gbp(150)}},
+			},
+			[]Bid{
+				{UserID: 1, Amount: gbp(11), Kind: BidProxy},
+				{UserID: 2, Amount: gbp(150), Kind: BidManual},
+			},
+			nil,
+		},
+		{
+			"competing_proxies",
+			[]step{
+				{proxy: &ProxyBid{UserID: 1, MaxAmount: gbp(100)}},
+				{proxy: &ProxyBid{UserID: 2, MaxAmount: gbp(50)}},
+			},
+			[]Bid{
+				{UserID: 1, Amount: gbp(11), Kind: BidProxy},
+				{UserID: 2, Amount: gbp(50), Kind: BidProxy},
+				{UserID: 1, Amount: gbp(51), Kind: BidProxy},
+			},
+			nil,
+		},
+		{
+			"tie_goes_to_first_proxy",
+			[]step{
+				{proxy: &ProxyBid{UserID: 1, MaxAmount: gbp(100)}},
+				{proxy: &ProxyBid{UserID: 2, MaxAmount: gbp(100)}},
+			},
+			[]Bid{
+				{UserID: 1, Amount: gbp(11), Kind: BidProxy},
+				{UserID: 1, Amount: gbp(100), Kind: BidProxy},
+			},
+			nil,
+		},
+		{
+			"higher_proxy_takes_over",
+			[]step{
+				{proxy: &ProxyBid{UserID: 1, MaxAmount: gbp(100)}},
+				{proxy: &ProxyBid{UserID: 2, MaxAmount: gbp(150)}},
+			},
+			[]Bid{
+				{UserID: 1, Amount: gbp(11), Kind: BidProxy},
+				{UserID: 1, Amount: gbp(100), Kind: BidProxy},
+				{UserID: 2, Amount: gbp(101), Kind: BidProxy},
+			},
+			nil,
+		},
+		{
+			"winner_raises_maximum",
+			[]step{
+				{proxy: &ProxyBid{UserID: 1, MaxAmount: gbp(100)}},
+				{proxy: &ProxyBid{UserID: 1, MaxAmount: gbp(200)}},
+			},
+			[]Bid{
+				{UserID: 1, Amount: gbp(11), Kind: BidProxy},
+			},
+			nil,
+		},
+		{
+			"maximum_below_minimum",
+			[]step{
+				{bid: &Bid{UserID: 2, Amount: gbp(50)}},
+				{proxy: &ProxyBid{UserID: 1, MaxAmount: gbp(50)}},
+			},
+			[]Bid{
+				{UserID: 2, Amount: gbp(50), Kind: BidManual},
+			},
+			ValidationError{"bid": BidTooLowError{Minimum: gbp(51)}},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			db := CreateDatabase()
+			db.SetClock(testClock())
+
+			usvc := NewUserService(db)
+			isvc := NewItemService(db, usvc)
+			bsvc := NewBidService(db, isvc, usvc)
+
+			usvc.TxCreate(&User{Name: "Morty"})
+			usvc.TxCreate(&User{Name: "Rick"})
+
+			item := Item{Name: "portal gun", SellerID: testSeller(usvc), Value: gbp(10)}
+			assert.NoError(t, isvc.TxCreate(&item))
+
+			var err error
+			for _, s := range tt.steps {
+				if s.proxy != nil {
+					s.proxy.ItemID = item.ID
+					err = bsvc.TxCreateProxy(s.proxy)
+				} else {
+					s.bid.ItemID = item.ID
+					err = bsvc.TxCreate(s.bid)
+				}
+			}
+
+			if tt.outerr != nil {
+				assert.Equal(t, tt.outerr, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			bids, _ := bsvc.ListBidsByItemID(item.ID)
+			sort.Slice(bids, func(a, b int) bool { return bids[a].ID < bids[b].ID })
+
+			for n := range tt.outbids {
+				tt.outbids[n].ID = int64(n + 1)
+				tt.outbids[n].ItemID = item.ID
+				tt.outbids[n].Status = BidActive
+				tt.outbids[n].CreatedAt = testNow
+			}
+			assert.Equal(t, tt.outbids, bids)
+		})
+	}
+}
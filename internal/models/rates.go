@@ -0,0 +1,140 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+)
+
+// RatesProvider gives the exchange rates used to convert the bids placed in a currency other than
+// the one of their item.
+type RatesProvider interface {
+	// Rate returns the number of units of the currency to that one unit of the currency from is
+	// worth. It returns ErrNoRate if either currency is not known.
+	Rate(from, to string) (*big.Rat, error)
+}
+
+// FileRates is a RatesProvider reading the rates from a JSON file, which gives the value of one unit
+// of a base currency in every other currency:
+//
+//	{"base": "GBP", "rates": {"EUR": 1.17, "USD": "1.27"}}
+//
+// The file can be changed while the service runs, see Reload and ReloadIfChanged.
+type FileRates struct {
+	path string
+
+	mu      sync.RWMutex
+	rates   map[string]*big.Rat
+	modTime time.Time
+}
+
+// NewFileRates loads the rates of the file at path.
+func NewFileRates(path string) (*FileRates, error) {
+	fr := &FileRates{path: path}
+	if err := fr.Reload(); err != nil {
+		return nil, err
+	}
+
+	return fr, nil
+}
+
+// Reload reads the file again. The rates in use are left unchanged if it cannot be read or is not
+// valid.
+func (fr *FileRates) Reload() error {
+	info, err := os.Stat(fr.path)
+	if err != nil {
+		return err
+	}
+
+	raw, err := os.ReadFile(fr.path)
+	if err != nil {
+		return err
+	}
+
+	rates, err := parseRates(raw)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fr.path, err)
+	}
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.rates, fr.modTime = rates, info.ModTime()
+	return nil
+}
+
+// ReloadIfChanged reloads the file if it was modified since it was last loaded, and reports whether
+// it did.
+func (fr *FileRates) ReloadIfChanged() (bool, error) {
+	info, err := os.Stat(fr.path)
+	if err != nil {
+		return false, err
+	}
+
+	fr.mu.RLock()
+	changed := !info.ModTime().Equal(fr.modTime)
+	fr.mu.RUnlock()
+
+	if !changed {
+		return false, nil
+	}
+
+	return true, fr.Reload()
+}
+
+func (fr *FileRates) Rate(from, to string) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	rfrom, ok := fr.rates[from]
+	if !ok {
+		return nil, ErrNoRate
+	}
+	rto, ok := fr.rates[to]
+	if !ok {
+		return nil, ErrNoRate
+	}
+
+	return new(big.Rat).Quo(rto, rfrom), nil
+}
+
+// parseRates parses the contents of a rates file, returning the rates of every currency against the
+// base one, including the base one itself.
+func parseRates(raw []byte) (map[string]*big.Rat, error) {
+	var file struct {
+		Base  string                 `json:"base"`
+		Rates map[string]json.Number `json:"rates"`
+	}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, err
+	}
+
+	if _, ok := currencies[file.Base]; !ok {
+		return nil, fmt.Errorf("invalid base currency %q", file.Base)
+	}
+
+	rates := map[string]*big.Rat{file.Base: big.NewRat(1, 1)}
+	for currency, n := range file.Rates {
+		if _, ok := currencies[currency]; !ok {
+			return nil, fmt.Errorf("invalid currency %q", currency)
+		}
+
+		r, ok := new(big.Rat).SetString(n.String())
+		if !ok || r.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid rate %q for %s", n, currency)
+		}
+
+		if currency != file.Base {
+			rates[currency] = r
+		}
+	}
+
+	return rates, nil
+}
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeRates writes contents to the rates file at path, moving its modification time forward.
+func writeRates(t *testing.T, path string, contents string) {
+	t.Helper()
+
+	assert.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+
+	// the modification time of the file may not change between quick writes
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	mtime := info.ModTime().Add(time.Second)
+	assert.NoError(t, os.Chtimes(path, mtime, mtime))
+}
+
+func TestFileRates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	writeRates(t, path, `{"base": "GBP", "rates": {"EUR": 1.25, "USD": "1.6"}}`)
+
+	rates, err := NewFileRates(path)
+	assert.NoError(t, err)
+
+	var cases = []struct {
+		name    string
+		from    string
+		to      string
+		outrate *big.Rat
+		outerr  error
+	}{
+		{"from_base", "GBP", "EUR", big.NewRat(5, 4), nil},
+		{"to_base", "EUR", "GBP", big.NewRat(4, 5), nil},
+		{"cross", "EUR", "USD", big.NewRat(32, 25), nil},
+		{"same", "JPY", "JPY", big.NewRat(1, 1), nil},
+		{"unknown", "EUR", "JPY", nil, ErrNoRate},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, err := rates.Rate(tt.from, tt.to)
+
+			if tt.outerr != nil {
+				assert.Equal(t, tt.outerr, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.outrate.String(), rate.String())
+		})
+	}
+
+	reloaded, err := rates.ReloadIfChanged()
+	assert.NoError(t, err)
+	assert.False(t, reloaded)
+
+	// invalid files leave the rates in use unchanged
+	writeRates(t, path, `{"base": "GBP", "rates": {"EUR": -1}}`)
+	reloaded, err = rates.ReloadIfChanged()
+	assert.True(t, reloaded)
+	assert.Error(t, err)
+
+	rate, err := rates.Rate("GBP", "EUR")
+	assert.NoError(t, err)
+	assert.Equal(t, "5/4", rate.String())
+
+	writeRates(t, path, `{"base": "GBP", "rates": {"EUR": 1.5}}`)
+	reloaded, err = rates.ReloadIfChanged()
+	assert.True(t, reloaded)
+	assert.NoError(t, err)
+
+	rate, err = rates.Rate("GBP", "EUR")
+	assert.NoError(t, err)
+	assert.Equal(t, "3/2", rate.String())
+
+	_, err = rates.Rate("GBP", "USD")
+	assert.Equal(t, ErrNoRate, err)
+}
+
+func TestNewFileRates_Invalid(t *testing.T) {
+	var cases = []struct {
+		name     string
+		contents string
+	}{
+		{"malformed", `{"base": "GBP", "rates": `},
+		{"unknown_base", `{"base": "XYZ", "rates": {"EUR": 1.25}}`},
+		{"unknown_currency", `{"base": "GBP", "rates": {"XYZ": 1.25}}`},
+		{"zero_rate", `{"base": "GBP", "rates": {"EUR": 0}}`},
+		{"not_a_number", `{"base": "GBP", "rates": {"EUR": "a lot"}}`},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rates.json")
+			writeRates(t, path, tt.contents)
+
+			_, err := NewFileRates(path)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := NewFileRates(filepath.Join(t.TempDir(), "missing.json"))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestBidService_Rates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	writeRates(t, path, `{"base": "GBP", "rates": {"EUR": 1.25}}`)
+
+	rates, err := NewFileRates(path)
+	assert.NoError(t, err)
+
+	db := CreateDatabase()
+	db.SetClock(testClock())
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc, WithRates(rates))
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+
+	item := Item{Name: "portal gun", SellerID: testSeller(usvc), Value: gbp(1000)}
+	assert.NoError(t, isvc.TxCreate(&item))
+
+	eur := func(amount int64) Money { return Money{Amount: amount, Currency: "EUR"} }
+
+	// €15.01 is worth £12.008, rounded down
+	bid := Bid{UserID: 1, ItemID: item.ID, Amount: eur(1501)}
+	assert.NoError(t, bsvc.TxCreate(&bid))
+	assert.Equal(t, gbp(1200), bid.Amount)
+	assert.Equal(t, eur(1501), *bid.OriginalAmount)
+
+	// bids are compared to the winning bid once converted
+	err = bsvc.TxCreate(&Bid{UserID: 2, ItemID: item.ID, Amount: gbp(1200)})
+	assert.True(t, errors.Is(err, ValidationError{"bid": BidTooLowError{Minimum: gbp(1201)}}), "unexpected error %v", err)
+
+	// after the reload, €15.01 is worth £10.00 only
+	writeRates(t, path, `{"base": "GBP", "rates": {"EUR": 1.501}}`)
+	_, err = rates.ReloadIfChanged()
+	assert.NoError(t, err)
+
+	err = bsvc.TxCreate(&Bid{UserID: 2, ItemID: item.ID, Amount: eur(1501)})
+	assert.True(t, errors.Is(err, ValidationError{"bid": BidTooLowError{Minimum: gbp(1201)}}), "unexpected error %v", err)
+
+	bid = Bid{UserID: 2, ItemID: item.ID, Amount: eur(1804)}
+	assert.NoError(t, bsvc.TxCreate(&bid))
+	assert.Equal(t, gbp(1201), bid.Amount)
+
+	winning, err := bsvc.GetWinningBid(item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, bid, winning)
+
+	err = bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: Money{Amount: 200000, Currency: "JPY"}})
+	assert.True(t, errors.Is(err, ValidationError{"amount": ErrNoRate}), "unexpected error %v", err)
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// RetractionPolicy sets when users may retract their own bids: only within Window after placing
+// them, and never in the final Cutoff of the auction. A zero Window disables the retractions.
+type RetractionPolicy struct {
+	Window time.Duration
+	Cutoff time.Duration
+}
+
+// allows reports whether bid b on item i can be retracted at time now.
+func (rp RetractionPolicy) allows(b Bid, i Item, now time.Time) bool {
+	if rp.Window <= 0 || now.Sub(b.CreatedAt) > rp.Window {
+		return false
+	}
+	return now.Before(i.EndsAt.Add(-rp.Cutoff))
+}
+
+// TxRetract withdraws the bid identified by b.ID on behalf of its owner b.UserID, recording b.Reason.
+// Only manual bids can be retracted, according to the retraction policy of the service. The winning
+// bid of the item falls back to the next active bid, and the proxies answer to it. On success, b
+// holds the retracted bid.
+func (bs *bidValidator) TxRetract(b *Bid) error {
+	stored, err := bs.BidDB.Get(b.ID)
+	if err != nil || stored.UserID != b.UserID {
+		return ValidationError{"bid": ErrNotFound}
+	}
+
+	if b.Reason == "" {
+		return ValidationError{"reason": ErrRequired}
+	}
+
+	if stored.Kind != BidManual {
+		return ValidationError{"bid": ErrUnsupported}
+	}
+
+	// the item is locked, so the item cannot close while the bid is being retracted
+	return bs.txBidding(stored.ItemID, func(i *Item) error {
+		now := bs.clock.Now()
+
+		if i.StateAt(now) != ItemOpen {
+			return ValidationError{"item": ErrNotOpen}
+		}
+
+		if !bs.retraction.allows(stored, *i, now) {
+			return ValidationError{"bid": ErrNoRetract}
+		}
+
+		if err := bs.setStatus(b, BidRetracted); err != nil {
+			return err
+		}
+
+		return bs.bidWithdrawn(i)
+	})
+}
+
+// TxVoid cancels the bid identified by b.ID as an administrator, recording b.Reason. Bids can be
+// voided until the item gets an order: if the item is closed already, its result is worked out again
+// among the remaining active bids. On success, b holds the voided bid.
+func (bs *bidValidator) TxVoid(b *Bid) error {
+	stored, err := bs.BidDB.Get(b.ID)
+	if err != nil {
+		return ValidationError{"bid": ErrNotFound}
+	}
+
+	if b.Reason == "" {
+		return ValidationError{"reason": ErrRequired}
+	}
+
+	return bs.txBidding(stored.ItemID, func(i *Item) error {
+		// the order of the item is issued to its winners, which cannot change anymore
+		_, err := bs.orders.GetByItemID(i.ID)
+		if err == nil || i.State == ItemSettled {
+			return ValidationError{"item": ErrConflict}
+		}
+		if err != ErrNotFound {
+			return err
+		}
+
+		if err := bs.setStatus(b, BidVoided); err != nil {
+			return err
+		}
+
+		// nobody wins a cancelled auction, whatever its bids
+		if i.closed() && i.State != ItemCancelled {
+			bids, err := bs.BidDB.ListBidsByItemID(i.ID)
+			if err != nil && err != ErrNotFound {
+				return err
+			}
+
+			freezeResult(i, bids)
+		}
+
+		return bs.bidWithdrawn(i)
+	})
+}
+
+// bidWithdrawn applies the consequences of a bid retracted or voided on item i, which must be locked:
+// while the item is open, the proxies answer to the new winning bid. The users leading the bidding
+// now get their funds held again, and the funds of the users who do not lead it anymore are released.
+func (bs *bidValidator) bidWithdrawn(i *Item) error {
+	if i.StateAt(bs.clock.Now()) == ItemOpen {
+		if err := bs.resolveProxies(*i); err != nil {
+			return err
+		}
+	}
+
+	if err := bs.holdLeaders(*i); err != nil {
+		return err
+	}
+	return bs.releaseHolds(*i)
+}
+
+// holdLeaders holds the funds for the best bids of the users leading the bidding of item i, which
+// must be locked, who have no hold on it, as theirs was released when they were outbid. Users who
+// spent their funds in the meantime keep their bids, and pay on settlement like any other buyer.
+func (bs *bidValidator) holdLeaders(i Item) error {
+	if !bs.holds || i.Direction == AuctionReverse {
+		return nil
+	}
+
+	bids, err := bs.BidDB.ListBidsByItemID(i.ID)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+
+	holds, err := bs.wallets.ListHoldsByItemID(i.ID)
+	if err != nil {
+		return err
+	}
+	held := map[int64]bool{}
+	for _, h := range holds {
+		held[h.UserID] = true
+	}
+
+	best := map[int64]Money{}
+	for _, b := range activeBids(bids) {
+		total, err := b.Amount.Times(int64(units(b.Quantity)))
+		if err != nil {
+			return err
+		}
+		if total.Amount > best[b.UserID].Amount {
+			best[b.UserID] = total
+		}
+	}
+
+	for userID := range leaders(i, bids) {
+		if held[userID] {
+			continue
+		}
+
+		err := bs.hold(i, userID, best[userID], false)
+		if err != nil && !errors.Is(err, ValidationError{"amount": ErrNoFunds}) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setStatus changes the status of the active bid identified by b.ID, recording b.Reason, and fills b
+// with the updated bid. It must be called while the item of the bid is locked.
+func (bs *bidValidator) setStatus(b *Bid, status BidStatus) error {
+	reason := b.Reason
+
+	return bs.BidDB.TxUpdate(b.ID, func(v *Bid) error {
+		if !v.active() {
+			return ValidationError{"bid": ErrConflict}
+		}
+
+		v.Status, v.Reason = status, reason
+		*b = *v
+		return nil
+	})
+}
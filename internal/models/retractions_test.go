@@ -0,0 +1,207 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBidService_TxRetract(t *testing.T) {
+	policy := RetractionPolicy{Window: 10 * time.Minute, Cutoff: time.Hour}
+
+	var cases = []struct {
+		name      string
+		policy    RetractionPolicy
+		endsIn    time.Duration // time left in the auction when the bid is placed
+		elapsed   time.Duration // between the bid and its retraction
+		userID    int64
+		reason    string
+		outerr    error
+		outwinner int64
+	}{
+		{"ok", policy, 2 * time.Hour, 5 * time.Minute, 2, "fat finger", nil, 1},
+		{"disabled", RetractionPolicy{}, 2 * time.Hour, 0, 2, "fat finger", ValidationError{"bid": ErrNoRetract}, 2},
+		{"window_passed", policy, 2 * time.Hour, 11 * time.Minute, 2, "fat finger", ValidationError{"bid": ErrNoRetract}, 2},
+		{"final_hour", policy, time.Hour + 5*time.Minute, 9 * time.Minute, 2, "fat finger", ValidationError{"bid": ErrNoRetract}, 2},
+		{"not_owner", policy, 2 * time.Hour, 0, 1, "fat finger", ValidationError{"bid": ErrNotFound}, 2},
+		{"reason_required", policy, 2 * time.Hour, 0, 2, "", ValidationError{"reason": ErrRequired}, 2},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			now := testNow
+			db := CreateDatabase()
+			db.SetClock(ClockFunc(func() time.Time { return now }))
+
+			usvc := NewUserService(db)
+			isvc := NewItemService(db, usvc)
+			bsvc := NewBidService(db, isvc, usvc, WithRetraction(tt.policy))
+
+			usvc.TxCreate(&User{Name: "Morty"})
+			usvc.TxCreate(&User{Name: "Rick"})
+
+			item := Item{Name: "portal gun", SellerID: testSeller(usvc), Value: gbp(10), EndsAt: now.Add(tt.endsIn)}
+			assert.NoError(t, isvc.TxCreate(&item))
+
+			assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: gbp(100)}))
+			bid := Bid{UserID: 2, ItemID: item.ID, Amount: gbp(10000)}
+			assert.NoError(t, bsvc.TxCreate(&bid))
+
+			now = now.Add(tt.elapsed)
+
+			retracted := Bid{ID: bid.ID, UserID: tt.userID, Reason: tt.reason}
+			err := bsvc.TxRetract(&retracted)
+
+			if tt.outerr != nil {
+				assert.Error(t, err)
+				assert.True(t, errors.Is(err, tt.outerr), "errors must match, expected %v, got %v", tt.outerr, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, BidRetracted, retracted.Status)
+				assert.Equal(t, tt.reason, retracted.Reason)
+				assert.Equal(t, bid.Amount, retracted.Amount)
+			}
+
+			winning, err := bsvc.GetWinningBid(item.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.outwinner, winning.UserID)
+
+			// the retracted bid stays in the history
+			bids, err := bsvc.ListBidsByItemID(item.ID)
+			assert.NoError(t, err)
+			assert.Len(t, bids, 2)
+		})
+	}
+}
+
+func TestBidService_TxVoid(t *testing.T) {
+	now := testNow
+	db := CreateDatabase()
+	db.SetClock(ClockFunc(func() time.Time { return now }))
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc)
+	asvc := NewAuctionService(db)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+
+	item := Item{Name: "portal gun", SellerID: testSeller(usvc), Value: gbp(10), EndsAt: now.Add(time.Hour)}
+	assert.NoError(t, isvc.TxCreate(&item))
+
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: gbp(20)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: item.ID, Amount: gbp(30)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: gbp(40)}))
+
+	// voiding an open item's bid makes the winner fall back to the next bid
+	voided := Bid{ID: 3, Reason: "payment fraud"}
+	assert.NoError(t, bsvc.TxVoid(&voided))
+	assert.Equal(t, BidVoided, voided.Status)
+
+	winning, err := bsvc.GetWinningBid(item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), winning.ID)
+
+	// a new bid only has to beat the active bids
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: gbp(31)}))
+
+	now = item.EndsAt
+	_, err = asvc.Advance()
+	assert.NoError(t, err)
+
+	// voiding the winning bid of a closed item works its result out again
+	assert.NoError(t, bsvc.TxVoid(&Bid{ID: 4, Reason: "shill bidding"}))
+
+	item, _ = isvc.Get(item.ID)
+	assert.Equal(t, int64(2), item.WinningBidID)
+	assert.Equal(t, gbpPtr(30), item.ClearingPrice)
+
+	winning, err = bsvc.GetWinningBid(item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), winning.ID)
+
+	err = bsvc.TxVoid(&Bid{ID: 4, Reason: "twice"})
+	assert.True(t, errors.Is(err, ValidationError{"bid": ErrConflict}), "unexpected error %v", err)
+
+	err = bsvc.TxVoid(&Bid{ID: 2})
+	assert.True(t, errors.Is(err, ValidationError{"reason": ErrRequired}), "unexpected error %v", err)
+
+	// the winners cannot change anymore once the item has an order
+	assert.NoError(t, db.orders.TxCreate(&Order{ItemID: item.ID}))
+	err = bsvc.TxVoid(&Bid{ID: 2, Reason: "shill bidding"})
+	assert.True(t, errors.Is(err, ValidationError{"item": ErrConflict}), "unexpected error %v", err)
+
+	winning, err = bsvc.GetWinningBid(item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), winning.ID)
+}
+
+func TestBidService_TxVoid_Leaders(t *testing.T) {
+	db := CreateDatabase()
+	db.SetClock(testClock())
+	tp := &testPublisher{}
+	db.SetPublisher(tp)
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc, WithHolds())
+	wsvc := NewWalletService(db, usvc)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+	usvc.TxCreate(&User{Name: "Summer"})
+	for userID := int64(1); userID <= 3; userID++ {
+		assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: userID, Amount: gbp(1000)}))
+	}
+
+	item := Item{Name: "portal gun", SellerID: testSeller(usvc), Value: gbp(10)}
+	assert.NoError(t, isvc.TxCreate(&item))
+
+	available := func(userID int64) int64 {
+		w, err := wsvc.Get(userID)
+		assert.NoError(t, err)
+		return w.Balances[0].Available.Amount
+	}
+
+	assert.NoError(t, bsvc.TxCreateProxy(&ProxyBid{UserID: 1, ItemID: item.ID, MaxAmount: gbp(50)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: item.ID, Amount: gbp(30)}))
+
+	proxied, err := bsvc.GetWinningBid(item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), proxied.UserID)
+	tp.take()
+
+	// the proxy answers again once its bid is voided
+	assert.NoError(t, bsvc.TxVoid(&Bid{ID: proxied.ID, Reason: "glitch"}))
+
+	winning, err := bsvc.GetWinningBid(item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), winning.UserID)
+	assert.NotEqual(t, proxied.ID, winning.ID)
+	assert.Equal(t, proxied.Amount, winning.Amount)
+	assert.Empty(t, tp.take())
+
+	// Summer outbids the proxy, releasing the funds of Morty
+	summer := Bid{UserID: 3, ItemID: item.ID, Amount: gbp(60)}
+	assert.NoError(t, bsvc.TxCreate(&summer))
+	assert.Equal(t, int64(1000), available(1))
+	assert.Equal(t, int64(940), available(3))
+	tp.take()
+
+	// voiding the bid of Summer makes Morty lead again, holding their funds, and tells Summer
+	assert.NoError(t, bsvc.TxVoid(&Bid{ID: summer.ID, Reason: "stolen card"}))
+
+	winning, err = bsvc.GetWinningBid(item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), winning.UserID)
+	assert.Equal(t, int64(950), available(1))
+	assert.Equal(t, int64(1000), available(3))
+
+	events := tp.take()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, EventOutbid, events[0].Type)
+		assert.Equal(t, int64(3), events[0].UserID)
+	}
+}
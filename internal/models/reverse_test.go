@@ -0,0 +1,168 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBidService_Reverse(t *testing.T) {
+	var cases = []struct {
+		name      string
+		auction   AuctionType
+		reserve   int64
+		bids      []Bid
+		outerr    error
+		outwinner int64
+		outprice  int64
+		outsold   bool
+	}{
+		{
+			"lowest_bid_wins",
+			AuctionEnglish,
+			0,
+			[]Bid{{UserID: 1, Amount: gbp(90)}, {UserID: 2, Amount: gbp(80)}, {UserID: 3, Amount: gbp(70)}},
+			nil,
+			3,
+			70,
+			true,
+		},
+		{
+			"above_initial_value",
+			AuctionEnglish,
+			0,
+			[]Bid{{UserID: 1, Amount: gbp(100)}},
+			ValidationError{"item": ErrHighValue},
+			0,
+			0,
+			false,
+		},
+		{
+			"does_not_undercut_winning_bid",
+			AuctionEnglish,
+			0,
+			[]Bid{{UserID: 1, Amount: gbp(90)}, {UserID: 2, Amount: gbp(90)}},
+			ValidationError{"bid": BidTooHighError{Maximum: gbp(89)}},
+			1,
+			90,
+			true,
+		},
+		{
+			"reserve_not_met",
+			AuctionEnglish,
+			50,
+			[]Bid{{UserID: 1, Amount: gbp(90)}, {UserID: 2, Amount: gbp(60)}},
+			nil,
+			2,
+			60,
+			false,
+		},
+		{
+			"sealed_first_price",
+			AuctionSealedFirstPrice,
+			0,
+			[]Bid{{UserID: 1, Amount: gbp(50)}, {UserID: 2, Amount: gbp(80)}, {UserID: 3, Amount: gbp(60)}},
+			nil,
+			1,
+			50,
+			true,
+		},
+		{
+			"vickrey",
+			AuctionVickrey,
+			0,
+			[]Bid{{UserID: 1, Amount: gbp(50)}, {UserID: 2, Amount: gbp(80)}, {UserID: 3, Amount: gbp(60)}},
+			nil,
+			1,
+			60,
+			true,
+		},
+		{
+			"vickrey_single_bid_gets_reserve",
+			AuctionVickrey,
+			70,
+			[]Bid{{UserID: 1, Amount: gbp(50)}},
+			nil,
+			1,
+			70,
+			true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			now := testNow
+			db := CreateDatabase()
+			db.SetClock(ClockFunc(func() time.Time { return now }))
+
+			usvc := NewUserService(db)
+			isvc := NewItemService(db, usvc)
+			bsvc := NewBidService(db, isvc, usvc)
+			asvc := NewAuctionService(db)
+
+			usvc.TxCreate(&User{Name: "Morty"})
+			usvc.TxCreate(&User{Name: "Rick"})
+			usvc.TxCreate(&User{Name: "Summer"})
+
+			item := Item{
+				Name:         "plumbing repair",
+				SellerID:     testSeller(usvc),
+				Value:        gbp(100),
+				ReservePrice: gbpPtr(tt.reserve),
+				Type:         tt.auction,
+				Direction:    AuctionReverse,
+				EndsAt:       now.Add(time.Hour),
+			}
+			assert.NoError(t, isvc.TxCreate(&item))
+
+			var err error
+			for _, b := range tt.bids {
+				b.ItemID = item.ID
+				if err = bsvc.TxCreate(&b); err != nil {
+					break
+				}
+			}
+
+			if tt.outerr != nil {
+				assert.Error(t, err)
+				assert.True(t, errors.Is(err, tt.outerr), "errors must match, expected %v, got %v", tt.outerr, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			now = item.EndsAt
+			_, err = asvc.Advance()
+			assert.NoError(t, err)
+
+			item, _ = isvc.Get(item.ID)
+			assert.Equal(t, gbpPtr(tt.outprice), item.ClearingPrice)
+			assert.Equal(t, tt.outsold, item.Sold)
+
+			winning, err := bsvc.GetWinningBid(item.ID)
+			if tt.outwinner == 0 {
+				assert.Equal(t, ErrNotFound, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.outwinner, winning.UserID)
+		})
+	}
+}
+
+func TestBidService_Reverse_Unsupported(t *testing.T) {
+	db := CreateDatabase()
+	db.SetClock(testClock())
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+
+	item := Item{Name: "plumbing repair", SellerID: testSeller(usvc), Value: gbp(100), Direction: AuctionReverse}
+	assert.NoError(t, isvc.TxCreate(&item))
+
+	err := bsvc.TxCreateProxy(&ProxyBid{UserID: 1, ItemID: item.ID, MaxAmount: gbp(50)})
+	assert.True(t, errors.Is(err, ValidationError{"item": ErrUnsupported}), "unexpected error %v", err)
+}
@@ -0,0 +1,312 @@
+package models
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxDescription is the length of the longest item description, in bytes.
+	MaxDescription = 5000
+
+	maxSearchTerms = 10
+
+	// nameWeight is how much more a word of the name of an item counts than a word of its description.
+	nameWeight = 3
+	// prefixWeight is how much a word matched by a prefix counts, relative to a whole word.
+	prefixWeight = 0.5
+
+	// snippetLength is the length of the snippets of the descriptions, in bytes, and snippetLead the
+	// length of the text kept before the first word matched.
+	snippetLength = 160
+	snippetLead   = 40
+
+	highlightStart = "<mark>"
+	highlightEnd   = "</mark>"
+)
+
+// SearchResult is an item matching a search. Name and Snippet are HTML, with the words matched
+// highlighted by <mark> tags: Name is the name of the item, and Snippet an excerpt of its description
+// around the first word matched.
+type SearchResult struct {
+	Item    Item    `json:"item"`
+	Score   float64 `json:"score"`
+	Name    string  `json:"name"`
+	Snippet string  `json:"snippet,omitempty"`
+}
+
+// token is a word of a text, between the byte offsets start and end. word is in lower case.
+type token struct {
+	word       string
+	start, end int
+}
+
+// tokenize splits s into words, made of letters and digits.
+func tokenize(s string) []token {
+	var tokens []token
+	start := -1
+	for n, r := range s {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case inWord && start < 0:
+			start = n
+		case !inWord && start >= 0:
+			tokens = append(tokens, token{word: strings.ToLower(s[start:n]), start: start, end: n})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{word: strings.ToLower(s[start:]), start: start, end: len(s)})
+	}
+	return tokens
+}
+
+// searchTerms returns the words of query, without duplicates.
+func searchTerms(query string) []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, t := range tokenize(query) {
+		if !seen[t.word] {
+			seen[t.word] = true
+			terms = append(terms, t.word)
+		}
+	}
+	return terms
+}
+
+// termCount is the number of times a word appears in the name and in the description of an item.
+type termCount struct {
+	name        int
+	description int
+}
+
+// searchIndex is an inverted index of the words of the names and descriptions of the items. words
+// holds every word indexed, sorted, so the words starting with a prefix are next to each other.
+type searchIndex struct {
+	postings map[string]map[int64]termCount
+	words    []string
+	docs     int
+}
+
+// add indexes the name and description of i.
+func (si *searchIndex) add(i Item) {
+	counts := itemTermCounts(i)
+	if len(counts) == 0 {
+		return
+	}
+
+	if si.postings == nil {
+		si.postings = make(map[string]map[int64]termCount)
+	}
+
+	si.docs++
+	for w, c := range counts {
+		if si.postings[w] == nil {
+			si.postings[w] = make(map[int64]termCount)
+
+			n := sort.SearchStrings(si.words, w)
+			si.words = append(si.words, "")
+			copy(si.words[n+1:], si.words[n:])
+			si.words[n] = w
+		}
+		si.postings[w][i.ID] = c
+	}
+}
+
+// remove removes i, as it was indexed, from the index.
+func (si *searchIndex) remove(i Item) {
+	counts := itemTermCounts(i)
+	if len(counts) == 0 {
+		return
+	}
+
+	si.docs--
+	for w := range counts {
+		delete(si.postings[w], i.ID)
+		if len(si.postings[w]) > 0 {
+			continue
+		}
+
+		delete(si.postings, w)
+		n := sort.SearchStrings(si.words, w)
+		si.words = append(si.words[:n], si.words[n+1:]...)
+	}
+}
+
+func itemTermCounts(i Item) map[string]termCount {
+	counts := make(map[string]termCount)
+	for _, t := range tokenize(i.Name) {
+		c := counts[t.word]
+		c.name++
+		counts[t.word] = c
+	}
+	for _, t := range tokenize(i.Description) {
+		c := counts[t.word]
+		c.description++
+		counts[t.word] = c
+	}
+	return counts
+}
+
+// search returns the score of the items matching every term, whether as a whole word or as the
+// prefix of a word. Words count more in names than in descriptions, and the rarer they are the more
+// they count.
+func (si *searchIndex) search(terms []string) map[int64]float64 {
+	var scores map[int64]float64
+	for _, term := range terms {
+		matched := make(map[int64]float64)
+		for n := sort.SearchStrings(si.words, term); n < len(si.words) && strings.HasPrefix(si.words[n], term); n++ {
+			w := si.words[n]
+
+			weight := math.Log(1 + float64(si.docs)/float64(len(si.postings[w])))
+			if w != term {
+				weight *= prefixWeight
+			}
+
+			for id, c := range si.postings[w] {
+				matched[id] += weight * float64(nameWeight*c.name+c.description)
+			}
+		}
+
+		// items have to match every term
+		if scores != nil {
+			for id, s := range matched {
+				if _, ok := scores[id]; ok {
+					matched[id] = s + scores[id]
+				} else {
+					delete(matched, id)
+				}
+			}
+		}
+		scores = matched
+	}
+
+	return scores
+}
+
+// Search returns the items matching query, the most relevant first. Every word of the query has to
+// match a word of the name or of the description of the item, a word matching the words it starts.
+func (iv *itemValidator) Search(query string) ([]SearchResult, error) {
+	terms := searchTerms(query)
+
+	switch {
+	case strings.TrimSpace(query) == "":
+		return nil, ValidationError{"q": ErrRequired}
+	case len(terms) == 0 || len(terms) > maxSearchTerms:
+		return nil, ValidationError{"q": ErrInvalid}
+	}
+
+	results := iv.ItemDB.SearchItems(terms)
+
+	for n, r := range results {
+		results[n].Name = highlight(r.Item.Name, terms)
+		results[n].Snippet = snippet(r.Item.Description, terms)
+	}
+
+	return results, nil
+}
+
+// matches reports whether word starts with any of the terms.
+func matches(word string, terms []string) bool {
+	for _, t := range terms {
+		if strings.HasPrefix(word, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// highlight escapes s as HTML, highlighting the words matching any of the terms.
+func highlight(s string, terms []string) string {
+	var b strings.Builder
+	last := 0
+	for _, t := range tokenize(s) {
+		if !matches(t.word, terms) {
+			continue
+		}
+
+		b.WriteString(html.EscapeString(s[last:t.start]))
+		b.WriteString(highlightStart)
+		b.WriteString(html.EscapeString(s[t.start:t.end]))
+		b.WriteString(highlightEnd)
+		last = t.end
+	}
+	b.WriteString(html.EscapeString(s[last:]))
+
+	return b.String()
+}
+
+// snippet returns an excerpt of the description s around the first word matching any of the terms,
+// or its beginning if none does, highlighted. Excerpts are cut between words, and marked with an
+// ellipsis where they are cut.
+func snippet(s string, terms []string) string {
+	if len(s) <= snippetLength {
+		return highlight(s, terms)
+	}
+
+	tokens := tokenize(s)
+
+	start := 0
+	for _, t := range tokens {
+		if matches(t.word, terms) {
+			start = t.start
+			break
+		}
+	}
+
+	// the excerpt starts at the first word within the lead, and ends after the last word that fits
+	if start > snippetLead {
+		lead := start - snippetLead
+		for _, t := range tokens {
+			if t.start >= lead {
+				start = t.start
+				break
+			}
+		}
+	} else {
+		start = 0
+	}
+
+	end := start + snippetLength
+	if end >= len(s) {
+		end = len(s)
+	} else {
+		cut := start
+		for _, t := range tokens {
+			if t.start >= start && t.end <= end {
+				cut = t.end
+			}
+		}
+		if cut > start {
+			end = cut
+		} else {
+			// a single word longer than the excerpt is cut where a rune starts
+			for !utf8.RuneStart(s[end]) {
+				end--
+			}
+		}
+	}
+
+	ret := highlight(s[start:end], terms)
+	if start > 0 {
+		ret = "…" + ret
+	}
+	if end < len(s) {
+		ret += "…"
+	}
+	return ret
+}
+
+func (iv *itemValidator) validDescription() (string, itemValFn) {
+	return "description", func(i *Item) error {
+		i.Description = strings.TrimSpace(i.Description)
+		if len(i.Description) > MaxDescription {
+			return ErrInvalid
+		}
+		return nil
+	}
+}
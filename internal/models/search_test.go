@@ -0,0 +1,118 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []token{
+		{"vintage", 0, 7},
+		{"téléphone", 8, 19},
+		{"1970s", 22, 27},
+	}, tokenize("Vintage Téléphone, (1970s)"))
+	assert.Nil(t, tokenize(" -- "))
+}
+
+func TestItemService_Search(t *testing.T) {
+	db := CreateDatabase()
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	seller := testSeller(usvc)
+
+	items := []Item{
+		{Name: "Vintage phone", Description: "A rotary phone from the seventies, in working order."},
+		{Name: "Radio", Description: "Vintage valve radio. Phones not included."},
+		{Name: "Phone charger"},
+		{Name: "Phonograph", Description: "<b>Loud</b> & clear"},
+	}
+	for n := range items {
+		items[n].SellerID = seller
+		items[n].Value = gbp(10)
+		assert.NoError(t, isvc.TxCreate(&items[n]))
+	}
+
+	var cases = []struct {
+		name       string
+		query      string
+		outids     []int64
+		outnames   []string
+		outsnippet string
+		outerr     error
+	}{
+		// names count more than descriptions, and whole words more than prefixes
+		{"word", "phone", []int64{1, 3, 2}, []string{"Vintage <mark>phone</mark>", "<mark>Phone</mark> charger", "Radio"}, "A rotary <mark>phone</mark> from the seventies, in working order.", nil},
+		{"every word", "VINTAGE phone", []int64{1, 2}, []string{"<mark>Vintage</mark> <mark>phone</mark>", "Radio"}, "A rotary <mark>phone</mark> from the seventies, in working order.", nil},
+		{"prefix", "phonog", []int64{4}, []string{"<mark>Phonograph</mark>"}, "&lt;b&gt;Loud&lt;/b&gt; &amp; clear", nil},
+		{"escaped", "loud", []int64{4}, []string{"Phonograph"}, "&lt;b&gt;<mark>Loud</mark>&lt;/b&gt; &amp; clear", nil},
+		{"no match", "gramophone", nil, nil, "", nil},
+		{"empty", "  ", nil, nil, "", ValidationError{"q": ErrRequired}},
+		{"no words", "?!", nil, nil, "", ValidationError{"q": ErrInvalid}},
+		{"too many words", "a b c d e f g h i j k", nil, nil, "", ValidationError{"q": ErrInvalid}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := isvc.Search(tt.query)
+			assert.Equal(t, tt.outerr, err)
+
+			var ids []int64
+			var names []string
+			for _, r := range results {
+				ids = append(ids, r.Item.ID)
+				names = append(names, r.Name)
+			}
+			assert.Equal(t, tt.outids, ids)
+			assert.Equal(t, tt.outnames, names)
+			if len(results) > 0 {
+				assert.Equal(t, tt.outsnippet, results[0].Snippet)
+			}
+		})
+	}
+
+	// the index follows the updates of the items
+	assert.NoError(t, isvc.TxUpdate(items[2].ID, func(i *Item) error {
+		i.Name = "Gramophone"
+		return nil
+	}))
+
+	results, err := isvc.Search("phone")
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	results, err = isvc.Search("gramophone")
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, items[2].ID, results[0].Item.ID)
+	}
+
+	results, err = isvc.Search("charger")
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("lorem ipsum ", 20) + "rotary phone " + strings.Repeat("dolor sit ", 20)
+
+	var cases = []struct {
+		name  string
+		s     string
+		terms []string
+		out   string
+	}{
+		{"short", "a rotary phone", []string{"phone"}, "a rotary <mark>phone</mark>"},
+		{"no match", long, []string{"radio"}, strings.TrimSpace(strings.Repeat("lorem ipsum ", 13)) + "…"},
+		{"around the match", long, []string{"rotary"}, "…lorem ipsum lorem ipsum lorem ipsum <mark>rotary</mark> phone" + strings.TrimSuffix(" "+strings.Repeat("dolor sit ", 11), " ") + "…"},
+		{"long word", strings.Repeat("é", 100), []string{"x"}, strings.Repeat("é", 80) + "…"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := snippet(tt.s, tt.terms)
+			assert.Equal(t, tt.out, got)
+			assert.LessOrEqual(t, len(strings.TrimSuffix(strings.TrimPrefix(strings.NewReplacer("<mark>", "", "</mark>", "").Replace(got), "…"), "…")), snippetLength)
+		})
+	}
+}
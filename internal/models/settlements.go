@@ -0,0 +1,413 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Order is the sale of a closed item, issued when the item is settled. It has a line for every
+// winning bid: a single one, unless the item is a lot of several units shared among several winners.
+type Order struct {
+	ID        int64       `json:"id"`
+	ItemID    int64       `json:"itemId"`
+	Lines     []OrderLine `json:"lines"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// OrderLine is what a buyer pays and a seller gets for the units won by a bid. The buyer is the
+// bidder, except on reverse auctions, where the bidder is paid by the owner of the item.
+type OrderLine struct {
+	BidID    int64 `json:"bidId"`
+	BuyerID  int64 `json:"buyerId"`
+	SellerID int64 `json:"sellerId"`
+	Quantity int   `json:"quantity"`
+
+	// Price is the hammer price of the units, before any fee.
+	Price            Money `json:"price"`
+	BuyerPremium     Money `json:"buyerPremium"`
+	SellerCommission Money `json:"sellerCommission"`
+	BuyerTotal       Money `json:"buyerTotal"`
+	SellerPayout     Money `json:"sellerPayout"`
+
+	// Paid is set once the buyer total has been taken from the wallet of the buyer and the payout
+	// added to the wallet of the seller.
+	Paid bool `json:"paid"`
+
+	// DueAt is the deadline for the buyer to pay, after which the line is Defaulted and its units
+	// offered to the next bidder. A zero DueAt means there is no deadline.
+	DueAt     time.Time `json:"dueAt"`
+	Defaulted bool      `json:"defaulted,omitempty"`
+}
+
+// due reports whether the payment of l is still expected.
+func (l OrderLine) due() bool {
+	return !l.Paid && !l.Defaulted
+}
+
+// paymentRefs returns the references identifying the charge of the buyer and the payout of the seller
+// of the line of order o, which make the payments idempotent.
+func (l OrderLine) paymentRefs(o Order) (string, string) {
+	prefix := fmt.Sprintf("order:%d:bid:%d", o.ID, l.BidID)
+	return prefix + ":charge", prefix + ":payout"
+}
+
+// InvoiceRole tells which side of an order line an invoice is addressed to.
+type InvoiceRole string
+
+const (
+	InvoiceBuyer  InvoiceRole = "buyer"
+	InvoiceSeller InvoiceRole = "seller"
+)
+
+// Invoice is the statement of an order line for one of its sides: what the buyer owes, or what the
+// seller is owed.
+type Invoice struct {
+	OrderID  int64         `json:"orderId"`
+	ItemID   int64         `json:"itemId"`
+	BidID    int64         `json:"bidId"`
+	UserID   int64         `json:"userId"`
+	Role     InvoiceRole   `json:"role"`
+	Lines    []InvoiceLine `json:"lines"`
+	Total    Money         `json:"total"`
+	Paid     bool          `json:"paid"`
+	IssuedAt time.Time     `json:"issuedAt"`
+}
+
+// InvoiceLine is an amount added to, or taken from when negative, the total of an invoice.
+type InvoiceLine struct {
+	Description string `json:"description"`
+	Amount      Money  `json:"amount"`
+}
+
+// invoices returns the invoices of the lines of o addressed to the user identified by userID.
+func invoices(o Order, userID int64) []Invoice {
+	var invs []Invoice
+
+	for _, l := range o.Lines {
+		inv := Invoice{OrderID: o.ID, ItemID: o.ItemID, BidID: l.BidID, UserID: userID, Paid: l.Paid, IssuedAt: o.CreatedAt}
+
+		if l.BuyerID == userID {
+			inv.Role, inv.Total = InvoiceBuyer, l.BuyerTotal
+			inv.Lines = []InvoiceLine{
+				{Description: "Hammer price", Amount: l.Price},
+				{Description: "Buyer's premium", Amount: l.BuyerPremium},
+			}
+			invs = append(invs, inv)
+		}
+
+		if l.SellerID == userID {
+			commission := l.SellerCommission
+			commission.Amount = -commission.Amount
+
+			inv.Role, inv.Total = InvoiceSeller, l.SellerPayout
+			inv.Lines = []InvoiceLine{
+				{Description: "Hammer price", Amount: l.Price},
+				{Description: "Seller commission", Amount: commission},
+			}
+			invs = append(invs, inv)
+		}
+	}
+
+	return invs
+}
+
+type OrderDB interface {
+	// TxCreate stores o, unless its item already has an order, in which case o is set to the stored
+	// one. An item gets a single order however many times it is settled.
+	TxCreate(o *Order) error
+	TxUpdate(int64, func(*Order) error) error
+	GetByItemID(int64) (Order, error)
+	ListOrdersByUserID(int64) ([]Order, error)
+
+	// ListUnpaidOrders lists the orders with at least a line whose payment is still due.
+	ListUnpaidOrders() ([]Order, error)
+}
+
+// SettlementService completes the sales of the closed items: it issues their orders, computing the
+// fees from the configured schedules, and collects the payments from the wallets of the users.
+type SettlementService interface {
+	// Settle issues the order of every item that closed with a sale, moving the item to settled, and
+	// collects the payments that are still due. Buyers who miss their payment deadline get a strike,
+	// and their units are offered to the next bidder, see Offer. It returns the items it settled.
+	// Running it again, for instance after a crash, never issues an order or takes a payment twice.
+	Settle() ([]Item, error)
+
+	// Outstanding reports whether some orders are still waiting for the buyer to have the funds, or
+	// some offers for an answer, so callers know Settle has to be run again later.
+	Outstanding() bool
+
+	GetOrderByItemID(int64) (Order, error)
+
+	// ListInvoicesByUserID lists the invoices of the user, both as a buyer and as a seller.
+	ListInvoicesByUserID(int64) ([]Invoice, error)
+
+	ListOffersByUserID(int64) ([]Offer, error)
+
+	// TxAcceptOffer accepts the offer identified by o.ID on behalf of o.UserID, adding its units to
+	// the order of the item, to be paid by the user.
+	TxAcceptOffer(o *Offer) error
+
+	// TxDeclineOffer declines the offer identified by o.ID on behalf of o.UserID, so it is made to
+	// the next bidder.
+	TxDeclineOffer(o *Offer) error
+}
+
+// SettlementOption configures the settlement service built by NewSettlementService.
+type SettlementOption func(*settlementService)
+
+// WithBuyerPremium sets the fee buyers pay on top of the hammer price.
+func WithBuyerPremium(fs FeeSchedule) SettlementOption {
+	return func(ss *settlementService) {
+		ss.premium = fs
+	}
+}
+
+// WithSellerCommission sets the fee taken from the hammer price before paying the sellers.
+func WithSellerCommission(fs FeeSchedule) SettlementOption {
+	return func(ss *settlementService) {
+		ss.commission = fs
+	}
+}
+
+// WithPaymentDeadline sets the time buyers have to pay once their order is issued. Without it, buyers
+// are waited for indefinitely.
+func WithPaymentDeadline(d time.Duration) SettlementOption {
+	return func(ss *settlementService) {
+		ss.paymentDeadline = d
+	}
+}
+
+// WithOfferWindow sets the time a second-chance offer stays open. Without it, no offers are made when
+// a buyer misses their payment deadline.
+func WithOfferWindow(d time.Duration) SettlementOption {
+	return func(ss *settlementService) {
+		ss.offerWindow = d
+	}
+}
+
+type settlementService struct {
+	items       *ItemStorage
+	bids        *BidStorage
+	orders      OrderDB
+	offers      OfferDB
+	wallets     WalletDB
+	userService UserService
+	clock       Clock
+
+	premium         FeeSchedule
+	commission      FeeSchedule
+	paymentDeadline time.Duration
+	offerWindow     time.Duration
+}
+
+func NewSettlementService(db *DB, usvc UserService, opts ...SettlementOption) SettlementService {
+	ss := &settlementService{
+		items:       &db.items,
+		bids:        &db.bids,
+		orders:      &db.orders,
+		offers:      &db.offers,
+		wallets:     &db.wallets,
+		userService: usvc,
+		clock:       db.clock,
+	}
+
+	for _, opt := range opts {
+		opt(ss)
+	}
+
+	return ss
+}
+
+func (ss *settlementService) Settle() ([]Item, error) {
+	var settled []Item
+	for _, item := range ss.items.ListItems() {
+		if item.State != ItemClosed || !item.Sold {
+			continue
+		}
+
+		var updated Item
+		err := ss.items.TxUpdate(item.ID, func(i *Item) error {
+			if i.State != ItemClosed {
+				return nil // settled in the meantime
+			}
+
+			if err := ss.issue(*i); err != nil {
+				return err
+			}
+
+			i.State = ItemSettled
+			updated = *i
+			return nil
+		})
+		if err != nil {
+			return settled, err
+		}
+
+		if updated.ID != 0 {
+			settled = append(settled, updated)
+		}
+	}
+
+	orders, err := ss.orders.ListUnpaidOrders()
+	if err != nil {
+		return settled, err
+	}
+
+	for _, o := range orders {
+		// the item does not change once settled, and it cannot be locked after the order
+		item, err := ss.items.Get(o.ItemID)
+		if err != nil {
+			return settled, err
+		}
+
+		err = ss.orders.TxUpdate(o.ID, func(o *Order) error {
+			if err := ss.collect(o); err != nil {
+				return err
+			}
+			return ss.enforceDeadlines(item, o)
+		})
+		if err != nil {
+			return settled, err
+		}
+	}
+
+	return settled, ss.expireOffers()
+}
+
+// issue stores the order of the closed item i, unless it was already issued. It must be called while
+// the item is locked.
+func (ss *settlementService) issue(i Item) error {
+	if _, err := ss.orders.GetByItemID(i.ID); err != ErrNotFound {
+		return err
+	}
+
+	bids, err := ss.bids.ListBidsByItemID(i.ID)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+
+	o := Order{ItemID: i.ID, CreatedAt: ss.clock.Now()}
+
+	if i.multiUnit() {
+		ranked := activeBids(bids)
+		rankBids(i, ranked)
+
+		for _, a := range allocate(i, ranked) {
+			price, err := i.ClearingPrice.Times(int64(a.Quantity))
+			if err != nil {
+				return err
+			}
+
+			l, err := ss.line(i, a.Bid, a.Quantity, price)
+			if err != nil {
+				return err
+			}
+			o.Lines = append(o.Lines, l)
+		}
+	} else {
+		for _, b := range bids {
+			if b.ID != i.WinningBidID {
+				continue
+			}
+
+			l, err := ss.line(i, b, 1, *i.ClearingPrice)
+			if err != nil {
+				return err
+			}
+			o.Lines = append(o.Lines, l)
+		}
+	}
+
+	return ss.orders.TxCreate(&o)
+}
+
+// line returns the order line of the units of item i won by bid b at price, to be paid before the
+// payment deadline.
+func (ss *settlementService) line(i Item, b Bid, quantity int, price Money) (OrderLine, error) {
+	l := OrderLine{BidID: b.ID, BuyerID: b.UserID, SellerID: i.SellerID, Quantity: quantity, Price: price}
+	if ss.paymentDeadline > 0 {
+		l.DueAt = ss.clock.Now().Add(ss.paymentDeadline)
+	}
+	if i.Direction == AuctionReverse {
+		l.BuyerID, l.SellerID = l.SellerID, l.BuyerID
+	}
+
+	var err error
+	if l.BuyerPremium, err = ss.premium.Fee(price); err != nil {
+		return OrderLine{}, err
+	}
+	if l.SellerCommission, err = ss.commission.Fee(price); err != nil {
+		return OrderLine{}, err
+	}
+	if l.BuyerTotal, err = price.Add(l.BuyerPremium); err != nil {
+		return OrderLine{}, err
+	}
+	if l.SellerPayout, err = price.Sub(l.SellerCommission); err != nil {
+		return OrderLine{}, err
+	}
+
+	return l, nil
+}
+
+// collect takes the payments of the unpaid lines of o. Lines whose buyer does not have the funds are
+// left unpaid, along with the funds they hold on the item, until the next attempt.
+func (ss *settlementService) collect(o *Order) error {
+	for n := range o.Lines {
+		l := &o.Lines[n]
+		if !l.due() {
+			continue
+		}
+
+		charge, payout := l.paymentRefs(*o)
+
+		err := ss.wallets.TxCapture(charge, Hold{UserID: l.BuyerID, ItemID: o.ItemID, Amount: l.BuyerTotal})
+		if err == ErrNoFunds {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := ss.wallets.TxCredit(payout, Transfer{UserID: l.SellerID, Amount: l.SellerPayout}); err != nil {
+			return err
+		}
+
+		l.Paid = true
+	}
+
+	return nil
+}
+
+func (ss *settlementService) Outstanding() bool {
+	orders, err := ss.orders.ListUnpaidOrders()
+	if err != nil || len(orders) > 0 {
+		return true
+	}
+
+	offers, err := ss.offers.ListPendingOffers()
+	return err != nil || len(offers) > 0
+}
+
+// GetOrderByItemID returns the order of a settled item. It returns ErrNotFound if the item was not
+// settled yet.
+func (ss *settlementService) GetOrderByItemID(itemID int64) (Order, error) {
+	return ss.orders.GetByItemID(itemID)
+}
+
+func (ss *settlementService) ListInvoicesByUserID(userID int64) ([]Invoice, error) {
+	if _, err := ss.userService.Get(userID); err != nil {
+		return nil, ValidationError{"user": ErrNotFound}
+	}
+
+	orders, err := ss.orders.ListOrdersByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	invs := []Invoice{}
+	for _, o := range orders {
+		invs = append(invs, invoices(o, userID)...)
+	}
+
+	return invs, nil
+}
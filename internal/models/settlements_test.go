@@ -0,0 +1,246 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettlementService_Settle(t *testing.T) {
+	now := testNow
+	db := CreateDatabase()
+	db.SetClock(ClockFunc(func() time.Time { return now }))
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc, WithHolds())
+	wsvc := NewWalletService(db, usvc)
+	asvc := NewAuctionService(db)
+	ssvc := NewSettlementService(db, usvc,
+		WithBuyerPremium(FeeSchedule{Tiers: []FeeTier{{BasisPoints: 2000}}}),
+		WithSellerCommission(FeeSchedule{Tiers: []FeeTier{{BasisPoints: 1000}}}),
+	)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+	seller := testSeller(usvc)
+
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 1, Amount: gbp(10000)}))
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 2, Amount: gbp(10000)}))
+
+	sold := Item{Name: "portal gun", SellerID: seller, Value: gbp(1000), EndsAt: now.Add(time.Hour)}
+	assert.NoError(t, isvc.TxCreate(&sold))
+	unsold := Item{Name: "plumbus", SellerID: seller, Value: gbp(1000), EndsAt: now.Add(time.Hour)}
+	assert.NoError(t, isvc.TxCreate(&unsold))
+
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: sold.ID, Amount: gbp(4000)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: sold.ID, Amount: gbp(5000)}))
+
+	// open items are not settled
+	settled, err := ssvc.Settle()
+	assert.NoError(t, err)
+	assert.Empty(t, settled)
+
+	now = sold.EndsAt
+	_, err = asvc.Advance()
+	assert.NoError(t, err)
+
+	settled, err = ssvc.Settle()
+	assert.NoError(t, err)
+	assert.Len(t, settled, 1)
+	assert.Equal(t, sold.ID, settled[0].ID)
+	assert.Equal(t, ItemSettled, settled[0].State)
+	assert.False(t, ssvc.Outstanding())
+
+	order, err := ssvc.GetOrderByItemID(sold.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, Order{ID: 1, ItemID: sold.ID, CreatedAt: now, Lines: []OrderLine{{
+		BidID:            2,
+		BuyerID:          2,
+		SellerID:         seller,
+		Quantity:         1,
+		Price:            gbp(5000),
+		BuyerPremium:     gbp(1000),
+		SellerCommission: gbp(500),
+		BuyerTotal:       gbp(6000),
+		SellerPayout:     gbp(4500),
+		Paid:             true,
+	}}}, order)
+
+	_, err = ssvc.GetOrderByItemID(unsold.ID)
+	assert.Equal(t, ErrNotFound, err)
+
+	unsold, err = isvc.Get(unsold.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, ItemClosed, unsold.State)
+
+	balances := func(userID int64) []Balance {
+		wallet, err := wsvc.Get(userID)
+		assert.NoError(t, err)
+		return wallet.Balances
+	}
+
+	// the hold of the buyer is captured along with the premium, the seller gets their payout
+	assert.Equal(t, []Balance{{Total: gbp(4000), Held: gbp(0), Available: gbp(4000)}}, balances(2))
+	assert.Equal(t, []Balance{{Total: gbp(4500), Held: gbp(0), Available: gbp(4500)}}, balances(seller))
+
+	// running the settlement again after a crash, before the item and the order were updated, issues
+	// the same order and takes no payment twice
+	assert.NoError(t, db.items.TxUpdate(sold.ID, func(i *Item) error {
+		i.State = ItemClosed
+		return nil
+	}))
+	assert.NoError(t, db.orders.TxUpdate(order.ID, func(o *Order) error {
+		o.Lines[0].Paid = false
+		return nil
+	}))
+
+	settled, err = ssvc.Settle()
+	assert.NoError(t, err)
+	assert.Len(t, settled, 1)
+
+	again, err := ssvc.GetOrderByItemID(sold.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, order, again)
+
+	assert.Equal(t, []Balance{{Total: gbp(4000), Held: gbp(0), Available: gbp(4000)}}, balances(2))
+	assert.Equal(t, []Balance{{Total: gbp(4500), Held: gbp(0), Available: gbp(4500)}}, balances(seller))
+
+	settled, err = ssvc.Settle()
+	assert.NoError(t, err)
+	assert.Empty(t, settled)
+}
+
+func TestSettlementService_Unpaid(t *testing.T) {
+	now := testNow
+	db := CreateDatabase()
+	db.SetClock(ClockFunc(func() time.Time { return now }))
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc, WithHolds())
+	wsvc := NewWalletService(db, usvc)
+	asvc := NewAuctionService(db)
+	ssvc := NewSettlementService(db, usvc, WithBuyerPremium(FeeSchedule{Tiers: []FeeTier{{BasisPoints: 2000}}}))
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	seller := testSeller(usvc)
+
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 1, Amount: gbp(5000)}))
+
+	item := Item{Name: "portal gun", SellerID: seller, Value: gbp(1000), EndsAt: now.Add(time.Hour)}
+	assert.NoError(t, isvc.TxCreate(&item))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: gbp(5000)}))
+
+	now = item.EndsAt
+	_, err := asvc.Advance()
+	assert.NoError(t, err)
+
+	// the funds cover the hammer price, but not the premium
+	settled, err := ssvc.Settle()
+	assert.NoError(t, err)
+	assert.Len(t, settled, 1)
+	assert.True(t, ssvc.Outstanding())
+
+	order, err := ssvc.GetOrderByItemID(item.ID)
+	assert.NoError(t, err)
+	assert.False(t, order.Lines[0].Paid)
+
+	wallet, err := wsvc.Get(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []Balance{{Total: gbp(5000), Held: gbp(5000), Available: gbp(0)}}, wallet.Balances)
+
+	invs, err := ssvc.ListInvoicesByUserID(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []Invoice{{
+		OrderID: order.ID,
+		ItemID:  item.ID,
+		BidID:   order.Lines[0].BidID,
+		UserID:  1,
+		Role:    InvoiceBuyer,
+		Lines: []InvoiceLine{
+			{Description: "Hammer price", Amount: gbp(5000)},
+			{Description: "Buyer's premium", Amount: gbp(1000)},
+		},
+		Total:    gbp(6000),
+		IssuedAt: now,
+	}}, invs)
+
+	// the payment is taken on the next run once the buyer has the funds
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 1, Amount: gbp(1000)}))
+
+	settled, err = ssvc.Settle()
+	assert.NoError(t, err)
+	assert.Empty(t, settled)
+	assert.False(t, ssvc.Outstanding())
+
+	wallet, err = wsvc.Get(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []Balance{{Total: gbp(0), Held: gbp(0), Available: gbp(0)}}, wallet.Balances)
+
+	invs, err = ssvc.ListInvoicesByUserID(seller)
+	assert.NoError(t, err)
+	assert.Len(t, invs, 1)
+	assert.Equal(t, InvoiceSeller, invs[0].Role)
+	assert.Equal(t, gbp(5000), invs[0].Total)
+	assert.True(t, invs[0].Paid)
+
+	_, err = ssvc.ListInvoicesByUserID(99)
+	assert.True(t, errors.Is(err, ValidationError{"user": ErrNotFound}), "unexpected error %v", err)
+}
+
+func TestSettlementService_MultiUnit(t *testing.T) {
+	now := testNow
+	db := CreateDatabase()
+	db.SetClock(ClockFunc(func() time.Time { return now }))
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc)
+	wsvc := NewWalletService(db, usvc)
+	asvc := NewAuctionService(db)
+	ssvc := NewSettlementService(db, usvc, WithSellerCommission(FeeSchedule{Tiers: []FeeTier{{BasisPoints: 1000}}}))
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+	seller := testSeller(usvc)
+
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 1, Amount: gbp(1000)}))
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 2, Amount: gbp(1000)}))
+
+	item := Item{Name: "concert ticket", SellerID: seller, Value: gbp(10), Quantity: 5, EndsAt: now.Add(time.Hour)}
+	assert.NoError(t, isvc.TxCreate(&item))
+
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: gbp(30), Quantity: 3}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: item.ID, Amount: gbp(20), Quantity: 3}))
+
+	now = item.EndsAt
+	_, err := asvc.Advance()
+	assert.NoError(t, err)
+
+	_, err = ssvc.Settle()
+	assert.NoError(t, err)
+
+	// every winner pays the lowest winning bid for each of their units
+	order, err := ssvc.GetOrderByItemID(item.ID)
+	assert.NoError(t, err)
+	assert.Len(t, order.Lines, 2)
+
+	for n, l := range []struct {
+		buyer    int64
+		quantity int
+		price    Money
+	}{{1, 3, gbp(60)}, {2, 2, gbp(40)}} {
+		assert.Equal(t, l.buyer, order.Lines[n].BuyerID)
+		assert.Equal(t, l.quantity, order.Lines[n].Quantity)
+		assert.Equal(t, l.price, order.Lines[n].Price)
+		assert.Equal(t, l.price, order.Lines[n].BuyerTotal)
+		assert.True(t, order.Lines[n].Paid)
+	}
+
+	wallet, err := wsvc.Get(seller)
+	assert.NoError(t, err)
+	assert.Equal(t, []Balance{{Total: gbp(90), Held: gbp(0), Available: gbp(90)}}, wallet.Balances)
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// SoftClose prevents sniping: a bid accepted within the final Window of an auction pushes its end
+// back by Extension, up to MaxExtension in total. A zero MaxExtension means there is no limit.
+type SoftClose struct {
+	Window       time.Duration
+	Extension    time.Duration
+	MaxExtension time.Duration
+}
+
+// enabled reports whether sc extends the auctions at all.
+func (sc SoftClose) enabled() bool {
+	return sc.Window > 0 && sc.Extension > 0
+}
+
+// extendEnd pushes back the end of item i, which must be locked, if a bid accepted now falls within
+// the soft close window.
+func (bs *bidValidator) extendEnd(i *Item) {
+	sc := bs.softClose
+	if !sc.enabled() || i.EndsAt.Sub(bs.clock.Now()) > sc.Window {
+		return
+	}
+
+	ext := sc.Extension
+	if sc.MaxExtension > 0 && i.ExtendedBy+ext > sc.MaxExtension {
+		ext = sc.MaxExtension - i.ExtendedBy
+	}
+
+	if ext <= 0 {
+		return
+	}
+
+	i.EndsAt = i.EndsAt.Add(ext)
+	i.ExtendedBy += ext
+}
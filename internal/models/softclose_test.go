@@ -0,0 +1,49 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBidService_SoftClose(t *testing.T) {
+	now := testNow
+	db := CreateDatabase()
+	db.SetClock(ClockFunc(func() time.Time { return now }))
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc, WithSoftClose(SoftClose{
+		Window:       5 * time.Minute,
+		Extension:    2 * time.Minute,
+		MaxExtension: 3 * time.Minute,
+	}))
+
+	usvc.TxCreate(&User{Name: "Morty"})
+
+	end := now.Add(time.Hour)
+	item := Item{Name: "portal gun", SellerID: testSeller(usvc), Value: gbp(10), EndsAt: end}
+	assert.NoError(t, isvc.TxCreate(&item))
+
+	var cases = []struct {
+		name      string
+		at        time.Time
+		outendsAt time.Time
+	}{
+		{"outside_window", end.Add(-10 * time.Minute), end},
+		{"within_window", end.Add(-4 * time.Minute), end.Add(2 * time.Minute)},
+		{"capped", end.Add(time.Minute), end.Add(3 * time.Minute)},
+		{"max_reached", end.Add(2 * time.Minute), end.Add(3 * time.Minute)},
+	}
+	for n, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			now = tt.at
+
+			assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: gbp(20 + int64(n))}))
+
+			item, _ := isvc.Get(item.ID)
+			assert.Equal(t, tt.outendsAt, item.EndsAt)
+		})
+	}
+}
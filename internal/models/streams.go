@@ -0,0 +1,226 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// streamBuffer is the number of bids a stream may fall behind the storage before it is unsubscribed
+// and has to catch up from the storage.
+const streamBuffer = 64
+
+// bidFeed fans out the bids stored to the streams following their item. The bids are sent without
+// blocking: a stream that does not keep up is unsubscribed, closing its channel, so a slow consumer
+// never holds up the storage.
+type bidFeed struct {
+	mu   sync.Mutex
+	subs map[int64]map[chan Bid]bool
+}
+
+// subscribe returns a channel receiving the bids of the item stored from now on.
+func (f *bidFeed) subscribe(itemID int64) chan Bid {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.subs == nil {
+		f.subs = make(map[int64]map[chan Bid]bool)
+	}
+	if f.subs[itemID] == nil {
+		f.subs[itemID] = make(map[chan Bid]bool)
+	}
+
+	c := make(chan Bid, streamBuffer)
+	f.subs[itemID][c] = true
+	return c
+}
+
+// unsubscribe stops sending the bids of the item to c and closes it, unless it was already.
+func (f *bidFeed) unsubscribe(itemID int64, c chan Bid) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.remove(itemID, c)
+}
+
+func (f *bidFeed) remove(itemID int64, c chan Bid) {
+	if !f.subs[itemID][c] {
+		return
+	}
+
+	delete(f.subs[itemID], c)
+	if len(f.subs[itemID]) == 0 {
+		delete(f.subs, itemID)
+	}
+	close(c)
+}
+
+// publish sends b to the subscribers of its item. It must be called once b is stored, and not while
+// the storage is locked.
+func (f *bidFeed) publish(b Bid) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for c := range f.subs[b.ItemID] {
+		select {
+		case c <- b:
+		default:
+			f.remove(b.ItemID, c)
+		}
+	}
+}
+
+// BidEventType is the kind of a BidEvent.
+type BidEventType string
+
+const (
+	BidEventBid    BidEventType = "bid"    // a bid was placed on the item
+	BidEventWinner BidEventType = "winner" // the winning bid of the item changed
+)
+
+// BidEvent is an event of a BidStream. Bid is the bid placed, or the new winning bid of Item.
+type BidEvent struct {
+	Type BidEventType
+	Bid  Bid
+	Item Item
+}
+
+// BidStream follows the bids of an item as they are placed, along with the changes of its winning
+// bid. Events are read with Next, and Close must be called once done.
+type BidStream struct {
+	itemID int64
+	feed   *bidFeed
+	bids   BidDB
+	svc    BidService
+	items  ItemService
+
+	c       chan Bid
+	pending []BidEvent
+
+	// lastID is the last bid streamed, and winnerID the last winning bid streamed
+	lastID   int64
+	winnerID int64
+}
+
+// StreamBids returns a stream of the bids placed on the item after the bid lastID, starting with the
+// current winning bid. Zero means that only the bids placed from now on are streamed. The bids of
+// sealed auctions cannot be followed until they close.
+func (bs *bidValidator) StreamBids(itemID, lastID int64) (*BidStream, error) {
+	item, err := bs.itemService.Get(itemID)
+	if err != nil {
+		return nil, ValidationError{"item": ErrNotFound}
+	}
+
+	if item.Type.Sealed() && !item.closed() {
+		return nil, ValidationError{"item": ErrSealed}
+	}
+
+	s := &BidStream{
+		itemID: itemID,
+		feed:   bs.feed,
+		bids:   bs.BidDB,
+		svc:    bs,
+		items:  bs.itemService,
+		lastID: lastID,
+	}
+
+	// the stream subscribes before reading the storage, so no bid is missed in between
+	s.c = s.feed.subscribe(itemID)
+	if err := s.catchUp(lastID == 0); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Next returns the next event of the stream, waiting for it until ctx is done. The stream can still
+// be read after ctx is done.
+func (s *BidStream) Next(ctx context.Context) (BidEvent, error) {
+	for len(s.pending) == 0 {
+		select {
+		case <-ctx.Done():
+			return BidEvent{}, ctx.Err()
+		case b, ok := <-s.c:
+			// the stream fell behind and was unsubscribed, the bids it missed are in the storage
+			if !ok {
+				s.c = s.feed.subscribe(s.itemID)
+				if err := s.catchUp(false); err != nil {
+					return BidEvent{}, err
+				}
+				continue
+			}
+
+			if b.ID > s.lastID {
+				s.lastID = b.ID
+				s.pending = append(s.pending, BidEvent{Type: BidEventBid, Bid: b})
+			}
+
+			// the winning bid is checked once the bids received are streamed, so it comes after them
+			if len(s.c) > 0 {
+				continue
+			}
+			if err := s.checkWinner(); err != nil {
+				return BidEvent{}, err
+			}
+		}
+	}
+
+	e := s.pending[0]
+	s.pending = s.pending[1:]
+	return e, nil
+}
+
+// Close stops following the bids of the item.
+func (s *BidStream) Close() {
+	s.feed.unsubscribe(s.itemID, s.c)
+}
+
+// catchUp queues the bids stored after the last one streamed, or skips them if skip is set, and the
+// winning bid if it changed.
+func (s *BidStream) catchUp(skip bool) error {
+	bids, err := s.bids.ListBidsByItemID(s.itemID)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+
+	sort.Slice(bids, func(a, b int) bool { return bids[a].ID < bids[b].ID })
+	for _, b := range bids {
+		if b.ID <= s.lastID {
+			continue
+		}
+
+		s.lastID = b.ID
+		if !skip {
+			s.pending = append(s.pending, BidEvent{Type: BidEventBid, Bid: b})
+		}
+	}
+
+	return s.checkWinner()
+}
+
+// checkWinner queues the winning bid of the item if it changed since it was last streamed.
+func (s *BidStream) checkWinner() error {
+	winning, err := s.svc.GetWinningBid(s.itemID)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+
+	if winning.ID == s.winnerID {
+		return nil
+	}
+	s.winnerID = winning.ID
+
+	// every bid may have been retracted
+	if winning.ID == 0 {
+		return nil
+	}
+
+	item, err := s.items.Get(s.itemID)
+	if err != nil {
+		return err
+	}
+
+	s.pending = append(s.pending, BidEvent{Type: BidEventWinner, Bid: winning, Item: item})
+	return nil
+}
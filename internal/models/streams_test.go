@@ -0,0 +1,154 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// nextEvents reads n events from s, failing the test if they do not come quickly.
+func nextEvents(t *testing.T, s *BidStream, n int) []BidEvent {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var events []BidEvent
+	for len(events) < n {
+		e, err := s.Next(ctx)
+		if !assert.NoError(t, err) {
+			break
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+// noEvent checks that s has nothing more to send.
+func noEvent(t *testing.T, s *BidStream) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := s.Next(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestBidService_StreamBids(t *testing.T) {
+	db := CreateDatabase()
+	db.SetClock(testClock())
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc, WithRetraction(RetractionPolicy{Window: time.Hour}))
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+	seller := testSeller(usvc)
+
+	english := Item{Name: "portal gun", SellerID: seller, Value: gbp(10)}
+	sealed := Item{Name: "plumbus", SellerID: seller, Value: gbp(10), Type: AuctionSealedFirstPrice}
+	for _, i := range []*Item{&english, &sealed} {
+		assert.NoError(t, isvc.TxCreate(i))
+	}
+
+	_, err := bsvc.StreamBids(sealed.ID, 0)
+	assert.Equal(t, ValidationError{"item": ErrSealed}, err)
+	_, err = bsvc.StreamBids(42, 0)
+	assert.Equal(t, ValidationError{"item": ErrNotFound}, err)
+
+	first := Bid{UserID: 1, ItemID: english.ID, Amount: gbp(20)}
+	assert.NoError(t, bsvc.TxCreate(&first))
+
+	// a new stream starts with the current winning bid
+	s, err := bsvc.StreamBids(english.ID, 0)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	events := nextEvents(t, s, 1)
+	assert.Equal(t, BidEventWinner, events[0].Type)
+	assert.Equal(t, first.ID, events[0].Bid.ID)
+	assert.Equal(t, english.ID, events[0].Item.ID)
+	noEvent(t, s)
+
+	second := Bid{UserID: 2, ItemID: english.ID, Amount: gbp(30)}
+	assert.NoError(t, bsvc.TxCreate(&second))
+
+	events = nextEvents(t, s, 2)
+	assert.Equal(t, BidEvent{Type: BidEventBid, Bid: second}, events[0])
+	assert.Equal(t, BidEventWinner, events[1].Type)
+	assert.Equal(t, second.ID, events[1].Bid.ID)
+	noEvent(t, s)
+
+	// retracting the winning bid gives the lead back to the first one
+	assert.NoError(t, bsvc.TxRetract(&Bid{ID: second.ID, UserID: 2, Reason: "typo"}))
+	events = nextEvents(t, s, 1)
+	assert.Equal(t, BidEventWinner, events[0].Type)
+	assert.Equal(t, first.ID, events[0].Bid.ID)
+	noEvent(t, s)
+
+	// resuming after the first bid sends the bids that came next
+	resumed, err := bsvc.StreamBids(english.ID, first.ID)
+	assert.NoError(t, err)
+	defer resumed.Close()
+
+	events = nextEvents(t, resumed, 2)
+	assert.Equal(t, BidEventBid, events[0].Type)
+	assert.Equal(t, second.ID, events[0].Bid.ID)
+	assert.Equal(t, BidEventWinner, events[1].Type)
+	assert.Equal(t, first.ID, events[1].Bid.ID)
+	noEvent(t, resumed)
+}
+
+func TestBidService_StreamBids_SlowConsumer(t *testing.T) {
+	db := CreateDatabase()
+	db.SetClock(testClock())
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+
+	item := Item{Name: "portal gun", SellerID: testSeller(usvc), Value: gbp(10)}
+	assert.NoError(t, isvc.TxCreate(&item))
+
+	s, err := bsvc.StreamBids(item.ID, 0)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	// nobody reads the stream while the bids are placed, which does not hold them up
+	n := 2*streamBuffer + 10
+	var last Bid
+	for i := 0; i < n; i++ {
+		last = Bid{UserID: int64(1 + i%2), ItemID: item.ID, Amount: gbp(int64(20 + i*10))}
+		assert.NoError(t, bsvc.TxCreate(&last))
+	}
+
+	// the stream catches up from the storage without missing nor repeating a bid, and ends with the
+	// last winning bid
+	var ids []int64
+	var winner int64
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		e, err := s.Next(ctx)
+		cancel()
+		if err != nil {
+			assert.Equal(t, context.DeadlineExceeded, err)
+			break
+		}
+
+		switch e.Type {
+		case BidEventBid:
+			ids = append(ids, e.Bid.ID)
+		case BidEventWinner:
+			winner = e.Bid.ID
+		}
+	}
+
+	assert.Equal(t, last.ID, winner)
+	assert.Len(t, ids, n)
+	for i, id := range ids {
+		assert.Equal(t, int64(i+1), id)
+	}
+}
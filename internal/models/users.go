@@ -1,5 +1,9 @@
 package models
 
+import (
+	"time"
+)
+
 type UserService interface {
 	UserDB
 }
@@ -8,11 +12,19 @@ type UserDB interface {
 	TxCreate(*User)
 	Get(int64) (User, error)
 	ListUsers() []User
+
+	// TxStrike records a non-paying-bidder strike on the user.
+	TxStrike(int64) error
 }
 
 type User struct {
 	ID   int64  `json:"id"`
 	Name string `json:"name"`
+
+	// Strikes is the number of items the user won and did not pay for in time.
+	Strikes int `json:"strikes"`
+
+	CreatedAt time.Time `json:"createdAt"`
 }
 
 // userService wraps the UserService interface to allow mocking by interfaces
@@ -22,16 +34,20 @@ type userService struct {
 
 type userCapsule struct {
 	UserDB
+	clock Clock
 }
 
 func NewUserService(db *DB) UserService {
 	return userService{
 		UserService: &userCapsule{
-			UserDB: &UserStorage{
-				db.users.mu,
-				db.users.data,
-				0,
-			},
+			UserDB: &db.users,
+			clock:  db.clock,
 		},
 	}
 }
+
+// TxCreate stores u as a new user, created now.
+func (uc *userCapsule) TxCreate(u *User) {
+	u.CreatedAt = uc.clock.Now()
+	uc.UserDB.TxCreate(u)
+}
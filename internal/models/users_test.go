@@ -37,6 +37,7 @@ func TestUserService_TxCreate(t *testing.T) {
 	tudb := &testUserDB{}
 
 	db := CreateDatabase()
+	db.SetClock(testClock())
 	usvc := NewUserService(db)
 
 	usvc.(userService).UserService.(*userCapsule).UserDB = tudb
@@ -49,7 +50,7 @@ func TestUserService_TxCreate(t *testing.T) {
 		{
 			"ok",
 			map[int64]User{
-				1: {ID: 1, Name: "test"},
+				1: {ID: 1, Name: "test", CreatedAt: testNow},
 			},
 			func(t *testing.T) {
 				tudb.txCreate = func(u *User) {
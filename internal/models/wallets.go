@@ -0,0 +1,212 @@
+package models
+
+// Wallet is the money of a user, with a balance for every currency they deposited, along with the
+// funds held by their bids.
+type Wallet struct {
+	UserID   int64     `json:"userId"`
+	Balances []Balance `json:"balances"`
+	Holds    []Hold    `json:"holds"`
+}
+
+// Balance is the money of a user in a currency. Total is what was deposited and not withdrawn, out of
+// which Held is set aside by the bids of the user, leaving Available to bid or withdraw.
+type Balance struct {
+	Total     Money `json:"total"`
+	Held      Money `json:"held"`
+	Available Money `json:"available"`
+}
+
+// Hold sets funds of a user aside while they lead the bidding on an item, so they cannot commit the
+// same money twice. A user holds funds at most once per item.
+type Hold struct {
+	UserID int64 `json:"userId"`
+	ItemID int64 `json:"itemId"`
+	Amount Money `json:"amount"`
+}
+
+// Transfer is a deposit into or a withdrawal from the wallet of a user.
+type Transfer struct {
+	UserID int64 `json:"userId"`
+	Amount Money `json:"amount"`
+}
+
+type WalletDB interface {
+	Get(int64) (Wallet, error)
+	TxDeposit(*Transfer) error
+	TxWithdraw(*Transfer) error
+
+	// TxHold places h, replacing the previous hold of the user on the item, and releases the holds of
+	// the outbid users on the same item in a single step. It returns ErrNoFunds, and changes nothing,
+	// if the available balance of the user is not enough.
+	TxHold(h Hold, outbid ...int64) error
+
+	// TxRelease releases the holds of the given users on an item.
+	TxRelease(itemID int64, userIDs ...int64) error
+
+	ListHoldsByItemID(int64) ([]Hold, error)
+
+	// TxRestoreHolds puts back the holds placed on an item as they were, releasing the others.
+	TxRestoreHolds(itemID int64, holds []Hold) error
+
+	// TxCapture takes the amount of h from the wallet of its user as the payment identified by ref,
+	// releasing the hold of the user on the item. It returns ErrNoFunds, and changes nothing, if the
+	// balance of the user not held by other items is not enough. A payment already taken is not
+	// taken again.
+	TxCapture(ref string, h Hold) error
+
+	// TxCredit adds the amount of t to the wallet of its user as the payment identified by ref. A
+	// payment already made is not made again.
+	TxCredit(ref string, t Transfer) error
+}
+
+type WalletService interface {
+	WalletDB
+}
+
+// walletService wraps the WalletService interface to allow mocking by interfaces
+type walletService struct {
+	WalletService
+}
+
+func NewWalletService(db *DB, usvc UserService) WalletService {
+	return walletService{
+		WalletService: &walletValidator{
+			WalletDB:    &db.wallets,
+			userService: usvc,
+		},
+	}
+}
+
+type walletValidator struct {
+	WalletDB
+	userService UserService
+}
+
+type transferValFn func(*Transfer) error
+
+func (wv *walletValidator) runValFuncs(t *Transfer, fns ...func() (string, transferValFn)) error {
+	return runValidationFunctions(t, fns)
+}
+
+// Get returns the wallet of the user, which is empty until they deposit money.
+func (wv *walletValidator) Get(userID int64) (Wallet, error) {
+	if err := wv.runValFuncs(&Transfer{UserID: userID},
+		wv.userExists,
+	); err != nil {
+		return Wallet{}, err
+	}
+
+	return wv.WalletDB.Get(userID)
+}
+
+func (wv *walletValidator) TxDeposit(t *Transfer) error {
+	if err := wv.runValFuncs(t,
+		wv.userExists,
+		wv.positiveAmount,
+	); err != nil {
+		return err
+	}
+
+	return wv.WalletDB.TxDeposit(t)
+}
+
+// TxWithdraw takes money out of the wallet of the user, which cannot exceed their available balance.
+func (wv *walletValidator) TxWithdraw(t *Transfer) error {
+	if err := wv.runValFuncs(t,
+		wv.userExists,
+		wv.positiveAmount,
+	); err != nil {
+		return err
+	}
+
+	if err := wv.WalletDB.TxWithdraw(t); err != nil {
+		if err == ErrNoFunds {
+			return ValidationError{"amount": ErrNoFunds}
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (wv *walletValidator) userExists() (string, transferValFn) {
+	return "user", func(t *Transfer) error {
+		if _, err := wv.userService.Get(t.UserID); err != nil {
+			return ErrNotFound
+		}
+		return nil
+	}
+}
+
+func (wv *walletValidator) positiveAmount() (string, transferValFn) {
+	return "amount", func(t *Transfer) error {
+		if err := t.Amount.Validate(); err != nil {
+			return err
+		}
+
+		if t.Amount.Amount == 0 {
+			return ErrInvalid
+		}
+		return nil
+	}
+}
+
+// leaders returns the users who lead the bidding on item among bids, who are the ones holding funds:
+// the users winning units while the item is open, every bidder of a sealed auction until it closes,
+// and the winners once the item is sold.
+func leaders(item Item, bids []Bid) map[int64]bool {
+	bids = activeBids(bids)
+	rankBids(item, bids)
+
+	users := map[int64]bool{}
+	switch {
+	case item.closed() && !item.Sold:
+	case item.multiUnit():
+		for _, a := range allocate(item, bids) {
+			users[a.Bid.UserID] = true
+		}
+	case item.closed():
+		for _, b := range bids {
+			if b.ID == item.WinningBidID {
+				users[b.UserID] = true
+			}
+		}
+	case item.Type.Sealed():
+		for _, b := range bids {
+			users[b.UserID] = true
+		}
+	case len(bids) > 0:
+		users[bids[0].UserID] = true
+	}
+
+	return users
+}
+
+// releaseHolds releases the funds held on item i by the users who do not lead its bidding anymore.
+// It must be called while the item is locked.
+func releaseHolds(wallets WalletDB, bdb BidDB, i Item) error {
+	bids, err := bdb.ListBidsByItemID(i.ID)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+
+	holds, err := wallets.ListHoldsByItemID(i.ID)
+	if err != nil {
+		return err
+	}
+
+	lead := leaders(i, bids)
+
+	var outbid []int64
+	for _, h := range holds {
+		if !lead[h.UserID] {
+			outbid = append(outbid, h.UserID)
+		}
+	}
+
+	if len(outbid) == 0 {
+		return nil
+	}
+
+	return wallets.TxRelease(i.ID, outbid...)
+}
@@ -0,0 +1,374 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletService_Transfers(t *testing.T) {
+	db := CreateDatabase()
+	usvc := NewUserService(db)
+	wsvc := NewWalletService(db, usvc)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+
+	var cases = []struct {
+		name      string
+		deposit   bool
+		transfer  Transfer
+		outerr    error
+		outwallet []Balance
+	}{
+		{
+			"deposit",
+			true,
+			Transfer{UserID: 1, Amount: gbp(1000)},
+			nil,
+			[]Balance{{Total: gbp(1000), Held: gbp(0), Available: gbp(1000)}},
+		},
+		{
+			"deposit_other_currency",
+			true,
+			Transfer{UserID: 1, Amount: Money{Amount: 500, Currency: "EUR"}},
+			nil,
+			[]Balance{
+				{Total: Money{Amount: 500, Currency: "EUR"}, Held: Money{Currency: "EUR"}, Available: Money{Amount: 500, Currency: "EUR"}},
+				{Total: gbp(1000), Held: gbp(0), Available: gbp(1000)},
+			},
+		},
+		{
+			"withdraw",
+			false,
+			Transfer{UserID: 1, Amount: Money{Amount: 500, Currency: "EUR"}},
+			nil,
+			[]Balance{
+				{Total: Money{Currency: "EUR"}, Held: Money{Currency: "EUR"}, Available: Money{Currency: "EUR"}},
+				{Total: gbp(1000), Held: gbp(0), Available: gbp(1000)},
+			},
+		},
+		{"withdraw_too_much", false, Transfer{UserID: 1, Amount: gbp(1001)}, ValidationError{"amount": ErrNoFunds}, nil},
+		{"user_not_found", true, Transfer{UserID: 2, Amount: gbp(1000)}, ValidationError{"user": ErrNotFound}, nil},
+		{"zero_amount", true, Transfer{UserID: 1, Amount: gbp(0)}, ValidationError{"amount": ErrInvalid}, nil},
+		{"invalid_currency", true, Transfer{UserID: 1, Amount: Money{Amount: 10}}, ValidationError{"amount.currency": ErrRequired}, nil},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.deposit {
+				err = wsvc.TxDeposit(&tt.transfer)
+			} else {
+				err = wsvc.TxWithdraw(&tt.transfer)
+			}
+
+			if tt.outerr != nil {
+				assert.Error(t, err)
+				assert.True(t, errors.Is(err, tt.outerr), "errors must match, expected %v, got %v", tt.outerr, err)
+				return
+			}
+
+			assert.NoError(t, err)
+
+			wallet, err := wsvc.Get(1)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.outwallet, wallet.Balances)
+		})
+	}
+}
+
+func TestBidService_Holds(t *testing.T) {
+	now := testNow
+	db := CreateDatabase()
+	db.SetClock(ClockFunc(func() time.Time { return now }))
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc, WithHolds())
+	wsvc := NewWalletService(db, usvc)
+	asvc := NewAuctionService(db)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 1, Amount: gbp(100)}))
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 2, Amount: gbp(100)}))
+
+	item := Item{Name: "portal gun", SellerID: testSeller(usvc), Value: gbp(10), EndsAt: now.Add(time.Hour)}
+	assert.NoError(t, isvc.TxCreate(&item))
+
+	held := func(userID int64) Money {
+		wallet, err := wsvc.Get(userID)
+		assert.NoError(t, err)
+		return wallet.Balances[0].Held
+	}
+
+	err := bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: gbp(101)})
+	assert.True(t, errors.Is(err, ValidationError{"amount": ErrNoFunds}), "unexpected error %v", err)
+
+	_, err = bsvc.ListBidsByItemID(item.ID)
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: gbp(40)}))
+	assert.Equal(t, gbp(40), held(1))
+
+	// the previous leader gets their funds back when they are outbid
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: item.ID, Amount: gbp(50)}))
+	assert.Equal(t, gbp(0), held(1))
+	assert.Equal(t, gbp(50), held(2))
+
+	// held funds cannot be withdrawn
+	err = wsvc.TxWithdraw(&Transfer{UserID: 2, Amount: gbp(51)})
+	assert.True(t, errors.Is(err, ValidationError{"amount": ErrNoFunds}), "unexpected error %v", err)
+
+	// raising their own bid replaces the hold of the user
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: item.ID, Amount: gbp(100)}))
+	assert.Equal(t, gbp(100), held(2))
+
+	now = item.EndsAt
+	_, err = asvc.Advance()
+	assert.NoError(t, err)
+
+	// the winner keeps their funds held until they pay
+	assert.Equal(t, gbp(100), held(2))
+}
+
+func TestBidService_Holds_Proxy(t *testing.T) {
+	db := CreateDatabase()
+	db.SetClock(testClock())
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc, WithHolds())
+	wsvc := NewWalletService(db, usvc)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 1, Amount: gbp(100)}))
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 2, Amount: gbp(100)}))
+
+	item := Item{Name: "portal gun", SellerID: testSeller(usvc), Value: gbp(10)}
+	assert.NoError(t, isvc.TxCreate(&item))
+
+	// the proxy holds its maximum, as it may bid it on behalf of the user
+	err := bsvc.TxCreateProxy(&ProxyBid{UserID: 1, ItemID: item.ID, MaxAmount: gbp(101)})
+	assert.True(t, errors.Is(err, ValidationError{"amount": ErrNoFunds}), "unexpected error %v", err)
+
+	assert.NoError(t, bsvc.TxCreateProxy(&ProxyBid{UserID: 1, ItemID: item.ID, MaxAmount: gbp(80)}))
+
+	// the proxy answers to the bid, so its user keeps their funds held
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: item.ID, Amount: gbp(50)}))
+
+	holds, err := db.wallets.ListHoldsByItemID(item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []Hold{{UserID: 1, ItemID: item.ID, Amount: gbp(80)}}, holds)
+
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: item.ID, Amount: gbp(90)}))
+
+	holds, err = db.wallets.ListHoldsByItemID(item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []Hold{{UserID: 2, ItemID: item.ID, Amount: gbp(90)}}, holds)
+}
+
+func TestBidService_Holds_Failed(t *testing.T) {
+	db := CreateDatabase()
+	db.SetClock(testClock())
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc, WithHolds())
+	wsvc := NewWalletService(db, usvc)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 1, Amount: gbp(math.MaxInt64)}))
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 2, Amount: gbp(math.MaxInt64)}))
+
+	item := Item{
+		Name:      "portal gun",
+		SellerID:  testSeller(usvc),
+		Value:     gbp(10),
+		Increment: &Increment{Type: IncrementFixed, Amount: 2},
+	}
+	assert.NoError(t, isvc.TxCreate(&item))
+
+	assert.NoError(t, bsvc.TxCreateProxy(&ProxyBid{UserID: 1, ItemID: item.ID, MaxAmount: gbp(math.MaxInt64)}))
+
+	bids, err := bsvc.ListBidsByItemID(item.ID)
+	assert.NoError(t, err)
+	holds, err := db.wallets.ListHoldsByItemID(item.ID)
+	assert.NoError(t, err)
+
+	s, err := bsvc.StreamBids(item.ID, 0)
+	assert.NoError(t, err)
+	defer s.Close()
+	nextEvents(t, s, 1)
+
+	// the bid is stored and held before the proxy fails to answer it, which undoes both
+	err = bsvc.TxCreate(&Bid{UserID: 2, ItemID: item.ID, Amount: gbp(math.MaxInt64 - 1)})
+	assert.Equal(t, ErrOverflow, err)
+
+	after, err := bsvc.ListBidsByItemID(item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, bids, after)
+
+	afterHolds, err := db.wallets.ListHoldsByItemID(item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, holds, afterHolds)
+
+	// and the stream never sees it
+	noEvent(t, s)
+}
+
+func TestBidService_Holds_Sealed(t *testing.T) {
+	now := testNow
+	db := CreateDatabase()
+	db.SetClock(ClockFunc(func() time.Time { return now }))
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc, WithHolds())
+	wsvc := NewWalletService(db, usvc)
+	asvc := NewAuctionService(db)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 1, Amount: gbp(100)}))
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 2, Amount: gbp(100)}))
+
+	item := Item{Name: "portal gun", SellerID: testSeller(usvc), Value: gbp(10), Type: AuctionSealedFirstPrice, EndsAt: now.Add(time.Hour)}
+	assert.NoError(t, isvc.TxCreate(&item))
+
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: gbp(60)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: item.ID, Amount: gbp(50)}))
+
+	// any sealed bid may win, so they all hold funds until the item closes
+	holds, err := db.wallets.ListHoldsByItemID(item.ID)
+	assert.NoError(t, err)
+	assert.Len(t, holds, 2)
+
+	now = item.EndsAt
+	_, err = asvc.Advance()
+	assert.NoError(t, err)
+
+	holds, err = db.wallets.ListHoldsByItemID(item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []Hold{{UserID: 1, ItemID: item.ID, Amount: gbp(60)}}, holds)
+}
+
+func TestBidService_Holds_BuyNow(t *testing.T) {
+	db := CreateDatabase()
+	db.SetClock(testClock())
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc, WithHolds(), WithBuyNowThreshold(50))
+	wsvc := NewWalletService(db, usvc)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 1, Amount: gbp(100)}))
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 2, Amount: gbp(100)}))
+
+	item := Item{Name: "portal gun", SellerID: testSeller(usvc), Value: gbp(10), BuyNowPrice: gbpPtr(60)}
+	assert.NoError(t, isvc.TxCreate(&item))
+
+	assert.NoError(t, bsvc.TxCreateProxy(&ProxyBid{UserID: 1, ItemID: item.ID, MaxAmount: gbp(80)}))
+	assert.NoError(t, bsvc.TxBuyNow(&Bid{UserID: 2, ItemID: item.ID}))
+
+	// the proxy bidding above the buy-now price gets its funds back once the item is sold
+	wallet, err := wsvc.Get(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []Balance{{Total: gbp(100), Held: gbp(0), Available: gbp(100)}}, wallet.Balances)
+
+	holds, err := db.wallets.ListHoldsByItemID(item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []Hold{{UserID: 2, ItemID: item.ID, Amount: gbp(60)}}, holds)
+}
+
+func TestBidService_Holds_MultiUnit(t *testing.T) {
+	db := CreateDatabase()
+	db.SetClock(testClock())
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc, WithHolds())
+	wsvc := NewWalletService(db, usvc)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 1, Amount: gbp(100)}))
+
+	item := Item{Name: "meeseeks box", SellerID: testSeller(usvc), Value: gbp(1), Quantity: 100}
+	assert.NoError(t, isvc.TxCreate(&item))
+
+	// the bid holds the price of every unit requested, which the funds do not cover
+	err := bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: gbp(5), Quantity: 50})
+	assert.True(t, errors.Is(err, ValidationError{"amount": ErrNoFunds}), "unexpected error %v", err)
+
+	holds, err := db.wallets.ListHoldsByItemID(item.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, holds)
+
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: item.ID, Amount: gbp(5), Quantity: 20}))
+
+	holds, err = db.wallets.ListHoldsByItemID(item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []Hold{{UserID: 1, ItemID: item.ID, Amount: gbp(100)}}, holds)
+}
+
+func TestBidService_Holds_Concurrent(t *testing.T) {
+	db := CreateDatabase()
+	db.SetClock(testClock())
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc, WithHolds())
+	wsvc := NewWalletService(db, usvc)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	seller := testSeller(usvc)
+
+	assert.NoError(t, wsvc.TxDeposit(&Transfer{UserID: 1, Amount: gbp(100)}))
+
+	var items []Item
+	for n := 0; n < 20; n++ {
+		item := Item{Name: "portal gun", SellerID: seller, Value: gbp(10)}
+		assert.NoError(t, isvc.TxCreate(&item))
+		items = append(items, item)
+	}
+
+	// the funds only cover three of the bids placed at the same time on different items
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	placed := 0
+	for _, item := range items {
+		wg.Add(1)
+		go func(itemID int64) {
+			defer wg.Done()
+
+			err := bsvc.TxCreate(&Bid{UserID: 1, ItemID: itemID, Amount: gbp(30)})
+			if err == nil {
+				mu.Lock()
+				placed++
+				mu.Unlock()
+				return
+			}
+			assert.True(t, errors.Is(err, ValidationError{"amount": ErrNoFunds}), "unexpected error %v", err)
+		}(item.ID)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 3, placed)
+
+	wallet, err := wsvc.Get(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []Balance{{Total: gbp(100), Held: gbp(90), Available: gbp(10)}}, wallet.Balances)
+}
@@ -0,0 +1,189 @@
+package models
+
+import (
+	"time"
+)
+
+// Watch is an item followed by a user, who gets it in their watchlist without bidding.
+type Watch struct {
+	UserID  int64     `json:"userId"`
+	ItemID  int64     `json:"itemId"`
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// WatchlistEntry is an item of a watchlist along with the state of its bidding: its current winning
+// bid, unless its bids are sealed, and whether the user is the one leading.
+type WatchlistEntry struct {
+	Item       Item      `json:"item"`
+	WinningBid *Bid      `json:"winningBid,omitempty"`
+	Leading    bool      `json:"leading"`
+	AddedAt    time.Time `json:"addedAt"`
+}
+
+type WatchlistDB interface {
+	// TxAdd adds w to the watchlist of its user, keeping the time it was first added if the item is
+	// already in it.
+	TxAdd(w *Watch) error
+	// TxRemove removes the item from the watchlist of the user. It returns ErrNotFound if the item
+	// was not in it.
+	TxRemove(userID, itemID int64) error
+	ListWatchesByUserID(int64) ([]Watch, error)
+}
+
+type WatchlistService interface {
+	TxWatch(*Watch) error
+	TxUnwatch(*Watch) error
+	ListWatchlist(userID int64) ([]WatchlistEntry, error)
+}
+
+// watchlistService wraps the WatchlistService interface to allow mocking by interfaces
+type watchlistService struct {
+	WatchlistService
+}
+
+func NewWatchlistService(db *DB, isvc ItemService, usvc UserService, bsvc BidService) WatchlistService {
+	return watchlistService{
+		WatchlistService: &watchlistValidator{
+			WatchlistDB: &db.watches,
+			itemService: isvc,
+			userService: usvc,
+			bidService:  bsvc,
+			clock:       db.clock,
+		},
+	}
+}
+
+type watchlistValidator struct {
+	WatchlistDB
+	itemService ItemService
+	userService UserService
+	bidService  BidService
+	clock       Clock
+}
+
+type watchValFn func(*Watch) error
+
+func (wv *watchlistValidator) runValFuncs(w *Watch, fns ...func() (string, watchValFn)) error {
+	return runValidationFunctions(w, fns)
+}
+
+func (wv *watchlistValidator) TxWatch(w *Watch) error {
+	if err := wv.runValFuncs(w,
+		wv.userExists,
+		wv.itemExists,
+	); err != nil {
+		return err
+	}
+
+	w.AddedAt = wv.clock.Now()
+	return wv.WatchlistDB.TxAdd(w)
+}
+
+func (wv *watchlistValidator) TxUnwatch(w *Watch) error {
+	if err := wv.runValFuncs(w,
+		wv.userExists,
+	); err != nil {
+		return err
+	}
+
+	if err := wv.WatchlistDB.TxRemove(w.UserID, w.ItemID); err != nil {
+		if err == ErrNotFound {
+			return ValidationError{"item": ErrNotFound}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ListWatchlist lists the items followed by the user, the most recently added first.
+func (wv *watchlistValidator) ListWatchlist(userID int64) ([]WatchlistEntry, error) {
+	if err := wv.runValFuncs(&Watch{UserID: userID},
+		wv.userExists,
+	); err != nil {
+		return nil, err
+	}
+
+	watches, err := wv.WatchlistDB.ListWatchesByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]WatchlistEntry, 0, len(watches))
+	for _, w := range watches {
+		// items are deleted by their seller while they have no bids
+		item, err := wv.itemService.Get(w.ItemID)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entry, err := wv.entry(item, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		entry.AddedAt = w.AddedAt
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// entry returns the watchlist entry of item for the user. The winning bid comes from the index of the
+// best bids kept by the storage, only multi-unit items go through their bids to allocate the units.
+func (wv *watchlistValidator) entry(item Item, userID int64) (WatchlistEntry, error) {
+	entry := WatchlistEntry{Item: item}
+
+	winning, err := wv.bidService.GetWinningBid(item.ID)
+	switch err {
+	case nil:
+		entry.WinningBid = &winning
+		entry.Leading = winning.UserID == userID
+	case ErrSealed, ErrNotFound:
+		return entry, nil
+	default:
+		return entry, err
+	}
+
+	if item.multiUnit() {
+		allocs, err := wv.bidService.GetWinningBids(item.ID)
+		if err != nil && err != ErrNotFound {
+			return entry, err
+		}
+
+		entry.Leading = false
+		for _, a := range allocs {
+			if a.Bid.UserID == userID {
+				entry.Leading = true
+			}
+		}
+	}
+
+	// nobody wins an item that closed without meeting its reserve
+	if item.closed() && !item.Sold {
+		entry.Leading = false
+	}
+
+	return entry, nil
+}
+
+func (wv *watchlistValidator) userExists() (string, watchValFn) {
+	return "user", func(w *Watch) error {
+		if _, err := wv.userService.Get(w.UserID); err != nil {
+			return ErrNotFound
+		}
+		return nil
+	}
+}
+
+func (wv *watchlistValidator) itemExists() (string, watchValFn) {
+	return "item", func(w *Watch) error {
+		if _, err := wv.itemService.Get(w.ItemID); err != nil {
+			return ErrNotFound
+		}
+		return nil
+	}
+}
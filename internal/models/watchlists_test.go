@@ -0,0 +1,93 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchlistService(t *testing.T) {
+	now := testNow
+	db := CreateDatabase()
+	db.SetClock(ClockFunc(func() time.Time { return now }))
+
+	usvc := NewUserService(db)
+	isvc := NewItemService(db, usvc)
+	bsvc := NewBidService(db, isvc, usvc)
+	wsvc := NewWatchlistService(db, isvc, usvc, bsvc)
+
+	usvc.TxCreate(&User{Name: "Morty"})
+	usvc.TxCreate(&User{Name: "Rick"})
+	seller := testSeller(usvc)
+
+	english := Item{Name: "portal gun", SellerID: seller, Value: gbp(10)}
+	sealed := Item{Name: "plumbus", SellerID: seller, Value: gbp(10), Type: AuctionSealedFirstPrice}
+	multi := Item{Name: "concert ticket", SellerID: seller, Value: gbp(10), Quantity: 2}
+	quiet := Item{Name: "meeseeks box", SellerID: seller, Value: gbp(10)}
+	for _, i := range []*Item{&english, &sealed, &multi, &quiet} {
+		assert.NoError(t, isvc.TxCreate(i))
+	}
+
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: english.ID, Amount: gbp(20)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: english.ID, Amount: gbp(30)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: sealed.ID, Amount: gbp(20)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 1, ItemID: multi.ID, Amount: gbp(20)}))
+	assert.NoError(t, bsvc.TxCreate(&Bid{UserID: 2, ItemID: multi.ID, Amount: gbp(30)}))
+
+	for n, i := range []Item{english, sealed, multi, quiet} {
+		now = testNow.Add(time.Duration(n) * time.Minute)
+		assert.NoError(t, wsvc.TxWatch(&Watch{UserID: 1, ItemID: i.ID}))
+	}
+
+	// watching an item again keeps the time it was first added
+	now = testNow.Add(time.Hour)
+	w := Watch{UserID: 1, ItemID: english.ID}
+	assert.NoError(t, wsvc.TxWatch(&w))
+	assert.Equal(t, testNow, w.AddedAt)
+
+	entries, err := wsvc.ListWatchlist(1)
+	assert.NoError(t, err)
+
+	var cases = []struct {
+		item      Item
+		outamount Money
+		leading   bool
+	}{
+		{quiet, Money{}, false},
+		{multi, gbp(30), true},
+		{sealed, Money{}, false},
+		{english, gbp(30), false},
+	}
+	assert.Len(t, entries, len(cases))
+	for n, tt := range cases {
+		t.Run(tt.item.Name, func(t *testing.T) {
+			entry := entries[n]
+			assert.Equal(t, tt.item.ID, entry.Item.ID)
+			assert.Equal(t, tt.leading, entry.Leading)
+			assert.Equal(t, testNow.Add(time.Duration(len(cases)-1-n)*time.Minute), entry.AddedAt)
+
+			if tt.outamount.IsZero() {
+				assert.Nil(t, entry.WinningBid)
+			} else {
+				assert.Equal(t, tt.outamount, entry.WinningBid.Amount)
+			}
+		})
+	}
+
+	assert.NoError(t, wsvc.TxUnwatch(&Watch{UserID: 1, ItemID: quiet.ID}))
+
+	err = wsvc.TxUnwatch(&Watch{UserID: 1, ItemID: quiet.ID})
+	assert.True(t, errors.Is(err, ValidationError{"item": ErrNotFound}), "unexpected error %v", err)
+
+	err = wsvc.TxWatch(&Watch{UserID: 1, ItemID: 99})
+	assert.True(t, errors.Is(err, ValidationError{"item": ErrNotFound}), "unexpected error %v", err)
+
+	_, err = wsvc.ListWatchlist(99)
+	assert.True(t, errors.Is(err, ValidationError{"user": ErrNotFound}), "unexpected error %v", err)
+
+	entries, err = wsvc.ListWatchlist(2)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
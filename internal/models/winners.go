@@ -0,0 +1,168 @@
+package models
+
+import (
+	"sort"
+)
+
+// rankBids sorts bids from the best to the worst for item: the highest amount first (the lowest on
+// reverse auctions), and the earliest bid first on ties.
+func rankBids(item Item, bids []Bid) {
+	sort.Slice(bids, func(a, b int) bool {
+		if bids[a].Amount.Amount != bids[b].Amount.Amount {
+			return item.Direction.beats(bids[a].Amount, bids[b].Amount)
+		}
+		return bids[a].ID < bids[b].ID
+	})
+}
+
+// closingResult works out the winning bid of item among the active bids, and the price the winner
+// pays according to the auction type. It returns false if there is no winner.
+//
+// On multi-unit items, the winning bid is the best one, and every winner pays the lowest winning
+// bid, see allocate. On Vickrey auctions, the winner pays the second best bid, or the worst price the
+// seller accepts if there is no other bid.
+func closingResult(item Item, bids []Bid) (Bid, Money, bool) {
+	bids = activeBids(bids)
+	if len(bids) == 0 {
+		return Bid{}, Money{}, false
+	}
+
+	ranked := append([]Bid(nil), bids...)
+	rankBids(item, ranked)
+	winner := ranked[0]
+
+	if item.multiUnit() {
+		allocs := allocate(item, ranked)
+		return winner, allocs[len(allocs)-1].Bid.Amount, true
+	}
+
+	if item.Type != AuctionVickrey {
+		return winner, winner.Amount, true
+	}
+
+	dir := item.Direction
+
+	price := item.Value
+	if item.ReservePrice != nil {
+		price = *item.ReservePrice
+	}
+	if len(ranked) > 1 && dir.beats(ranked[1].Amount, price) {
+		price = ranked[1].Amount
+	}
+	if dir.beats(price, winner.Amount) {
+		price = winner.Amount
+	}
+
+	return winner, price, true
+}
+
+// freezeResult records on the closed item i the result of its auction among bids.
+func freezeResult(i *Item, bids []Bid) {
+	i.WinningBidID, i.ClearingPrice, i.Sold = 0, nil, false
+
+	if winning, price, ok := closingResult(*i, bids); ok {
+		i.WinningBidID = winning.ID
+		i.ClearingPrice = &price
+		i.Sold = i.ReserveMet(price)
+	}
+}
+
+// Allocation is the number of units of a multi-unit item won by a bid.
+type Allocation struct {
+	Bid      Bid `json:"bid"`
+	Quantity int `json:"quantity"`
+}
+
+// allocate fills the units of item with the best bids, which must be ranked with rankBids. The last
+// winning bid may get fewer units than it requested when there are not enough left.
+func allocate(item Item, ranked []Bid) []Allocation {
+	var allocs []Allocation
+
+	left := item.units()
+	for _, b := range ranked {
+		if left == 0 {
+			break
+		}
+
+		q := units(b.Quantity)
+		if q > left {
+			q = left
+		}
+
+		allocs = append(allocs, Allocation{Bid: b, Quantity: q})
+		left -= q
+	}
+
+	return allocs
+}
+
+// units returns the number of units of a quantity, where zero stands for a single unit.
+func units(quantity int) int {
+	if quantity == 0 {
+		return 1
+	}
+	return quantity
+}
+
+// marginalBid returns the bid a new bid of userID has to beat on the multi-unit item: the lowest
+// winning bid of the other users, provided they take all the units. It returns ErrNotFound if there
+// are units left.
+func (bv *bidValidator) marginalBid(item Item, userID int64) (Bid, error) {
+	bids, err := bv.BidDB.ListBidsByItemID(item.ID)
+	if err != nil {
+		return Bid{}, err
+	}
+
+	var others []Bid
+	for _, b := range activeBids(bids) {
+		if b.UserID != userID {
+			others = append(others, b)
+		}
+	}
+	rankBids(item, others)
+
+	allocs := allocate(item, others)
+
+	taken := 0
+	for _, a := range allocs {
+		taken += a.Quantity
+	}
+	if taken < item.units() {
+		return Bid{}, ErrNotFound
+	}
+
+	return allocs[len(allocs)-1].Bid, nil
+}
+
+// GetWinningBids returns the bids that currently win units of the item, along with the number of
+// units they get. Single-unit items have a single allocation, with their winning bid.
+func (bs *bidValidator) GetWinningBids(itemID int64) ([]Allocation, error) {
+	item, err := bs.itemService.Get(itemID)
+	if err != nil {
+		return nil, ValidationError{"item": ErrNotFound}
+	}
+
+	if !item.multiUnit() {
+		winning, err := bs.GetWinningBid(itemID)
+		if err != nil {
+			return nil, err
+		}
+		return []Allocation{{Bid: winning, Quantity: 1}}, nil
+	}
+
+	if item.Type.Sealed() && !item.closed() {
+		return nil, ErrSealed
+	}
+	if item.State == ItemCancelled {
+		return nil, ErrNotFound
+	}
+
+	bids, err := bs.BidDB.ListBidsByItemID(itemID)
+	if err != nil {
+		return nil, err
+	}
+	bids = activeBids(bids)
+	rankBids(item, bids)
+
+	return allocate(item, bids), nil
+}
@@ -0,0 +1,54 @@
+package views
+
+import (
+	"time"
+
+	"github.com/noelruault/auction-bid-tracker/internal/models"
+)
+
+// WinningBid is the winning bid of an item along with whether it meets the reserve price of the
+// item, which is not disclosed, and the price the winner pays.
+type WinningBid struct {
+	models.Bid
+	ReserveMet bool          `json:"reserveMet"`
+	Price      *models.Money `json:"price,omitempty"`
+}
+
+// NewWinningBid builds the WinningBid view of b, the winning bid of i. Until the item closes, the
+// price is the amount of the bid. Once closed, the price is left out if it would disclose the reserve.
+func NewWinningBid(b models.Bid, i models.Item) WinningBid {
+	price := &b.Amount
+	if i.ClearingPrice != nil {
+		price = i.PublicClearingPrice()
+	}
+
+	return WinningBid{Bid: b, ReserveMet: i.ReserveMet(b.Amount), Price: price}
+}
+
+// CreatedBid is the response to a new bid. It includes the end time of the item, as the bid may have
+// pushed it back.
+type CreatedBid struct {
+	models.Bid
+	ItemEndsAt time.Time `json:"itemEndsAt"`
+}
+
+// Winners are the bids that win units of a multi-unit item, along with the price every winner pays:
+// the clearing price once the item closes, or the lowest winning bid until then.
+type Winners struct {
+	Allocations []models.Allocation `json:"allocations"`
+	ReserveMet  bool                `json:"reserveMet"`
+	Price       models.Money        `json:"price"`
+}
+
+// NewWinners builds the Winners view of allocs, the allocation of the units of i.
+func NewWinners(allocs []models.Allocation, i models.Item) Winners {
+	price := models.Money{Currency: i.Value.Currency}
+	if len(allocs) > 0 {
+		price = allocs[len(allocs)-1].Bid.Amount
+	}
+	if i.ClearingPrice != nil {
+		price = *i.ClearingPrice
+	}
+
+	return Winners{Allocations: allocs, ReserveMet: i.ReserveMet(price), Price: price}
+}
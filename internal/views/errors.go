@@ -25,6 +25,13 @@ type Error struct {
 // In case err is a models.ValidationError, it returns by default an HTTP Bad Request doce an error code of "validation_error"
 // is returned, and the specific errors for each field are included as the value of the JSON "fields" field.
 func (e Error) JSON(ctx context.Context, w http.ResponseWriter, err error) {
+	status, data := e.Body(err)
+	web.Respond(ctx, w, data, status)
+}
+
+// Body returns the HTTP status code and the JSON document of the error response to err, as sent by
+// JSON, for the responses that do not go through HTTP.
+func (e Error) Body(err error) (int, map[string]interface{}) {
 	// set the defaults we are going to return
 	status := http.StatusInternalServerError
 	data := map[string]interface{}{"error": "server_error"}
@@ -76,5 +83,5 @@ func (e Error) JSON(ctx context.Context, w http.ResponseWriter, err error) {
 		data["message"] = fmt.Sprintf("unhandled_error: %v.", err.Error())
 	}
 
-	web.Respond(ctx, w, data, status)
+	return status, data
 }
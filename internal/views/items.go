@@ -0,0 +1,63 @@
+package views
+
+import (
+	"github.com/noelruault/auction-bid-tracker/internal/models"
+)
+
+// Item is the representation of an item shown to users other than its seller.
+type Item struct {
+	models.Item
+	HasReserve bool `json:"hasReserve"`
+}
+
+// PublicItem hides the reserve price of i, only telling whether the item has one, and its clearing
+// price unless it is public.
+func PublicItem(i models.Item) Item {
+	hasReserve := i.ReservePrice != nil
+	i.ClearingPrice = i.PublicClearingPrice()
+	i.ReservePrice = nil
+
+	return Item{Item: i, HasReserve: hasReserve}
+}
+
+// PublicItems applies PublicItem to every item in items.
+func PublicItems(items []models.Item) []Item {
+	ret := make([]Item, 0, len(items))
+	for _, i := range items {
+		ret = append(ret, PublicItem(i))
+	}
+
+	return ret
+}
+
+// WatchlistEntry is an item of the watchlist of a user, hiding the reserve price of the item.
+type WatchlistEntry struct {
+	models.WatchlistEntry
+	Item Item `json:"item"`
+}
+
+// PublicWatchlist applies PublicItem to the item of every entry in entries.
+func PublicWatchlist(entries []models.WatchlistEntry) []WatchlistEntry {
+	ret := make([]WatchlistEntry, 0, len(entries))
+	for _, e := range entries {
+		ret = append(ret, WatchlistEntry{WatchlistEntry: e, Item: PublicItem(e.Item)})
+	}
+
+	return ret
+}
+
+// SearchResult is an item matching a search, hiding the reserve price of the item.
+type SearchResult struct {
+	models.SearchResult
+	Item Item `json:"item"`
+}
+
+// PublicSearchResults applies PublicItem to the item of every result in results.
+func PublicSearchResults(results []models.SearchResult) []SearchResult {
+	ret := make([]SearchResult, 0, len(results))
+	for _, r := range results {
+		ret = append(ret, SearchResult{SearchResult: r, Item: PublicItem(r.Item)})
+	}
+
+	return ret
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrNoStreaming is returned when the response cannot be streamed to the client.
+var ErrNoStreaming = errors.New("web: streaming is not supported")
+
+// StartEvents sends the headers of a stream of Server-Sent Events to the client. The events are
+// sent with SendEvent.
+func StartEvents(w http.ResponseWriter) error {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		return ErrNoStreaming
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+
+	return nil
+}
+
+// SendEvent converts a Go value to JSON and sends it to the client as an event of type event. The
+// client resumes the stream after id when reconnecting, unless it is empty.
+func SendEvent(w http.ResponseWriter, id, event string, data interface{}) error {
+	res, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, res); err != nil {
+		return err
+	}
+
+	return flush(w)
+}
+
+// SendComment sends a comment to the client, which ignores it. It keeps idle streams alive.
+func SendComment(w http.ResponseWriter, comment string) error {
+	if _, err := fmt.Fprintf(w, ": %s\n\n", comment); err != nil {
+		return err
+	}
+
+	return flush(w)
+}
+
+func flush(w http.ResponseWriter) error {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		return ErrNoStreaming
+	}
+
+	f.Flush()
+	return nil
+}
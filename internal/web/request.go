@@ -1,9 +1,11 @@
 package web
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 
@@ -18,7 +20,16 @@ var validate = validator.New()
 //
 // If the provided value is a struct then it is checked for validation tags.
 func Decode(r *http.Request, val interface{}) error {
-	decoder := json.NewDecoder(r.Body)
+	return decode(r.Body, val)
+}
+
+// DecodeMessage decodes a message holding a JSON document into the provided value, like Decode.
+func DecodeMessage(msg []byte, val interface{}) error {
+	return decode(bytes.NewReader(msg), val)
+}
+
+func decode(r io.Reader, val interface{}) error {
+	decoder := json.NewDecoder(r)
 	decoder.DisallowUnknownFields() // return an error when the destination is a struct and the input
 	// contains object keys which do not match the destination.
 
@@ -4,15 +4,50 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"net/url"
 )
 
-// Respond converts a Go value to JSON and sends it to the client.
+// Page is a page of a list, sent by Respond in an envelope along with the cursor of the next page,
+// which is left out on the last page:
+//
+//	{"data": [...], "next": "eyJsIjoiL2l0ZW1zLyIsInMiOiJpZCIsImsiOnsiaSI6NTB9fQ.Qm9n..."}
+//
+// The URL of the next page is also given in a Link header.
+type Page struct {
+	Data interface{} `json:"data"`
+	Next string      `json:"next,omitempty"`
+
+	nextURL string
+}
+
+// NewPage returns the page of data read by r, followed by the page starting at the cursor next.
+func NewPage(r *http.Request, data interface{}, next string) Page {
+	p := Page{Data: data, Next: next}
+	if next == "" {
+		return p
+	}
+
+	u := url.URL{Path: r.URL.Path}
+	q := r.URL.Query()
+	q.Set("cursor", next)
+	u.RawQuery = q.Encode()
+	p.nextURL = u.String()
+
+	return p
+}
+
+// Respond converts a Go value to JSON and sends it to the client. Pages are sent with a Link header
+// to the next page, if any.
 func Respond(ctx context.Context, w http.ResponseWriter, data interface{}, statusCode int) error {
 	if statusCode == http.StatusNoContent {
 		w.WriteHeader(statusCode)
 		return nil
 	}
 
+	if p, ok := data.(Page); ok && p.nextURL != "" {
+		w.Header().Set("Link", "<"+p.nextURL+`>; rel="next"`)
+	}
+
 	// Convert the response value to JSON.
 	res, err := json.Marshal(data)
 	if err != nil {
@@ -0,0 +1,367 @@
+package web
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// The WebSocket protocol is implemented as described in RFC 6455, for the server side of the
+// connections only and without extensions.
+
+// wsGUID is appended to the key of the client to accept a WebSocket handshake.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Opcodes of the WebSocket frames.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+// Status codes of the WebSocket close frames.
+const (
+	WSNormalClosure   = 1000
+	WSProtocolError   = 1002
+	WSUnsupportedData = 1003
+	WSInvalidPayload  = 1007
+	WSMessageTooBig   = 1009
+)
+
+const (
+	// WSMaxMessage is the size of the largest message read from a client.
+	WSMaxMessage = 64 << 10
+	// WSReadTimeout is the time a client may stay silent, pongs included, before its connection
+	// is closed.
+	WSReadTimeout = 60 * time.Second
+
+	wsWriteTimeout = 10 * time.Second
+)
+
+var (
+	// ErrWSClosed is returned when reading a message from a connection closed by the client.
+	ErrWSClosed = errors.New("web: websocket closed")
+	// ErrWSOrigin is returned by Upgrade when the handshake comes from a web page of another origin.
+	ErrWSOrigin = errors.New("web: websocket origin not allowed")
+)
+
+// wsError is a protocol violation of the client, closing the connection with its code.
+type wsError struct {
+	code   int
+	reason string
+}
+
+func (e wsError) Error() string {
+	return fmt.Sprintf("web: websocket %d: %s", e.code, e.reason)
+}
+
+// WSConn is the server side of a WebSocket connection. A single goroutine reads the messages, while
+// any may write them.
+type WSConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	wmu    sync.Mutex
+	closed bool
+}
+
+// Upgrade completes the WebSocket handshake of r and takes over its connection. If r is not a valid
+// handshake, it responds with an error and returns it. Handshakes opened by web pages are only
+// accepted from the origin of the server itself and from the origins given, such as
+// "https://example.com", so that other sites cannot use the connection of their visitors.
+func Upgrade(w http.ResponseWriter, r *http.Request, origins []string) (*WSConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	rawKey, err := base64.StdEncoding.DecodeString(key)
+
+	switch {
+	case !allowedOrigin(r, origins):
+		http.Error(w, "websocket origin not allowed", http.StatusForbidden)
+		return nil, ErrWSOrigin
+	case r.Method != http.MethodGet:
+		err = errors.New("web: websocket handshake must be a GET request")
+	case !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket"):
+		err = errors.New("web: not a websocket handshake")
+	case r.Header.Get("Sec-WebSocket-Version") != "13":
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("web: unsupported websocket version")
+	case err != nil || len(rawKey) != 16:
+		err = errors.New("web: invalid websocket key")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, err
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, ErrNoStreaming.Error(), http.StatusInternalServerError)
+		return nil, ErrNoStreaming
+	}
+
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + wsGUID))
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", accept); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// the reader may already hold the first frames of the client
+	return &WSConn{conn: conn, br: brw.Reader}, nil
+}
+
+// allowedOrigin reports whether the origin of the handshake r is allowed. Browsers always send the
+// origin of the page, while other clients may leave it out.
+func allowedOrigin(r *http.Request, origins []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if u, err := url.Parse(origin); err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	for _, o := range origins {
+		if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerHasToken reports whether the comma-separated list of the header holds token, regardless of
+// the case.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage returns the next text message of the client. The control frames are handled along the
+// way: pings are answered, and a close frame ends the connection with ErrWSClosed. Binary messages
+// and protocol violations close the connection with an error.
+func (c *WSConn) ReadMessage() ([]byte, error) {
+	msg, err := c.readMessage()
+
+	var we wsError
+	if errors.As(err, &we) {
+		c.close(we.code, we.reason)
+	}
+	return msg, err
+}
+
+func (c *WSConn) readMessage() ([]byte, error) {
+	var msg []byte
+	var opcode byte
+	started := false
+
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(WSReadTimeout))
+
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			code := WSNormalClosure
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			c.close(code, "")
+			return nil, ErrWSClosed
+		case wsText, wsBinary:
+			if started {
+				return nil, wsError{WSProtocolError, "message interrupted"}
+			}
+			started, opcode = true, op
+		case wsContinuation:
+			if !started {
+				return nil, wsError{WSProtocolError, "unexpected continuation"}
+			}
+		default:
+			return nil, wsError{WSProtocolError, "unknown opcode"}
+		}
+
+		if len(msg)+len(payload) > WSMaxMessage {
+			return nil, wsError{WSMessageTooBig, "message too big"}
+		}
+		msg = append(msg, payload...)
+
+		if !fin {
+			continue
+		}
+
+		if opcode != wsText {
+			return nil, wsError{WSUnsupportedData, "only text messages are supported"}
+		}
+		if !utf8.Valid(msg) {
+			return nil, wsError{WSInvalidPayload, "invalid utf-8"}
+		}
+		return msg, nil
+	}
+}
+
+// readFrame reads a frame of the client, unmasking its payload.
+func (c *WSConn) readFrame() (bool, byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin, op := head[0]&0x80 != 0, head[0]&0x0f
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, wsError{WSProtocolError, "reserved bits set"}
+	}
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, wsError{WSProtocolError, "frames of clients must be masked"}
+	}
+
+	size := uint64(head[1] & 0x7f)
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if op >= wsClose && (size > 125 || !fin) {
+		return false, 0, nil, wsError{WSProtocolError, "invalid control frame"}
+	}
+	if size > WSMaxMessage {
+		return false, 0, nil, wsError{WSMessageTooBig, "message too big"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, op, payload, nil
+}
+
+// WriteJSON converts a Go value to JSON and sends it to the client as a text message.
+func (c *WSConn) WriteJSON(v interface{}) error {
+	res, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return c.writeFrame(wsText, res)
+}
+
+// Ping sends a ping to the client, whose pong keeps the connection alive.
+func (c *WSConn) Ping() error {
+	return c.writeFrame(wsPing, nil)
+}
+
+// Close closes the connection normally.
+func (c *WSConn) Close() error {
+	return c.close(WSNormalClosure, "")
+}
+
+// close sends a close frame with code and reason to the client, unless the connection was already
+// closed, and closes the connection.
+func (c *WSConn) close(code int, reason string) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	c.conn.Write(frame(wsClose, payload))
+	return c.conn.Close()
+}
+
+// writeFrame sends a single unmasked frame to the client.
+func (c *WSConn) writeFrame(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.closed {
+		return ErrWSClosed
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	_, err := c.conn.Write(frame(op, payload))
+	return err
+}
+
+// frame encodes a final frame of the server with op and payload.
+func frame(op byte, payload []byte) []byte {
+	b := []byte{0x80 | op, 0}
+
+	switch n := len(payload); {
+	case n < 126:
+		b[1] = byte(n)
+	case n <= 0xffff:
+		b[1] = 126
+		b = append(b, 0, 0)
+		binary.BigEndian.PutUint16(b[2:], uint16(n))
+	default:
+		b[1] = 127
+		b = append(b, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(b[2:], uint64(n))
+	}
+
+	return append(b, payload...)
+}
@@ -0,0 +1,462 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testFrame is a frame sent or received by the client side of a test connection.
+type testFrame struct {
+	fin     bool
+	op      byte
+	payload []byte
+}
+
+// clientFrame encodes a frame of a client, masked unless unmasked is set. size overrides the length
+// announced by the frame when it is not negative, to announce more than is sent.
+func clientFrame(fin bool, op byte, payload []byte, unmasked bool, size int) []byte {
+	b := []byte{op, 0}
+	if fin {
+		b[0] |= 0x80
+	}
+	if !unmasked {
+		b[1] |= 0x80
+	}
+
+	n := len(payload)
+	if size >= 0 {
+		n = size
+	}
+	switch {
+	case n < 126:
+		b[1] |= byte(n)
+	case n <= 0xffff:
+		b[1] |= 126
+		b = append(b, 0, 0)
+		binary.BigEndian.PutUint16(b[2:], uint16(n))
+	default:
+		b[1] |= 127
+		b = append(b, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(b[2:], uint64(n))
+	}
+
+	if unmasked {
+		return append(b, payload...)
+	}
+
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	b = append(b, mask...)
+	for i, c := range payload {
+		b = append(b, c^mask[i%4])
+	}
+	return b
+}
+
+// masked encodes a final masked frame of a client.
+func masked(op byte, payload string) []byte {
+	return clientFrame(true, op, []byte(payload), false, -1)
+}
+
+// readTestFrame reads a frame of the server, which is never masked.
+func readTestFrame(r io.Reader) (testFrame, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return testFrame{}, err
+	}
+	if head[1]&0x80 != 0 {
+		return testFrame{}, errors.New("frames of servers must not be masked")
+	}
+
+	size := uint64(head[1] & 0x7f)
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return testFrame{}, err
+		}
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return testFrame{}, err
+		}
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return testFrame{}, err
+	}
+	return testFrame{fin: head[0]&0x80 != 0, op: head[0] & 0x0f, payload: payload}, nil
+}
+
+// closeFrame returns the close frame of the server with code.
+func closeFrame(code int) testFrame {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return testFrame{fin: true, op: wsClose, payload: payload}
+}
+
+// wsPipe returns the server side of a connection, and its client side.
+func wsPipe(t *testing.T) (*WSConn, net.Conn) {
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	return &WSConn{conn: server, br: bufio.NewReader(server)}, client
+}
+
+func TestWSConn_ReadMessage(t *testing.T) {
+	large := strings.Repeat("a", WSMaxMessage)
+
+	var cases = []struct {
+		name    string
+		frames  [][]byte
+		replies []testFrame
+		outmsg  string
+		outerr  error
+	}{
+		{
+			"text",
+			[][]byte{masked(wsText, "hello")},
+			nil,
+			"hello",
+			nil,
+		},
+		{
+			"16-bit length",
+			[][]byte{masked(wsText, strings.Repeat("b", 300))},
+			nil,
+			strings.Repeat("b", 300),
+			nil,
+		},
+		{
+			"64-bit length up to the limit",
+			[][]byte{masked(wsText, large)},
+			nil,
+			large,
+			nil,
+		},
+		{
+			"fragmented, with control frames in between",
+			[][]byte{
+				clientFrame(false, wsText, []byte("hel"), false, -1),
+				masked(wsPing, "are you there"),
+				masked(wsPong, ""),
+				clientFrame(false, wsContinuation, []byte("l"), false, -1),
+				masked(wsContinuation, "o"),
+			},
+			[]testFrame{{fin: true, op: wsPong, payload: []byte("are you there")}},
+			"hello",
+			nil,
+		},
+		{
+			"close",
+			[][]byte{masked(wsClose, "\x03\xe9")},
+			[]testFrame{closeFrame(1001)},
+			"",
+			ErrWSClosed,
+		},
+		{
+			"close without code",
+			[][]byte{masked(wsClose, "")},
+			[]testFrame{closeFrame(WSNormalClosure)},
+			"",
+			ErrWSClosed,
+		},
+		{
+			"unmasked",
+			[][]byte{clientFrame(true, wsText, []byte("hello"), true, -1)},
+			[]testFrame{closeFrame(WSProtocolError)},
+			"",
+			wsError{WSProtocolError, "frames of clients must be masked"},
+		},
+		{
+			"reserved bits",
+			[][]byte{append([]byte{0x80 | 0x40 | wsText}, masked(wsText, "hello")[1:]...)},
+			[]testFrame{closeFrame(WSProtocolError)},
+			"",
+			wsError{WSProtocolError, "reserved bits set"},
+		},
+		{
+			"continuation first",
+			[][]byte{masked(wsContinuation, "hello")},
+			[]testFrame{closeFrame(WSProtocolError)},
+			"",
+			wsError{WSProtocolError, "unexpected continuation"},
+		},
+		{
+			"interrupted message",
+			[][]byte{clientFrame(false, wsText, []byte("hel"), false, -1), masked(wsText, "lo")},
+			[]testFrame{closeFrame(WSProtocolError)},
+			"",
+			wsError{WSProtocolError, "message interrupted"},
+		},
+		{
+			"fragmented control frame",
+			[][]byte{clientFrame(false, wsPing, nil, false, -1)},
+			[]testFrame{closeFrame(WSProtocolError)},
+			"",
+			wsError{WSProtocolError, "invalid control frame"},
+		},
+		{
+			"long control frame",
+			[][]byte{masked(wsPing, strings.Repeat("p", 126))},
+			[]testFrame{closeFrame(WSProtocolError)},
+			"",
+			wsError{WSProtocolError, "invalid control frame"},
+		},
+		{
+			"unknown opcode",
+			[][]byte{masked(0x3, "hello")},
+			[]testFrame{closeFrame(WSProtocolError)},
+			"",
+			wsError{WSProtocolError, "unknown opcode"},
+		},
+		{
+			"binary",
+			[][]byte{masked(wsBinary, "hello")},
+			[]testFrame{closeFrame(WSUnsupportedData)},
+			"",
+			wsError{WSUnsupportedData, "only text messages are supported"},
+		},
+		{
+			"invalid utf-8",
+			[][]byte{masked(wsText, "\xff\xfe")},
+			[]testFrame{closeFrame(WSInvalidPayload)},
+			"",
+			wsError{WSInvalidPayload, "invalid utf-8"},
+		},
+		{
+			"frame too big",
+			[][]byte{clientFrame(true, wsText, nil, false, WSMaxMessage+1)},
+			[]testFrame{closeFrame(WSMessageTooBig)},
+			"",
+			wsError{WSMessageTooBig, "message too big"},
+		},
+		{
+			"message too big",
+			[][]byte{
+				clientFrame(false, wsText, []byte(large[:WSMaxMessage/2]), false, -1),
+				masked(wsContinuation, large[:WSMaxMessage/2+1]),
+			},
+			[]testFrame{closeFrame(WSMessageTooBig)},
+			"",
+			wsError{WSMessageTooBig, "message too big"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, client := wsPipe(t)
+
+			// the pipe is synchronous, so the frames are sent while the server replies
+			go func() {
+				for _, f := range tt.frames {
+					if _, err := client.Write(f); err != nil {
+						return
+					}
+				}
+			}()
+
+			type result struct {
+				msg []byte
+				err error
+			}
+			done := make(chan result, 1)
+			go func() {
+				msg, err := conn.ReadMessage()
+				done <- result{msg, err}
+			}()
+
+			for _, want := range tt.replies {
+				got, err := readTestFrame(client)
+				assert.NoError(t, err)
+				if want.op == wsClose && len(got.payload) > 2 {
+					// the reason of the close frame is informative only
+					got.payload = got.payload[:2]
+				}
+				assert.Equal(t, want, got)
+			}
+
+			res := <-done
+			assert.Equal(t, tt.outerr, res.err)
+			assert.Equal(t, tt.outmsg, string(res.msg))
+
+			// the connection is closed along with the close frame
+			if tt.outerr != nil {
+				_, err := client.Read(make([]byte, 1))
+				assert.Equal(t, io.EOF, err)
+			}
+		})
+	}
+}
+
+func TestWSConn_Write(t *testing.T) {
+	conn, client := wsPipe(t)
+
+	go func() {
+		conn.WriteJSON(map[string]string{"type": "subscribed"})
+		conn.WriteJSON(strings.Repeat("w", 70000))
+		conn.Ping()
+		conn.Close()
+	}()
+
+	f, err := readTestFrame(client)
+	assert.NoError(t, err)
+	assert.Equal(t, testFrame{fin: true, op: wsText, payload: []byte(`{"type":"subscribed"}`)}, f)
+
+	f, err = readTestFrame(client)
+	assert.NoError(t, err)
+	assert.Equal(t, testFrame{fin: true, op: wsText, payload: []byte(`"` + strings.Repeat("w", 70000) + `"`)}, f)
+
+	f, err = readTestFrame(client)
+	assert.NoError(t, err)
+	assert.Equal(t, testFrame{fin: true, op: wsPing, payload: []byte{}}, f)
+
+	f, err = readTestFrame(client)
+	assert.NoError(t, err)
+	assert.Equal(t, closeFrame(WSNormalClosure), f)
+
+	// nothing is sent once the connection is closed
+	assert.Equal(t, ErrWSClosed, conn.WriteJSON("late"))
+	assert.NoError(t, conn.Close())
+}
+
+func TestFrame(t *testing.T) {
+	var cases = []struct {
+		size    int
+		outhead []byte
+	}{
+		{0, []byte{0x81, 0}},
+		{125, []byte{0x81, 125}},
+		{126, []byte{0x81, 126, 0, 126}},
+		{0xffff, []byte{0x81, 126, 0xff, 0xff}},
+		{0x10000, []byte{0x81, 127, 0, 0, 0, 0, 0, 1, 0, 0}},
+	}
+
+	for _, tt := range cases {
+		t.Run(fmt.Sprint(tt.size), func(t *testing.T) {
+			b := frame(wsText, bytes.Repeat([]byte("f"), tt.size))
+			assert.Equal(t, tt.outhead, b[:len(tt.outhead)])
+			assert.Len(t, b, len(tt.outhead)+tt.size)
+		})
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r, []string{"https://allowed.example.com/"})
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// echoes the first message
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.WriteJSON(string(msg))
+	}))
+	defer srv.Close()
+
+	host := srv.Listener.Addr().String()
+
+	// the example handshake of RFC 6455
+	key, accept := "dGhlIHNhbXBsZSBub25jZQ==", "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="
+
+	var cases = []struct {
+		name      string
+		method    string
+		header    map[string]string
+		outstatus int
+	}{
+		{"ok", http.MethodGet, nil, http.StatusSwitchingProtocols},
+		{"same origin", http.MethodGet, map[string]string{"Origin": "http://" + host}, http.StatusSwitchingProtocols},
+		{"allowed origin", http.MethodGet, map[string]string{"Origin": "https://Allowed.example.com"}, http.StatusSwitchingProtocols},
+		{"tokens in lists", http.MethodGet, map[string]string{"Connection": "keep-alive, Upgrade", "Upgrade": "WebSocket"}, http.StatusSwitchingProtocols},
+		{"other origin", http.MethodGet, map[string]string{"Origin": "https://evil.example.com"}, http.StatusForbidden},
+		{"other port", http.MethodGet, map[string]string{"Origin": "http://" + host + "0"}, http.StatusForbidden},
+		{"opaque origin", http.MethodGet, map[string]string{"Origin": "null"}, http.StatusForbidden},
+		{"not a get", http.MethodPost, nil, http.StatusBadRequest},
+		{"no upgrade", http.MethodGet, map[string]string{"Upgrade": ""}, http.StatusBadRequest},
+		{"no connection upgrade", http.MethodGet, map[string]string{"Connection": "keep-alive"}, http.StatusBadRequest},
+		{"old version", http.MethodGet, map[string]string{"Sec-WebSocket-Version": "8"}, http.StatusUpgradeRequired},
+		{"invalid key", http.MethodGet, map[string]string{"Sec-WebSocket-Key": "c2hvcnQ="}, http.StatusBadRequest},
+		{"no key", http.MethodGet, map[string]string{"Sec-WebSocket-Key": ""}, http.StatusBadRequest},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", host)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+			header := map[string]string{
+				"Connection":            "Upgrade",
+				"Upgrade":               "websocket",
+				"Sec-WebSocket-Version": "13",
+				"Sec-WebSocket-Key":     key,
+			}
+			for k, v := range tt.header {
+				header[k] = v
+			}
+
+			req := fmt.Sprintf("%s /live HTTP/1.1\r\nHost: %s\r\n", tt.method, host)
+			for k, v := range header {
+				if v != "" {
+					req += k + ": " + v + "\r\n"
+				}
+			}
+
+			// the first frame comes along with the handshake, before the server answers it
+			_, err = conn.Write(append([]byte(req+"\r\n"), masked(wsText, "hello")...))
+			assert.NoError(t, err)
+
+			br := bufio.NewReader(conn)
+			res, err := http.ReadResponse(br, nil)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.outstatus, res.StatusCode)
+
+			if tt.outstatus == http.StatusUpgradeRequired {
+				assert.Equal(t, "13", res.Header.Get("Sec-WebSocket-Version"))
+			}
+			if tt.outstatus != http.StatusSwitchingProtocols {
+				return
+			}
+
+			assert.Equal(t, accept, res.Header.Get("Sec-WebSocket-Accept"))
+			assert.True(t, strings.EqualFold("websocket", res.Header.Get("Upgrade")))
+
+			f, err := readTestFrame(br)
+			assert.NoError(t, err)
+			assert.Equal(t, testFrame{fin: true, op: wsText, payload: []byte(`"hello"`)}, f)
+
+			f, err = readTestFrame(br)
+			assert.NoError(t, err)
+			assert.Equal(t, closeFrame(WSNormalClosure), f)
+		})
+	}
+}